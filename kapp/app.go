@@ -17,22 +17,47 @@ type IExecutor interface {
 	SearchApp(name string) (IApp, bool)
 }
 
-// IApp 应用接口，对应于每个微服务应用，可以是RPC或者HTTP
+// IApp 应用接口，对应于每个微服务应用，可以是RPC或者HTTP；App需要匿名嵌入AppBase，加入Executor时由Executor注入
 type IApp interface {
 	AppName() string // 获取应用名称
 	Execute() error  // 启动应用，其中包装有微服务应用的业务逻辑
 	Exit() error     // 关闭应用，关闭微服务应用
 }
 
+/*
+ILivenessApp 可以报告自身是否仍在正常工作的App；多进程模式下，子进程每次发送心跳前调用Alive，
+Alive返回错误或者在心跳间隔内未返回时，子进程不发送心跳，主进程超过心跳超时后按照失去响应处理。
+*/
+type ILivenessApp interface {
+	Alive(ctx context.Context) error
+}
+
+// AppBase App的公共部分，App通过匿名嵌入AppBase，在加入Executor时由Executor注入以下字段
 type AppBase struct {
 	Executor  IExecutor       // App所属的执行器
 	Context   context.Context // App专属上下文
 	AppConfig *AppConfig      // App相关的配置
 }
 
+// IAppBase 嵌入了AppBase的App，Executor通过Base获取需要注入的AppBase
+type IAppBase interface {
+	Base() *AppBase
+}
+
+// Base 返回App嵌入的AppBase
+func (that *AppBase) Base() *AppBase {
+	return that
+}
+
 type AppContainer struct {
 	App       IApp
 	StartTime *gtime.Time         // APP启动时间
 	StopTime  *gtime.Time         // APP关闭时间
 	State     processes.ProcState // APP的运行状态，用进程状态表示
+	Restarts  int                 // APP被重启的次数，不包括第一次启动
+	LastRun   *gtime.Time         // 定时App或一次性任务最近一次执行的开始时间
+	NextRun   *gtime.Time         // 定时App下一次按计划执行的时间
+	LastError string              // 定时App或一次性任务最近一次执行的错误，执行成功时为空
+	Runs      int                 // 定时App或一次性任务的执行次数
+	Completed bool                // 一次性任务已执行成功
 }
@@ -0,0 +1,171 @@
+package kapp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	"github.com/gogf/gf/os/gcfg"
+)
+
+/*
+  定时App：Executor按照执行计划调用Execute，而不是启动后一直运行，Execute返回即表示本次执行结束；
+  App处于运行状态时才会按计划执行，停止App时如果正在执行，会调用Exit让Execute尽快返回；
+  实现了IRunContextApp的App每次执行使用单独的ctx，超时时只取消该次执行，否则超时时调用Exit；
+  执行计划的格式见kcron，可以在配置中覆盖(apps.[appName]节点)：
+    apps:
+      cleanup:
+        schedule: "0 3 * * *"   # cron表达式、@daily等描述符，或者@every 10m、10m这样的固定间隔
+        overlap: skip           # 上一次执行还未结束时的处理方式：skip、queue、allow
+        jitter: 5m              # 每次按计划执行前随机等待[0, jitter)，避免多个实例同时执行
+        timeout: 1h             # 单次执行的超时时间，超时后只结束本次执行，0表示不限制
+  使用示例：
+    k.AddAppToExecutor(kapp.NewCronApp("cleanup", "0 3 * * *", func(ctx context.Context) error {
+        return cleanup(ctx)
+    }))
+  可以通过交互式shell的trigger命令立即执行一次。
+  多进程模式下每个副本子进程都会按计划执行，定时App所在的Executor通常只需要1个副本。
+*/
+
+// OverlapPolicy 上一次执行还未结束时，再次需要执行的处理方式
+type OverlapPolicy string
+
+const (
+	OverlapSkip  OverlapPolicy = "skip"  // 跳过本次执行，默认值
+	OverlapQueue OverlapPolicy = "queue" // 等待上一次执行结束后再执行
+	OverlapAllow OverlapPolicy = "allow" // 同时执行
+)
+
+// ScheduleConfig 定时App的执行计划
+type ScheduleConfig struct {
+	Spec    string        // 执行计划，格式见kcron
+	Overlap OverlapPolicy // 上一次执行还未结束时的处理方式
+	Jitter  time.Duration // 每次按计划执行前随机等待的最长时间
+	Timeout time.Duration // 单次执行的超时时间，0表示不限制
+}
+
+// IScheduledApp 定时App，Executor按照Schedule返回的执行计划调用Execute
+type IScheduledApp interface {
+	IApp
+	Schedule() *ScheduleConfig // App启动时读取执行计划
+}
+
+// IRunContextApp 每次执行使用单独ctx的定时App，Executor在单次执行超时时只取消该次执行的ctx
+type IRunContextApp interface {
+	IScheduledApp
+	ExecuteContext(ctx context.Context) error
+}
+
+// LoadScheduleConfig 读取node节点下的执行计划，未配置的项使用def中的值
+func LoadScheduleConfig(cfg *gcfg.Config, node string, def *ScheduleConfig) *ScheduleConfig {
+	if def == nil {
+		def = &ScheduleConfig{}
+	}
+	conf := &ScheduleConfig{
+		Spec:    cfg.GetString(node+".schedule", def.Spec),
+		Overlap: OverlapPolicy(cfg.GetString(node+".overlap", string(def.Overlap))),
+		Jitter:  cfg.GetDuration(node+".jitter", def.Jitter),
+		Timeout: cfg.GetDuration(node+".timeout", def.Timeout),
+	}
+	if conf.Overlap == "" {
+		conf.Overlap = OverlapSkip
+	}
+	return conf
+}
+
+// Validate 检查执行计划中的处理方式和时间，执行计划本身由kcron.Parse检查
+func (that *ScheduleConfig) Validate() error {
+	switch that.Overlap {
+	case OverlapSkip, OverlapQueue, OverlapAllow:
+	default:
+		return gerror.NewCodef(gcode.CodeInvalidParameter, "不支持的overlap: %s", that.Overlap)
+	}
+	if that.Jitter < 0 || that.Timeout < 0 {
+		return gerror.NewCode(gcode.CodeInvalidParameter, "jitter和timeout不能为负数")
+	}
+	return nil
+}
+
+// CronApp 按执行计划调用Job的定时App
+type CronApp struct {
+	AppBase
+	Name string                          // App名称
+	Node string                          // 配置节点，默认为apps.[Name]
+	Spec string                          // 默认的执行计划，可以被配置中的schedule覆盖
+	Job  func(ctx context.Context) error // 每次执行的任务，ctx在本次执行超时或者Exit时被取消
+	lock sync.Mutex
+	seq  int64
+	runs map[int64]context.CancelFunc // 正在执行的各次Job的cancel
+}
+
+// NewCronApp 创建CronApp，node为可选的配置节点
+func NewCronApp(name, spec string, job func(ctx context.Context) error, node ...string) *CronApp {
+	app := &CronApp{Name: name, Spec: spec, Job: job, Node: ConfigNode(name)}
+	if len(node) > 0 && node[0] != "" {
+		app.Node = node[0]
+	}
+	return app
+}
+
+func (that *CronApp) AppName() string {
+	return that.Name
+}
+
+// Schedule 读取执行计划
+func (that *CronApp) Schedule() *ScheduleConfig {
+	return LoadScheduleConfig(that.Config(), that.Node, &ScheduleConfig{Spec: that.Spec})
+}
+
+// Execute 执行一次Job
+func (that *CronApp) Execute() error {
+	return that.ExecuteContext(context.Background())
+}
+
+// ExecuteContext 执行一次Job，ctx被取消或者Exit时结束本次执行
+func (that *CronApp) ExecuteContext(ctx context.Context) error {
+	if that.Job == nil {
+		return gerror.NewCodef(gcode.CodeMissingParameter, "CronApp[%s]未设置Job", that.Name)
+	}
+	parent := that.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	runCtx, cancel := context.WithCancel(parent)
+	defer cancel()
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-ctx.Done():
+			cancel()
+		case <-stop:
+		}
+	}()
+
+	that.lock.Lock()
+	if that.runs == nil {
+		that.runs = map[int64]context.CancelFunc{}
+	}
+	that.seq++
+	id := that.seq
+	that.runs[id] = cancel
+	that.lock.Unlock()
+	defer func() {
+		that.lock.Lock()
+		delete(that.runs, id)
+		that.lock.Unlock()
+	}()
+	return that.Job(runCtx)
+}
+
+// Exit 取消所有正在执行的Job，之后的执行不受影响
+func (that *CronApp) Exit() error {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	for _, cancel := range that.runs {
+		cancel()
+	}
+	return nil
+}
@@ -0,0 +1,62 @@
+package kapp
+
+import (
+	"github.com/gogf/gf/container/gmap"
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	"github.com/gogf/gf/util/gconv"
+)
+
+/*
+  App工厂：按类型名称注册App的构造方法，keeper运行时可以通过交互式shell的adda命令按类型名称创建新的App实例；
+  App的配置位于apps.[appName]节点下，adda命令传入的配置会写入该节点，App在Execute时读取。
+  使用示例(在StartFunc中注册，主进程和子进程都需要注册)：
+    kapp.RegisterFactory("consumer", func(name, node string) (kapp.IApp, error) {
+        return kapp.NewWorkerApp(name, fetch, handle, node), nil
+    })
+  之后可以运行：ctrl adda -e workers -t consumer -c '{"concurrency": 4}' consumer2
+  也可以在配置文件中声明App的类型和所属的Executor，keeper启动时创建(见Keeper.AddConfigApps)：
+    apps:
+      consumer2:
+        type: consumer
+        executor: workers
+  工厂创建的App嵌入AppBase即可，加入Executor时由Executor注入AppBase中的字段。
+*/
+
+// AppFactory App的构造方法，name为App名称，node为App的配置节点
+type AppFactory func(name, node string) (IApp, error)
+
+// factories 已注册的App工厂，key: 类型名称，value: AppFactory
+var factories = gmap.NewStrAnyMap(true)
+
+// RegisterFactory 注册App工厂，类型名称不能重复
+func RegisterFactory(typeName string, factory AppFactory) error {
+	if typeName == "" || factory == nil {
+		return gerror.NewCode(gcode.CodeMissingParameter, "注册App工厂需要类型名称和构造方法")
+	}
+	if !factories.SetIfNotExist(typeName, factory) {
+		return gerror.NewCodef(gcode.CodeInvalidOperation, "App工厂[%s]已注册", typeName)
+	}
+	return nil
+}
+
+// Factories 已注册的App工厂的类型名称
+func Factories() []string {
+	return gconv.Strings(factories.Keys())
+}
+
+// NewApp 通过类型名称对应的工厂创建App
+func NewApp(typeName, name string) (IApp, error) {
+	v, found := factories.Search(typeName)
+	if !found {
+		return nil, gerror.NewCodef(gcode.CodeNotFound, "App工厂[%s]未注册", typeName)
+	}
+	app, err := v.(AppFactory)(name, ConfigNode(name))
+	if err != nil {
+		return nil, gerror.WrapCodef(gcode.CodeOperationFailed, err, "App工厂[%s]创建App[%s]失败", typeName, name)
+	}
+	if app == nil || app.AppName() != name {
+		return nil, gerror.NewCodef(gcode.CodeOperationFailed, "App工厂[%s]创建的App名称与[%s]不一致", typeName, name)
+	}
+	return app, nil
+}
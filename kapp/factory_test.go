@@ -0,0 +1,90 @@
+package kapp
+
+import (
+	"testing"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+)
+
+type testApp struct {
+	AppBase
+	name string
+}
+
+func (that *testApp) AppName() string { return that.name }
+func (that *testApp) Execute() error  { return nil }
+func (that *testApp) Exit() error     { return nil }
+
+func TestRegisterFactory(t *testing.T) {
+	factory := func(name, node string) (IApp, error) { return &testApp{name: name}, nil }
+	if err := RegisterFactory("test.register", factory); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name     string
+		typeName string
+		factory  AppFactory
+		code     gcode.Code
+	}{
+		{"empty type", "", factory, gcode.CodeMissingParameter},
+		{"nil factory", "test.nil", nil, gcode.CodeMissingParameter},
+		{"duplicated type", "test.register", factory, gcode.CodeInvalidOperation},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := RegisterFactory(c.typeName, c.factory)
+			if err == nil || gerror.Code(err) != c.code {
+				t.Fatalf("err = %v (code %v), want code %v", err, gerror.Code(err), c.code)
+			}
+		})
+	}
+}
+
+func TestNewApp(t *testing.T) {
+	factories := map[string]AppFactory{
+		"test.ok": func(name, node string) (IApp, error) {
+			if node != ConfigNode(name) {
+				return nil, gerror.Newf("unexpected node %s", node)
+			}
+			return &testApp{name: name}, nil
+		},
+		"test.fail": func(name, node string) (IApp, error) { return nil, gerror.New("boom") },
+		"test.nil":  func(name, node string) (IApp, error) { return nil, nil },
+		"test.name": func(name, node string) (IApp, error) { return &testApp{name: "other"}, nil },
+	}
+	for typeName, factory := range factories {
+		if err := RegisterFactory(typeName, factory); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cases := []struct {
+		name     string
+		typeName string
+		code     gcode.Code // gcode.CodeNil表示创建成功
+	}{
+		{"created", "test.ok", gcode.CodeNil},
+		{"not registered", "test.missing", gcode.CodeNotFound},
+		{"factory error", "test.fail", gcode.CodeOperationFailed},
+		{"nil app", "test.nil", gcode.CodeOperationFailed},
+		{"name mismatch", "test.name", gcode.CodeOperationFailed},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			app, err := NewApp(c.typeName, "app1")
+			if gerror.Code(err) != c.code {
+				t.Fatalf("err = %v (code %v), want code %v", err, gerror.Code(err), c.code)
+			}
+			if err == nil && app.AppName() != "app1" {
+				t.Fatalf("app name = %s, want app1", app.AppName())
+			}
+		})
+	}
+	found := false
+	for _, name := range Factories() {
+		found = found || name == "test.ok"
+	}
+	if !found {
+		t.Fatalf("Factories() = %v, want test.ok included", Factories())
+	}
+}
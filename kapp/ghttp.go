@@ -0,0 +1,155 @@
+package kapp
+
+import (
+	"os"
+	"sync"
+	"time"
+	_ "unsafe" // go:linkname
+
+	"github.com/gogf/gf"
+	"github.com/gogf/gf/container/gtype"
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	"github.com/gogf/gf/net/ghttp"
+	"github.com/gogf/gf/util/gutil"
+	ktype "github.com/moqsien/gokeeper/ktype"
+)
+
+/*
+  GfApp 在keeper中运行gf框架的ghttp.Server，配置示例(apps.[appName]节点)：
+    apps:
+      web:
+        address: ":8199"             # 以及ServerConfig、HttpConfig中的其他配置
+        clientMaxBodySize: 8MB       # 其余配置项原样交给ghttp.Server.SetConfigWithMap
+        sessionPath: /tmp/sessions
+        dumpRouterMap: false
+  使用示例：
+    s := g.Server("web")
+    s.BindHandler("/", func(r *ghttp.Request) { r.Response.Write("hello") })
+    k.AddAppToExecutor(kapp.NewGfApp("web", s))
+
+  ghttp.Server自带的平滑重启与keeper冲突：它会监听SIGTERM、SIGUSR1等信号，收到信号后自行结束或者fork新的进程。
+  因此GfApp：
+    - 禁用ghttp的进程级初始化，信号由keeper统一处理，平滑重启由keeper的reload完成；
+      ghttp平滑重启时通过GF_SERVER_RELOAD向新进程传递监听套接字，keeper的子进程中清空该环境变量，GfApp启动前也会清除；
+      gf没有提供禁用的公开方法，只能通过go:linkname设置ghttp未导出的变量，因此go.mod中固定了gf的版本，
+      gf的版本与ghttpLinkedVersion不一致时GfApp启动失败，升级gf时需要确认该变量未变化；
+    - ghttp.Server.Start负责路由注册、session等初始化，它总是会监听，这里让它监听127.0.0.1的随机端口，开始监听后立即通过Shutdown关闭；
+    - 真正的请求由HttpApp在继承自keeper的监听套接字上处理，Exit时在shutdownTimeout内等待处理中的请求完成；
+    - reload时重新读取配置并交给ghttp.Server，路由、session等只在第一次启动时初始化。
+  HTTPS只通过certFile、keyFile配置，httpsAddr、httpsCertPath等ghttp的HTTPS配置会被忽略，也不要开启ghttp的admin功能。
+*/
+
+// ghttpProcessInitialized ghttp中标记进程级初始化(信号处理、平滑重启的进程通信)是否已完成
+//
+//go:linkname ghttpProcessInitialized github.com/gogf/gf/net/ghttp.serverProcessInitialized
+var ghttpProcessInitialized *gtype.Bool
+
+// ghttpLinkedVersion 确认过ghttpProcessInitialized的gf版本，与go.mod中的版本一致
+const ghttpLinkedVersion = "v1.16.9"
+
+// ghttpStartTimeout 等待ghttp.Server开始监听的最长时间
+const ghttpStartTimeout = 5 * time.Second
+
+// ghttpIgnoredConfig 由GfApp处理，不交给ghttp.Server的配置
+var ghttpIgnoredConfig = []string{
+	"listener", "shutdownTimeout", "certFile", "keyFile",
+	"httpsAddr", "httpsCertPath", "httpsKeyPath", "graceful", "gracefulTimeout",
+}
+
+// ghttpStartLock ghttp的进程级初始化只能执行一次，多个GfApp需要依次初始化
+var ghttpStartLock sync.Mutex
+
+// GfApp 运行ghttp.Server的App
+type GfApp struct {
+	HttpApp
+	Server  *ghttp.Server // 完成了路由注册的ghttp.Server
+	started bool          // ghttp.Server.Start已执行，由ghttpStartLock保护
+}
+
+// NewGfApp 创建GfApp，node为可选的配置节点
+func NewGfApp(name string, s *ghttp.Server, node ...string) *GfApp {
+	app := &GfApp{Server: s}
+	app.Name = name
+	app.Handler = s
+	app.Node = ConfigNode(name)
+	if len(node) > 0 && node[0] != "" {
+		app.Node = node[0]
+	}
+	return app
+}
+
+// gfConfig 配置节点中需要交给ghttp.Server的配置
+func (that *GfApp) gfConfig() map[string]interface{} {
+	m := gutil.MapCopy(that.Config().GetMap(that.Node))
+	for _, key := range ghttpIgnoredConfig {
+		if k, _ := gutil.MapPossibleItemByKey(m, key); k != "" {
+			delete(m, k)
+		}
+	}
+	return m
+}
+
+// checkGhttpLink 确认go:linkname得到的变量可用，gf版本变化时返回错误
+func checkGhttpLink() error {
+	if gf.VERSION != ghttpLinkedVersion || ghttpProcessInitialized == nil {
+		return gerror.NewCodef(gcode.CodeNotSupported,
+			"GfApp依赖gf %s中未导出的ghttp.serverProcessInitialized，当前gf版本为%s，确认该变量未变化后更新ghttpLinkedVersion",
+			ghttpLinkedVersion, gf.VERSION)
+	}
+	return nil
+}
+
+/*
+startGf 更新ghttp.Server的配置，第一次执行时调用ghttp.Server.Start完成初始化；
+ghttp.Server总是会监听，这里让它监听127.0.0.1的随机端口，开始监听后立即关闭，请求由HttpApp处理。
+ghttp.Server再次Start时会重新启动之前所有的监听，因此reload时不再调用Start，只重启HttpApp。
+*/
+func (that *GfApp) startGf() error {
+	ghttpStartLock.Lock()
+	defer ghttpStartLock.Unlock()
+	if m := that.gfConfig(); len(m) > 0 {
+		if err := that.Server.SetConfigWithMap(m); err != nil {
+			return err
+		}
+	}
+	if that.started {
+		return nil
+	}
+	if err := checkGhttpLink(); err != nil {
+		return err
+	}
+	// 信号由keeper处理，不再由ghttp处理
+	ghttpProcessInitialized.Set(true)
+	// 监听套接字只由keeper传递，避免ghttp按照继承来的环境变量使用不属于它的文件描述符
+	_ = os.Unsetenv(ktype.AdminActionReloadEnvKey)
+	that.Server.SetAddr("127.0.0.1:0")
+	if err := that.Server.Start(); err != nil {
+		return err
+	}
+	for deadline := time.Now().Add(ghttpStartTimeout); that.Server.Status() != ghttp.ServerStatusRunning; {
+		if time.Now().After(deadline) {
+			return gerror.NewCodef(gcode.CodeOperationFailed, "GfApp[%s]超过%v未完成ghttp.Server的初始化", that.Name, ghttpStartTimeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	_ = that.Server.Shutdown()
+	that.started = true
+	return nil
+}
+
+// Execute 初始化ghttp.Server，并在继承的监听套接字上处理请求，直到Exit被调用
+func (that *GfApp) Execute() error {
+	if that.Server == nil {
+		return gerror.NewCodef(gcode.CodeMissingParameter, "GfApp[%s]未设置Server", that.Name)
+	}
+	if err := that.startGf(); err != nil {
+		return err
+	}
+	return that.HttpApp.Execute()
+}
+
+// Exit 在shutdownTimeout内等待处理中的请求完成
+func (that *GfApp) Exit() error {
+	return that.HttpApp.Exit()
+}
@@ -0,0 +1,154 @@
+package kapp
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	logger "github.com/moqsien/processes/logger"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+)
+
+/*
+  GrpcApp 运行gRPC服务的App，配置见ServerConfig(apps.[appName]节点)：
+    k.AddAppToExecutor(kapp.NewGrpcApp("rpc", func() (kapp.GrpcServer, kapp.GrpcHealth) {
+        srv := grpc.NewServer()
+        pb.RegisterGreeterServer(srv, &greeter{})
+        return srv, nil
+    }))
+  grpc.Server停止后不能再次启动，因此每次Execute都通过工厂方法创建新的Server；
+  NewServer未返回健康检查服务，且*grpc.Server上未注册grpc.health.v1.Health时，自动注册health.NewServer()；
+  健康检查服务的状态随App的状态变化：Execute开始监听后所有服务为SERVING，Exit时先变为NOT_SERVING，
+  再调用GracefulStop等待处理中的请求完成，超过shutdownTimeout后调用Stop强制结束。
+*/
+
+// GrpcServer *grpc.Server实现了本接口
+type GrpcServer interface {
+	Serve(lis net.Listener) error
+	GracefulStop()
+	Stop()
+}
+
+// GrpcHealth google.golang.org/grpc/health.Server实现了本接口
+type GrpcHealth interface {
+	Resume()   // 所有服务的状态设置为SERVING
+	Shutdown() // 所有服务的状态设置为NOT_SERVING，之后的状态变更都会被忽略，直到Resume
+}
+
+// GrpcApp 运行gRPC服务的App
+type GrpcApp struct {
+	AppBase
+	Name      string                          // App名称
+	Node      string                          // 配置节点，默认为apps.[Name]
+	NewServer func() (GrpcServer, GrpcHealth) // 创建gRPC服务及其健康检查服务，健康检查服务可以为nil
+	lock      sync.Mutex
+	server    GrpcServer
+	health    GrpcHealth
+	stopped   bool // Exit在Execute开始处理请求之前被调用，Execute不再处理请求
+}
+
+// NewGrpcApp 创建GrpcApp，node为可选的配置节点
+func NewGrpcApp(name string, newServer func() (GrpcServer, GrpcHealth), node ...string) *GrpcApp {
+	app := &GrpcApp{Name: name, NewServer: newServer, Node: ConfigNode(name)}
+	if len(node) > 0 && node[0] != "" {
+		app.Node = node[0]
+	}
+	return app
+}
+
+func (that *GrpcApp) AppName() string {
+	return that.Name
+}
+
+// LoadConfig 读取配置
+func (that *GrpcApp) LoadConfig() *ServerConfig {
+	return LoadServerConfig(that.Config(), that.Node, that.Name, ":9090")
+}
+
+// Execute 创建gRPC服务并开始处理请求，直到Exit被调用
+func (that *GrpcApp) Execute() error {
+	if that.NewServer == nil {
+		return gerror.NewCodef(gcode.CodeMissingParameter, "GrpcApp[%s]未设置NewServer", that.Name)
+	}
+	ln, err := that.LoadConfig().Listen("tcp")
+	if err != nil {
+		return err
+	}
+	srv, health := that.NewServer()
+	if srv == nil {
+		_ = ln.Close()
+		return gerror.NewCodef(gcode.CodeInvalidParameter, "GrpcApp[%s]的NewServer返回了空的Server", that.Name)
+	}
+	if health == nil {
+		health = registerHealth(srv)
+	}
+	that.lock.Lock()
+	if that.stopped {
+		that.stopped = false
+		that.lock.Unlock()
+		_ = ln.Close()
+		return nil
+	}
+	that.server, that.health = srv, health
+	that.lock.Unlock()
+	if health != nil {
+		health.Resume()
+	}
+
+	logger.Printf("GrpcApp[%s]开始监听: %s", that.Name, ln.Addr())
+	// GracefulStop或者Stop之后Serve返回nil
+	return srv.Serve(ln)
+}
+
+// Exit 健康检查变为NOT_SERVING，并在shutdownTimeout内等待处理中的请求完成
+func (that *GrpcApp) Exit() error {
+	that.lock.Lock()
+	srv, health := that.server, that.health
+	that.server, that.health = nil, nil
+	// Execute还未开始处理请求时，由Execute在开始前检查并直接返回
+	that.stopped = srv == nil
+	that.lock.Unlock()
+	if srv == nil {
+		return nil
+	}
+	if health != nil {
+		health.Shutdown()
+	}
+	timeout := that.LoadConfig().ShutdownTimeout
+	done := make(chan struct{})
+	go func() {
+		srv.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		srv.Stop()
+		return gerror.NewCodef(gcode.CodeOperationFailed, "GrpcApp[%s]超过%v仍有未处理完的请求，已强制结束", that.Name, timeout)
+	}
+}
+
+// registerHealth 在*grpc.Server上注册健康检查服务，已注册或者不是*grpc.Server时返回nil
+func registerHealth(srv GrpcServer) GrpcHealth {
+	gs, ok := srv.(*grpc.Server)
+	if !ok {
+		return nil
+	}
+	services := gs.GetServiceInfo()
+	if _, registered := services[healthpb.Health_ServiceDesc.ServiceName]; registered {
+		return nil
+	}
+	hs := health.NewServer()
+	healthpb.RegisterHealthServer(gs, hs)
+	// 开始监听前所有服务为NOT_SERVING，Resume时统一变为SERVING
+	for name := range services {
+		hs.SetServingStatus(name, healthpb.HealthCheckResponse_NOT_SERVING)
+	}
+	hs.SetServingStatus("", healthpb.HealthCheckResponse_NOT_SERVING)
+	return hs
+}
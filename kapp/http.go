@@ -0,0 +1,159 @@
+package kapp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+  HttpApp 基于net/http的App，配置示例(apps.[appName]节点，公共配置见ServerConfig)：
+    apps:
+      web:
+        address: ":8080"
+        certFile: /path/to/cert.pem   # certFile和keyFile都设置时使用HTTPS
+        keyFile: /path/to/key.pem
+        readTimeout: 30s
+        readHeaderTimeout: 10s
+        writeTimeout: 30s
+        idleTimeout: 60s
+        maxHeaderBytes: 1048576
+        shutdownTimeout: 10s
+  使用示例：
+    k.AddAppToExecutor(kapp.NewHttpApp("web", mux))
+  Exit时先停止接受新的连接，再等待处理中的请求完成，超过shutdownTimeout后强制关闭所有连接。
+*/
+
+// HttpConfig HttpApp的配置
+type HttpConfig struct {
+	*ServerConfig
+	CertFile          string        // TLS证书
+	KeyFile           string        // TLS私钥
+	ReadTimeout       time.Duration // 读取整个请求的超时时间
+	ReadHeaderTimeout time.Duration // 读取请求头的超时时间
+	WriteTimeout      time.Duration // 写入响应的超时时间
+	IdleTimeout       time.Duration // keep-alive连接的空闲超时时间
+	MaxHeaderBytes    int           // 请求头的最大长度
+}
+
+// HttpApp 基于net/http的App
+type HttpApp struct {
+	AppBase
+	Name    string                 // App名称
+	Node    string                 // 配置节点，默认为apps.[Name]
+	Handler http.Handler           // 处理请求的Handler
+	Setup   func(srv *http.Server) // 启动前对http.Server做额外的设置，例如TLSConfig
+	lock    sync.Mutex
+	server  *http.Server
+	stopped bool // Exit在Execute开始处理请求之前被调用，Execute不再处理请求
+}
+
+// NewHttpApp 创建HttpApp，node为可选的配置节点
+func NewHttpApp(name string, handler http.Handler, node ...string) *HttpApp {
+	app := &HttpApp{Name: name, Handler: handler, Node: ConfigNode(name)}
+	if len(node) > 0 && node[0] != "" {
+		app.Node = node[0]
+	}
+	return app
+}
+
+func (that *HttpApp) AppName() string {
+	return that.Name
+}
+
+// LoadConfig 读取配置
+func (that *HttpApp) LoadConfig() *HttpConfig {
+	cfg, node := that.Config(), that.Node
+	return &HttpConfig{
+		ServerConfig:      LoadServerConfig(cfg, node, that.Name, ":8080"),
+		CertFile:          cfg.GetString(node + ".certFile"),
+		KeyFile:           cfg.GetString(node + ".keyFile"),
+		ReadTimeout:       cfg.GetDuration(node + ".readTimeout"),
+		ReadHeaderTimeout: cfg.GetDuration(node + ".readHeaderTimeout"),
+		WriteTimeout:      cfg.GetDuration(node + ".writeTimeout"),
+		IdleTimeout:       cfg.GetDuration(node + ".idleTimeout"),
+		MaxHeaderBytes:    cfg.GetInt(node + ".maxHeaderBytes"),
+	}
+}
+
+// Server 当前运行中的http.Server，未运行时为nil
+func (that *HttpApp) Server() *http.Server {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	return that.server
+}
+
+// Execute 监听并处理请求，直到Exit被调用
+func (that *HttpApp) Execute() error {
+	if that.Handler == nil {
+		return gerror.NewCodef(gcode.CodeMissingParameter, "HttpApp[%s]未设置Handler", that.Name)
+	}
+	conf := that.LoadConfig()
+	ln, err := conf.Listen("tcp")
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{
+		Handler:           that.Handler,
+		ReadTimeout:       conf.ReadTimeout,
+		ReadHeaderTimeout: conf.ReadHeaderTimeout,
+		WriteTimeout:      conf.WriteTimeout,
+		IdleTimeout:       conf.IdleTimeout,
+		MaxHeaderBytes:    conf.MaxHeaderBytes,
+	}
+	if that.Context != nil {
+		ctx := that.Context
+		srv.BaseContext = func(net.Listener) context.Context { return ctx }
+	}
+	if that.Setup != nil {
+		that.Setup(srv)
+	}
+	that.lock.Lock()
+	if that.stopped {
+		that.stopped = false
+		that.lock.Unlock()
+		_ = ln.Close()
+		return nil
+	}
+	that.server = srv
+	that.lock.Unlock()
+
+	logger.Printf("HttpApp[%s]开始监听: %s", that.Name, ln.Addr())
+	if conf.CertFile != "" && conf.KeyFile != "" {
+		err = srv.ServeTLS(ln, conf.CertFile, conf.KeyFile)
+	} else {
+		err = srv.Serve(ln)
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+// Exit 停止接受新的连接，并在shutdownTimeout内等待处理中的请求完成
+func (that *HttpApp) Exit() error {
+	that.lock.Lock()
+	srv := that.server
+	that.server = nil
+	// Execute还未开始处理请求时，由Execute在开始前检查并直接返回
+	that.stopped = srv == nil
+	that.lock.Unlock()
+	if srv == nil {
+		return nil
+	}
+	timeout := that.LoadConfig().ShutdownTimeout
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := srv.Shutdown(ctx); err != nil {
+		_ = srv.Close()
+		return gerror.WrapCodef(gcode.CodeOperationFailed, err, "HttpApp[%s]超过%v仍有未处理完的请求，已强制关闭", that.Name, timeout)
+	}
+	return nil
+}
@@ -0,0 +1,142 @@
+package kapp
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	"github.com/gogf/gf/os/gcfg"
+)
+
+/*
+  一次性任务：Execute返回nil表示任务完成，App的状态变为Completed，之后不会再被自动启动；
+  返回错误时按照重试策略重试，超过重试次数后App的状态变为Fatal。
+  其他App可以等待任务完成后再启动，例如先执行数据库迁移，再启动API服务，配置示例(apps.[appName]节点)：
+    apps:
+      migrate:
+        retries: 3          # 失败后的最大重试次数，0表示不重试
+        backoff: 1s         # 第一次重试前的等待时间，之后每次翻倍，默认1s
+        maxBackoff: 1m      # 重试等待时间的上限，默认1m
+      api:
+        dependsOn: [migrate] # 启动前需要等待完成的一次性任务，必须与本App属于同一个Executor
+  使用示例：
+    k.AddAppToExecutor(kapp.NewJobApp("migrate", func(ctx context.Context) error {
+        return migrate(ctx)
+    }), "api")
+    k.AddAppToExecutor(kapp.NewHttpApp("api", mux), "api")
+  多进程模式下，副本子进程被重启时不会再次执行本副本已完成的任务；每个副本子进程(包括扩容新增的副本)都会执行一次任务，
+  任务所在的Executor通常只需要1个副本。
+  通过starta、reload命令显式启动已完成的任务时会再次执行。
+*/
+
+// StateCompleted 一次性任务完成后的状态
+const StateCompleted = "Completed"
+
+// JobConfig 一次性任务的重试策略
+type JobConfig struct {
+	Retries    int           // 失败后的最大重试次数
+	Backoff    time.Duration // 第一次重试前的等待时间，之后每次翻倍
+	MaxBackoff time.Duration // 重试等待时间的上限
+}
+
+// IJobApp 一次性任务，Execute返回nil表示任务完成
+type IJobApp interface {
+	IApp
+	Job() *JobConfig // App启动时读取重试策略
+}
+
+// IDependentApp 启动前需要等待同一个Executor中的一次性任务完成的App，也可以通过配置中的dependsOn指定
+type IDependentApp interface {
+	DependsOn() []string
+}
+
+const (
+	DefaultJobBackoff    = time.Second // 一次性任务默认的重试等待时间
+	DefaultJobMaxBackoff = time.Minute // 一次性任务默认的重试等待时间上限
+)
+
+// LoadJobConfig 读取node节点下的重试策略，未配置的项使用def中的值
+func LoadJobConfig(cfg *gcfg.Config, node string, def *JobConfig) *JobConfig {
+	if def == nil {
+		def = &JobConfig{}
+	}
+	conf := &JobConfig{
+		Retries:    cfg.GetInt(node+".retries", def.Retries),
+		Backoff:    cfg.GetDuration(node+".backoff", def.Backoff),
+		MaxBackoff: cfg.GetDuration(node+".maxBackoff", def.MaxBackoff),
+	}
+	if conf.Backoff == 0 {
+		conf.Backoff = DefaultJobBackoff
+	}
+	if conf.MaxBackoff == 0 {
+		conf.MaxBackoff = DefaultJobMaxBackoff
+	}
+	return conf
+}
+
+// Validate 检查重试策略
+func (that *JobConfig) Validate() error {
+	if that.Retries < 0 || that.Backoff < 0 || that.MaxBackoff < 0 {
+		return gerror.NewCode(gcode.CodeInvalidParameter, "retries、backoff和maxBackoff不能为负数")
+	}
+	return nil
+}
+
+// JobApp 执行一次Job的一次性任务
+type JobApp struct {
+	AppBase
+	Name    string                          // App名称
+	Node    string                          // 配置节点，默认为apps.[Name]
+	JobConf *JobConfig                      // 默认的重试策略，可以被配置覆盖
+	Run     func(ctx context.Context) error // 任务内容，ctx在Exit时被取消
+	lock    sync.Mutex
+	cancel  context.CancelFunc
+}
+
+// NewJobApp 创建JobApp，node为可选的配置节点
+func NewJobApp(name string, run func(ctx context.Context) error, node ...string) *JobApp {
+	app := &JobApp{Name: name, Run: run, Node: ConfigNode(name)}
+	if len(node) > 0 && node[0] != "" {
+		app.Node = node[0]
+	}
+	return app
+}
+
+func (that *JobApp) AppName() string {
+	return that.Name
+}
+
+// Job 读取重试策略
+func (that *JobApp) Job() *JobConfig {
+	return LoadJobConfig(that.Config(), that.Node, that.JobConf)
+}
+
+// Execute 执行一次任务
+func (that *JobApp) Execute() error {
+	if that.Run == nil {
+		return gerror.NewCodef(gcode.CodeMissingParameter, "JobApp[%s]未设置Run", that.Name)
+	}
+	parent := that.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	that.lock.Lock()
+	that.cancel = cancel
+	that.lock.Unlock()
+	defer cancel()
+	return that.Run(ctx)
+}
+
+// Exit 取消正在执行的任务
+func (that *JobApp) Exit() error {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	if that.cancel != nil {
+		that.cancel()
+		that.cancel = nil
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package kapp
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/gogf/gf/os/gcfg"
+	kgrace "github.com/moqsien/gokeeper/kgrace"
+	ktype "github.com/moqsien/gokeeper/ktype"
+)
+
+/*
+  服务类App(HTTP、gRPC、TCP、UDP等)的公共配置，位于配置文件的apps.[appName]节点下：
+    apps:
+      web:
+        address: ":8080"        # 监听地址
+        listener: web           # 继承的监听套接字名称，默认为App名称，见kgrace
+        shutdownTimeout: 10s    # Exit时等待连接处理完成的最长时间
+  监听套接字优先从keeper继承(systemd socket activation或者主进程传来的)，没有继承到时才自己监听address。
+*/
+
+// DefaultShutdownTimeout 服务类App默认的结束超时时间，需要小于ktype.MinShutdownTimeout
+const DefaultShutdownTimeout = 10 * time.Second
+
+// ServerConfig 服务类App的公共配置
+type ServerConfig struct {
+	Address         string        // 监听地址
+	Listener        string        // 继承的监听套接字名称
+	ShutdownTimeout time.Duration // Exit时等待连接处理完成的最长时间
+}
+
+// Config App使用的配置，未通过Executor添加时使用默认配置
+func (that *AppBase) Config() *gcfg.Config {
+	if that.AppConfig != nil && that.AppConfig.Config != nil {
+		return that.AppConfig.Config
+	}
+	return gcfg.Instance()
+}
+
+// ConfigNode App的专属配置节点
+func ConfigNode(appName string) string {
+	return fmt.Sprintf("%s.%s", ktype.ConfigNodeNameApps, appName)
+}
+
+// LoadServerConfig 读取node节点下服务类App的公共配置
+func LoadServerConfig(cfg *gcfg.Config, node, appName, defaultAddress string) *ServerConfig {
+	conf := &ServerConfig{
+		Address:         cfg.GetString(node+".address", defaultAddress),
+		Listener:        cfg.GetString(node+".listener", appName),
+		ShutdownTimeout: cfg.GetDuration(node+".shutdownTimeout", DefaultShutdownTimeout),
+	}
+	if conf.ShutdownTimeout <= 0 {
+		conf.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	return conf
+}
+
+// Listen 获取流式监听套接字，优先使用继承的套接字
+func (that *ServerConfig) Listen(network string) (net.Listener, error) {
+	return kgrace.Listen(that.Listener, network, that.Address)
+}
+
+// ListenPacket 获取数据报套接字，优先使用继承的套接字
+func (that *ServerConfig) ListenPacket(network string) (net.PacketConn, error) {
+	return kgrace.ListenPacket(that.Listener, network, that.Address)
+}
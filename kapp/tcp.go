@@ -0,0 +1,233 @@
+package kapp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+  TcpApp 处理TCP连接的App，配置示例(apps.[appName]节点，公共配置见ServerConfig)：
+    apps:
+      echo:
+        address: ":7000"
+        maxConnections: 10000   # 同时处理的最大连接数，超过时新的连接会被直接关闭，0表示不限制
+        shutdownTimeout: 10s
+  使用示例：
+    k.AddAppToExecutor(kapp.NewTcpApp("echo", func(ctx context.Context, conn net.Conn) {
+        io.Copy(conn, conn)
+    }))
+  每个连接在单独的goroutine中处理，Handler返回后连接会被关闭；
+  Exit时先关闭监听套接字并取消传给Handler的ctx，再等待所有连接处理完成，超过shutdownTimeout后强制关闭剩余的连接。
+  长连接协议的Handler应当在ctx取消后处理完当前请求就返回。
+*/
+
+// TcpHandler 处理一个TCP连接，ctx在App结束时被取消
+type TcpHandler func(ctx context.Context, conn net.Conn)
+
+// TcpConfig TcpApp的配置
+type TcpConfig struct {
+	*ServerConfig
+	MaxConnections int // 同时处理的最大连接数，0表示不限制
+}
+
+// TcpApp 处理TCP连接的App
+type TcpApp struct {
+	AppBase
+	Name    string     // App名称
+	Node    string     // 配置节点，默认为apps.[Name]
+	Handler TcpHandler // 处理连接的Handler
+	lock    sync.Mutex
+	serving *tcpServing
+	stopped bool // Exit在Execute开始接受连接之前被调用，Execute不再接受连接
+	failed  bool // Execute因监听出错已经返回，之后的Exit不再标记stopped
+}
+
+// tcpServing 一次Execute中的监听套接字和连接，reload时上一次的连接可能还未处理完
+type tcpServing struct {
+	ln     net.Listener
+	cancel context.CancelFunc
+	lock   sync.Mutex
+	conns  map[net.Conn]struct{}
+	wg     sync.WaitGroup
+}
+
+// NewTcpApp 创建TcpApp，node为可选的配置节点
+func NewTcpApp(name string, handler TcpHandler, node ...string) *TcpApp {
+	app := &TcpApp{Name: name, Handler: handler, Node: ConfigNode(name)}
+	if len(node) > 0 && node[0] != "" {
+		app.Node = node[0]
+	}
+	return app
+}
+
+func (that *TcpApp) AppName() string {
+	return that.Name
+}
+
+// LoadConfig 读取配置
+func (that *TcpApp) LoadConfig() *TcpConfig {
+	cfg, node := that.Config(), that.Node
+	return &TcpConfig{
+		ServerConfig:   LoadServerConfig(cfg, node, that.Name, ""),
+		MaxConnections: cfg.GetInt(node + ".maxConnections"),
+	}
+}
+
+// Conns 当前正在处理的连接数
+func (that *TcpApp) Conns() int {
+	that.lock.Lock()
+	serving := that.serving
+	that.lock.Unlock()
+	if serving == nil {
+		return 0
+	}
+	return serving.size()
+}
+
+// Execute 监听并处理连接，直到Exit被调用
+func (that *TcpApp) Execute() error {
+	if that.Handler == nil {
+		return gerror.NewCodef(gcode.CodeMissingParameter, "TcpApp[%s]未设置Handler", that.Name)
+	}
+	conf := that.LoadConfig()
+	ln, err := conf.Listen("tcp")
+	if err != nil {
+		return err
+	}
+	parent := that.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	serving := &tcpServing{ln: ln, cancel: cancel, conns: make(map[net.Conn]struct{})}
+	that.lock.Lock()
+	if that.stopped {
+		that.stopped = false
+		that.lock.Unlock()
+		cancel()
+		_ = ln.Close()
+		return nil
+	}
+	that.serving = serving
+	that.failed = false
+	that.lock.Unlock()
+
+	logger.Printf("TcpApp[%s]开始监听: %s", that.Name, ln.Addr())
+	var delay time.Duration // 临时错误时的重试间隔，与net/http相同
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			if ne, ok := err.(net.Error); ok && ne.Temporary() {
+				if delay == 0 {
+					delay = 5 * time.Millisecond
+				} else if delay *= 2; delay > time.Second {
+					delay = time.Second
+				}
+				logger.Warningf("TcpApp[%s]接受连接失败: %v, %v后重试", that.Name, err, delay)
+				time.Sleep(delay)
+				continue
+			}
+			that.fail(serving)
+			return err
+		}
+		delay = 0
+		if !serving.track(conn, conf.MaxConnections) {
+			logger.Warningf("TcpApp[%s]连接数超过%d，关闭来自%s的连接", that.Name, conf.MaxConnections, conn.RemoteAddr())
+			_ = conn.Close()
+			continue
+		}
+		go func() {
+			defer serving.untrack(conn)
+			that.Handler(ctx, conn)
+		}()
+	}
+}
+
+// fail 监听出错时关闭监听套接字并取消ctx，已建立的连接由Handler处理完后关闭
+func (that *TcpApp) fail(serving *tcpServing) {
+	that.lock.Lock()
+	if that.serving == serving {
+		that.serving = nil
+		that.failed = true
+	}
+	that.lock.Unlock()
+	_ = serving.ln.Close()
+	serving.cancel()
+}
+
+// track 记录连接，超过最大连接数时返回false
+func (that *tcpServing) track(conn net.Conn, max int) bool {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	if max > 0 && len(that.conns) >= max {
+		return false
+	}
+	that.conns[conn] = struct{}{}
+	that.wg.Add(1)
+	return true
+}
+
+// untrack 关闭连接并删除记录
+func (that *tcpServing) untrack(conn net.Conn) {
+	_ = conn.Close()
+	that.lock.Lock()
+	delete(that.conns, conn)
+	that.lock.Unlock()
+	that.wg.Done()
+}
+
+func (that *tcpServing) size() int {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	return len(that.conns)
+}
+
+// closeAll 强制关闭所有连接，返回关闭的连接数
+func (that *tcpServing) closeAll() int {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	for conn := range that.conns {
+		_ = conn.Close()
+	}
+	return len(that.conns)
+}
+
+// Exit 停止接受新的连接，并在shutdownTimeout内等待所有连接处理完成
+func (that *TcpApp) Exit() error {
+	that.lock.Lock()
+	serving := that.serving
+	that.serving = nil
+	// Execute还未开始接受连接时，由Execute在开始前检查并直接返回
+	that.stopped = serving == nil && !that.failed
+	that.failed = false
+	that.lock.Unlock()
+	if serving == nil {
+		return nil
+	}
+	_ = serving.ln.Close()
+	serving.cancel()
+
+	timeout := that.LoadConfig().ShutdownTimeout
+	done := make(chan struct{})
+	go func() {
+		serving.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+	}
+	remain := serving.closeAll()
+	return gerror.NewCodef(gcode.CodeOperationFailed, "TcpApp[%s]超过%v仍有%d个连接未处理完，已强制关闭", that.Name, timeout, remain)
+}
@@ -0,0 +1,75 @@
+package kapp
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gogf/gf/os/gcfg"
+)
+
+// waitTcpServing 等待Execute开始接受连接
+func waitTcpServing(t *testing.T, app *TcpApp) *tcpServing {
+	deadline := time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		app.lock.Lock()
+		serving := app.serving
+		app.lock.Unlock()
+		if serving != nil {
+			return serving
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("TcpApp did not start serving")
+	return nil
+}
+
+func TestTcpAppExecuteAfterFailure(t *testing.T) {
+	const file = "tcp_test.json"
+	gcfg.SetContent(`{"apps": {"echo": {"address": "127.0.0.1:0", "shutdownTimeout": "1s"}}}`, file)
+	t.Cleanup(func() { gcfg.RemoveContent(file) })
+	app := NewTcpApp("echo", func(ctx context.Context, conn net.Conn) {})
+	app.AppConfig = &AppConfig{Config: gcfg.New(file)}
+
+	done := execute(app)
+	serving := waitTcpServing(t, app)
+	addr := serving.ln.Addr().String()
+	// 模拟Accept返回不可恢复的错误
+	app.fail(serving)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Execute did not return after failure")
+	}
+	if conn, err := net.Dial("tcp", addr); err == nil {
+		_ = conn.Close()
+		t.Fatal("listener still open after failure")
+	}
+	if app.Conns() != 0 || app.serving != nil {
+		t.Fatal("serving not cleared after failure")
+	}
+
+	// 失败后的Exit不能让下一次Execute直接返回
+	if err := app.Exit(); err != nil {
+		t.Fatal(err)
+	}
+	done = execute(app)
+	serving = waitTcpServing(t, app)
+	conn, err := net.Dial("tcp", serving.ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.Close()
+	if err := app.Exit(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Execute did not return after Exit")
+	}
+}
@@ -0,0 +1,226 @@
+package kapp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+  UdpApp 处理UDP数据报的App，配置示例(apps.[appName]节点，公共配置见ServerConfig)：
+    apps:
+      collector:
+        address: ":8125"
+        workers: 8            # 处理数据报的goroutine数量，默认为CPU核数
+        queueSize: 1024       # 已读取、等待处理的数据报队列长度，默认为workers*64
+        bufferSize: 65535     # 读取数据报的缓冲区大小，超过的部分会被截断
+        shutdownTimeout: 10s
+  使用示例：
+    k.AddAppToExecutor(kapp.NewUdpApp("collector", func(ctx context.Context, conn net.PacketConn, data []byte, addr net.Addr) {
+        conn.WriteTo(data, addr)
+    }))
+  一个goroutine读取数据报后放入队列，由workers个goroutine并发处理，队列满时暂停读取；
+  Exit时停止读取，在shutdownTimeout内处理完队列中的数据报后再关闭套接字，Handler在此期间仍然可以回复。
+*/
+
+// UdpHandler 处理一个数据报，conn用于回复；data在Handler返回后会被复用，需要保留时请复制
+type UdpHandler func(ctx context.Context, conn net.PacketConn, data []byte, addr net.Addr)
+
+// UdpConfig UdpApp的配置
+type UdpConfig struct {
+	*ServerConfig
+	Workers    int // 处理数据报的goroutine数量
+	QueueSize  int // 等待处理的数据报队列长度
+	BufferSize int // 读取数据报的缓冲区大小
+}
+
+// UdpApp 处理UDP数据报的App
+type UdpApp struct {
+	AppBase
+	Name    string     // App名称
+	Node    string     // 配置节点，默认为apps.[Name]
+	Handler UdpHandler // 处理数据报的Handler
+	lock    sync.Mutex
+	serving *udpServing
+	stopped bool // Exit在Execute开始接收数据报之前被调用，Execute不再接收数据报
+	failed  bool // Execute因读取出错已经返回，之后的Exit不再标记stopped
+}
+
+// udpServing 一次Execute中的套接字和处理数据报的goroutine
+type udpServing struct {
+	conn    net.PacketConn
+	cancel  context.CancelFunc
+	stopped chan struct{} // Exit开始时关闭
+	workers sync.WaitGroup
+}
+
+// udpPacket 等待处理的数据报
+type udpPacket struct {
+	data []byte
+	addr net.Addr
+}
+
+// NewUdpApp 创建UdpApp，node为可选的配置节点
+func NewUdpApp(name string, handler UdpHandler, node ...string) *UdpApp {
+	app := &UdpApp{Name: name, Handler: handler, Node: ConfigNode(name)}
+	if len(node) > 0 && node[0] != "" {
+		app.Node = node[0]
+	}
+	return app
+}
+
+func (that *UdpApp) AppName() string {
+	return that.Name
+}
+
+// LoadConfig 读取配置
+func (that *UdpApp) LoadConfig() *UdpConfig {
+	cfg, node := that.Config(), that.Node
+	conf := &UdpConfig{
+		ServerConfig: LoadServerConfig(cfg, node, that.Name, ""),
+		Workers:      cfg.GetInt(node+".workers", runtime.NumCPU()),
+		QueueSize:    cfg.GetInt(node + ".queueSize"),
+		BufferSize:   cfg.GetInt(node+".bufferSize", 65535),
+	}
+	if conf.Workers <= 0 {
+		conf.Workers = runtime.NumCPU()
+	}
+	if conf.QueueSize <= 0 {
+		conf.QueueSize = conf.Workers * 64
+	}
+	if conf.BufferSize <= 0 {
+		conf.BufferSize = 65535
+	}
+	return conf
+}
+
+// Execute 读取并处理数据报，直到Exit被调用
+func (that *UdpApp) Execute() error {
+	if that.Handler == nil {
+		return gerror.NewCodef(gcode.CodeMissingParameter, "UdpApp[%s]未设置Handler", that.Name)
+	}
+	conf := that.LoadConfig()
+	conn, err := conf.ListenPacket("udp")
+	if err != nil {
+		return err
+	}
+	parent := that.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	ctx, cancel := context.WithCancel(parent)
+	serving := &udpServing{conn: conn, cancel: cancel, stopped: make(chan struct{})}
+	that.lock.Lock()
+	if that.stopped {
+		that.stopped = false
+		that.lock.Unlock()
+		cancel()
+		_ = conn.Close()
+		return nil
+	}
+	that.serving = serving
+	that.failed = false
+	that.lock.Unlock()
+
+	queue := make(chan *udpPacket, conf.QueueSize)
+	pool := &sync.Pool{New: func() interface{} { return make([]byte, conf.BufferSize) }}
+	for i := 0; i < conf.Workers; i++ {
+		serving.workers.Add(1)
+		go func() {
+			defer serving.workers.Done()
+			for p := range queue {
+				that.Handler(ctx, conn, p.data, p.addr)
+				pool.Put(p.data[:cap(p.data)])
+			}
+		}()
+	}
+	defer close(queue)
+
+	logger.Printf("UdpApp[%s]开始监听: %s", that.Name, conn.LocalAddr())
+	for {
+		buf := pool.Get().([]byte)
+		n, addr, err := conn.ReadFrom(buf)
+		if n > 0 {
+			// 读取出错时也可能已经读到了数据，先处理数据
+			select {
+			case queue <- &udpPacket{data: buf[:n], addr: addr}:
+			case <-serving.stopped:
+				return nil
+			}
+		} else {
+			pool.Put(buf)
+		}
+		if err == nil {
+			continue
+		}
+		select {
+		case <-serving.stopped:
+			return nil
+		default:
+		}
+		if errors.Is(err, net.ErrClosed) {
+			return nil
+		}
+		if ne, ok := err.(net.Error); ok && ne.Temporary() {
+			logger.Warningf("UdpApp[%s]读取数据报失败: %v", that.Name, err)
+			continue
+		}
+		that.fail(serving)
+		return err
+	}
+}
+
+// fail 读取出错时关闭套接字并取消ctx，workers在队列关闭后退出
+func (that *UdpApp) fail(serving *udpServing) {
+	that.lock.Lock()
+	if that.serving == serving {
+		that.serving = nil
+		that.failed = true
+	}
+	that.lock.Unlock()
+	serving.cancel()
+	_ = serving.conn.Close()
+}
+
+// Exit 停止读取，并在shutdownTimeout内处理完已读取的数据报
+func (that *UdpApp) Exit() error {
+	that.lock.Lock()
+	serving := that.serving
+	that.serving = nil
+	// Execute还未开始接收数据报时，由Execute在开始前检查并直接返回
+	that.stopped = serving == nil && !that.failed
+	that.failed = false
+	that.lock.Unlock()
+	if serving == nil {
+		return nil
+	}
+	close(serving.stopped)
+	// 通过读超时让ReadFrom返回，处理完队列前不关闭套接字，Handler仍然可以回复
+	if err := serving.conn.SetReadDeadline(time.Now()); err != nil {
+		_ = serving.conn.Close()
+	}
+	defer func() {
+		serving.cancel()
+		_ = serving.conn.Close()
+	}()
+
+	timeout := that.LoadConfig().ShutdownTimeout
+	done := make(chan struct{})
+	go func() {
+		serving.workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(timeout):
+		return gerror.NewCodef(gcode.CodeOperationFailed, "UdpApp[%s]超过%v仍有数据报未处理完，已强制结束", that.Name, timeout)
+	}
+}
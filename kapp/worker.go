@@ -0,0 +1,350 @@
+package kapp
+
+import (
+	"context"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+  WorkerApp 从队列等来源获取任务并发处理的App，配置示例(apps.[appName]节点)：
+    apps:
+      consumer:
+        concurrency: 8          # 并发处理任务的worker数，默认为CPU核数
+        itemTimeout: 30s        # 单个任务的处理超时时间，超时后取消传给Handler的ctx，0表示不限制
+        idleWait: 1s            # Fetch没有取到任务或者出错后，等待多久再次Fetch
+        shutdownTimeout: 10s    # Exit时等待正在处理的任务完成的最长时间
+  使用示例：
+    k.AddAppToExecutor(kapp.NewWorkerApp("consumer", func(ctx context.Context) (interface{}, error) {
+        return queue.Pop(ctx)
+    }, func(ctx context.Context, item interface{}) error {
+        return handle(ctx, item.(*Message))
+    }))
+  每个worker依次调用Fetch和Handler；Fetch可以阻塞等待任务，ctx取消时应尽快返回，返回nil表示暂时没有任务；
+  Handler中的panic只影响当前任务，记录为失败后worker继续处理下一个任务；Fetch中的panic按获取任务出错处理，等待idleWait后重试。
+  并发数可以通过交互式shell的resize命令在运行时调整，调整后的值在进程退出前一直有效(包括reload之后)；
+  减少worker时，被减少的worker处理完当前任务后退出。
+  Exit时先停止Fetch，再等待正在处理的任务完成，超过shutdownTimeout后取消传给Handler的ctx。
+  配置了itemTimeout时，任务超过itemTimeout的两倍仍未结束，说明Handler卡住，多进程模式下子进程停止发送心跳，由主进程处理。
+*/
+
+// WorkerFetch 获取一个任务，ctx在worker停止时被取消
+type WorkerFetch func(ctx context.Context) (item interface{}, err error)
+
+// WorkerHandler 处理一个任务，ctx在任务超时或者App结束超时时被取消
+type WorkerHandler func(ctx context.Context, item interface{}) error
+
+// WorkerConfig WorkerApp的配置
+type WorkerConfig struct {
+	Concurrency     int           // 并发处理任务的worker数
+	ItemTimeout     time.Duration // 单个任务的处理超时时间，0表示不限制
+	IdleWait        time.Duration // Fetch没有取到任务或者出错后的等待时间
+	ShutdownTimeout time.Duration // Exit时等待正在处理的任务完成的最长时间
+}
+
+// WorkerStats WorkerApp的运行情况
+type WorkerStats struct {
+	Concurrency int   // 当前的worker数
+	InFlight    int64 // 正在处理的任务数
+	Processed   int64 // 处理成功的任务数
+	Failed      int64 // 处理失败(包括panic和超时)的任务数
+}
+
+// IWorkerApp 可以在运行时调整并发数的App
+type IWorkerApp interface {
+	IApp
+	Resize(n int) error  // 调整并发数
+	Stats() *WorkerStats // 获取运行情况
+}
+
+// DefaultWorkerIdleWait WorkerApp默认的空闲等待时间
+const DefaultWorkerIdleWait = time.Second
+
+// WorkerApp 并发处理任务的App
+type WorkerApp struct {
+	AppBase
+	Name        string        // App名称
+	Node        string        // 配置节点，默认为apps.[Name]
+	Fetch       WorkerFetch   // 获取任务
+	Handler     WorkerHandler // 处理任务
+	lock        sync.Mutex
+	pool        *workerPool
+	stopped     bool // Exit在Execute启动worker之前被调用，Execute不再启动worker
+	concurrency int  // 通过Resize调整后的并发数，0表示使用配置
+	stats       WorkerStats
+}
+
+// workerPool 一次Execute中的worker，reload时上一次的任务可能还未处理完
+type workerPool struct {
+	app        *WorkerApp
+	conf       *WorkerConfig
+	fetchCtx   context.Context // Exit开始时取消，所有worker停止Fetch
+	stopFetch  context.CancelFunc
+	workCtx    context.Context // 传给Handler的ctx的父ctx，Exit超时后取消
+	cancelWork context.CancelFunc
+	lock       sync.Mutex
+	workers    []context.CancelFunc // 每个worker的停止函数
+	wg         sync.WaitGroup
+	seq        int64
+	busy       sync.Map      // 正在处理的任务的开始时间，key: 任务序号，value: time.Time
+	stopped    chan struct{} // Exit开始时关闭
+}
+
+// NewWorkerApp 创建WorkerApp，node为可选的配置节点
+func NewWorkerApp(name string, fetch WorkerFetch, handler WorkerHandler, node ...string) *WorkerApp {
+	app := &WorkerApp{Name: name, Fetch: fetch, Handler: handler, Node: ConfigNode(name)}
+	if len(node) > 0 && node[0] != "" {
+		app.Node = node[0]
+	}
+	return app
+}
+
+func (that *WorkerApp) AppName() string {
+	return that.Name
+}
+
+// LoadConfig 读取配置，通过Resize调整过的并发数优先
+func (that *WorkerApp) LoadConfig() *WorkerConfig {
+	cfg, node := that.Config(), that.Node
+	conf := &WorkerConfig{
+		Concurrency:     cfg.GetInt(node+".concurrency", runtime.NumCPU()),
+		ItemTimeout:     cfg.GetDuration(node + ".itemTimeout"),
+		IdleWait:        cfg.GetDuration(node+".idleWait", DefaultWorkerIdleWait),
+		ShutdownTimeout: cfg.GetDuration(node+".shutdownTimeout", DefaultShutdownTimeout),
+	}
+	that.lock.Lock()
+	if that.concurrency > 0 {
+		conf.Concurrency = that.concurrency
+	}
+	that.lock.Unlock()
+	if conf.Concurrency <= 0 {
+		conf.Concurrency = runtime.NumCPU()
+	}
+	if conf.IdleWait <= 0 {
+		conf.IdleWait = DefaultWorkerIdleWait
+	}
+	if conf.ShutdownTimeout <= 0 {
+		conf.ShutdownTimeout = DefaultShutdownTimeout
+	}
+	return conf
+}
+
+// Execute 启动worker，直到Exit被调用
+func (that *WorkerApp) Execute() error {
+	if that.Fetch == nil || that.Handler == nil {
+		return gerror.NewCodef(gcode.CodeMissingParameter, "WorkerApp[%s]未设置Fetch或Handler", that.Name)
+	}
+	conf := that.LoadConfig()
+	parent := that.Context
+	if parent == nil {
+		parent = context.Background()
+	}
+	pool := &workerPool{app: that, conf: conf, stopped: make(chan struct{})}
+	pool.fetchCtx, pool.stopFetch = context.WithCancel(parent)
+	pool.workCtx, pool.cancelWork = context.WithCancel(parent)
+	that.lock.Lock()
+	if that.stopped {
+		that.stopped = false
+		that.lock.Unlock()
+		pool.stopFetch()
+		pool.cancelWork()
+		return nil
+	}
+	that.pool = pool
+	that.lock.Unlock()
+
+	pool.resize(conf.Concurrency)
+	logger.Printf("WorkerApp[%s]启动%d个worker", that.Name, conf.Concurrency)
+	<-pool.stopped
+	return nil
+}
+
+// Resize 调整并发数，App未运行时在下一次启动时生效
+func (that *WorkerApp) Resize(n int) error {
+	if n < 1 {
+		return gerror.NewCodef(gcode.CodeInvalidParameter, "WorkerApp[%s]的并发数不能小于1: %d", that.Name, n)
+	}
+	that.lock.Lock()
+	that.concurrency = n
+	pool := that.pool
+	that.lock.Unlock()
+	if pool != nil {
+		pool.resize(n)
+		logger.Printf("WorkerApp[%s]的并发数调整为%d", that.Name, n)
+	}
+	return nil
+}
+
+// Stats 获取运行情况，处理的任务数从App创建开始累计
+func (that *WorkerApp) Stats() *WorkerStats {
+	that.lock.Lock()
+	stats := that.stats
+	pool := that.pool
+	that.lock.Unlock()
+	if pool != nil {
+		stats.Concurrency = pool.size()
+	}
+	return &stats
+}
+
+/*
+Alive 实现ILivenessApp，配置了itemTimeout时，任务超过itemTimeout的两倍仍未处理完，
+说明Handler没有响应ctx的取消而卡住，返回错误。
+*/
+func (that *WorkerApp) Alive(ctx context.Context) error {
+	that.lock.Lock()
+	pool := that.pool
+	that.lock.Unlock()
+	if pool == nil || pool.conf.ItemTimeout <= 0 {
+		return nil
+	}
+	var err error
+	pool.busy.Range(func(_, v interface{}) bool {
+		if elapsed := time.Since(v.(time.Time)); elapsed > 2*pool.conf.ItemTimeout {
+			err = gerror.NewCodef(gcode.CodeOperationFailed, "WorkerApp[%s]有任务已处理%v仍未结束", that.Name, elapsed.Truncate(time.Second))
+			return false
+		}
+		return true
+	})
+	return err
+}
+
+// Exit 停止获取任务，并在shutdownTimeout内等待正在处理的任务完成
+func (that *WorkerApp) Exit() error {
+	that.lock.Lock()
+	pool := that.pool
+	that.pool = nil
+	// Execute还未启动worker时，由Execute在启动前检查并直接返回
+	that.stopped = pool == nil
+	that.lock.Unlock()
+	if pool == nil {
+		return nil
+	}
+	pool.stopFetch()
+	// 在pool.lock内关闭，保证之后的resize不会再调用wg.Add
+	pool.lock.Lock()
+	close(pool.stopped)
+	pool.lock.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		pool.wg.Wait()
+		close(done)
+	}()
+	defer pool.cancelWork()
+	select {
+	case <-done:
+		return nil
+	case <-time.After(pool.conf.ShutdownTimeout):
+	}
+	return gerror.NewCodef(gcode.CodeOperationFailed, "WorkerApp[%s]超过%v仍有%d个任务未处理完，已取消", that.Name, pool.conf.ShutdownTimeout, that.Stats().InFlight)
+}
+
+// count 更新任务数
+func (that *WorkerApp) count(inFlight, processed, failed int64) {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	that.stats.InFlight += inFlight
+	that.stats.Processed += processed
+	that.stats.Failed += failed
+}
+
+// resize 增加或者减少worker
+func (that *workerPool) resize(n int) {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	select {
+	case <-that.stopped:
+		return
+	default:
+	}
+	for len(that.workers) < n {
+		ctx, cancel := context.WithCancel(that.fetchCtx)
+		that.workers = append(that.workers, cancel)
+		that.wg.Add(1)
+		go that.work(ctx)
+	}
+	for len(that.workers) > n {
+		last := len(that.workers) - 1
+		that.workers[last]()
+		that.workers = that.workers[:last]
+	}
+}
+
+func (that *workerPool) size() int {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	return len(that.workers)
+}
+
+// work 依次获取并处理任务，ctx取消后处理完当前任务再退出
+func (that *workerPool) work(ctx context.Context) {
+	defer that.wg.Done()
+	name := that.app.Name
+	for ctx.Err() == nil {
+		item, err := that.safeFetch(ctx)
+		if err == nil && item != nil {
+			that.handle(item)
+			continue
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Warningf("WorkerApp[%s]获取任务失败，%v后重试: %v", name, that.conf.IdleWait, err)
+		}
+		timer := time.NewTimer(that.conf.IdleWait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// handle 处理一个任务，并记录结果
+func (that *workerPool) handle(item interface{}) {
+	ctx, cancel := that.workCtx, context.CancelFunc(func() {})
+	if that.conf.ItemTimeout > 0 {
+		ctx, cancel = context.WithTimeout(that.workCtx, that.conf.ItemTimeout)
+	}
+	defer cancel()
+	id := atomic.AddInt64(&that.seq, 1)
+	that.busy.Store(id, time.Now())
+	defer that.busy.Delete(id)
+	that.app.count(1, 0, 0)
+	if err := that.safeHandle(ctx, item); err != nil {
+		that.app.count(-1, 0, 1)
+		logger.Warningf("WorkerApp[%s]处理任务失败: %v", that.app.Name, err)
+		return
+	}
+	that.app.count(-1, 1, 0)
+}
+
+// safeFetch 调用Fetch，将panic转换为错误，worker等待idleWait后重试
+func (that *workerPool) safeFetch(ctx context.Context) (item interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			item, err = nil, gerror.NewCodef(gcode.CodeInternalError, "panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return that.app.Fetch(ctx)
+}
+
+// safeHandle 调用Handler，将panic转换为错误，避免影响其他任务
+func (that *workerPool) safeHandle(ctx context.Context, item interface{}) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = gerror.NewCodef(gcode.CodeInternalError, "panic: %v\n%s", r, debug.Stack())
+		}
+	}()
+	return that.app.Handler(ctx, item)
+}
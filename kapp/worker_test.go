@@ -0,0 +1,88 @@
+package kapp
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gogf/gf/os/gcfg"
+)
+
+// newTestWorkerApp 创建每次Fetch都返回一个任务的WorkerApp，fetched记录Fetch的调用次数
+func newTestWorkerApp(t *testing.T, fetched *int64) *WorkerApp {
+	const file = "worker_test.json"
+	gcfg.SetContent(`{"apps": {"worker": {"concurrency": 2, "idleWait": "10ms"}}}`, file)
+	t.Cleanup(func() { gcfg.RemoveContent(file) })
+	app := NewWorkerApp("worker", func(ctx context.Context) (interface{}, error) {
+		atomic.AddInt64(fetched, 1)
+		return 1, nil
+	}, func(ctx context.Context, item interface{}) error {
+		time.Sleep(time.Millisecond)
+		return nil
+	})
+	app.AppConfig = &AppConfig{Config: gcfg.New(file)}
+	return app
+}
+
+// execute 在goroutine中运行Execute，返回Execute的结果
+func execute(app IApp) <-chan error {
+	done := make(chan error, 1)
+	go func() { done <- app.Execute() }()
+	return done
+}
+
+func TestWorkerAppExit(t *testing.T) {
+	var fetched int64
+	app := newTestWorkerApp(t, &fetched)
+	done := execute(app)
+	for app.Stats().Processed == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if n := app.Stats().Concurrency; n != 2 {
+		t.Fatalf("concurrency = %d, want 2", n)
+	}
+	if err := app.Exit(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Execute did not return after Exit")
+	}
+}
+
+func TestWorkerAppExitBeforeExecute(t *testing.T) {
+	var fetched int64
+	app := newTestWorkerApp(t, &fetched)
+	if err := app.Exit(); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-execute(app):
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(time.Second):
+		_ = app.Exit()
+		t.Fatal("Execute started workers after Exit")
+	}
+	if n := atomic.LoadInt64(&fetched); n != 0 {
+		t.Fatalf("Fetch called %d times", n)
+	}
+
+	// 只跳过Exit之后的一次Execute
+	done := execute(app)
+	for app.Stats().Processed == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if err := app.Exit(); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+}
@@ -18,6 +18,9 @@ type ICommand interface {
 	IKStartCmd
 	IkStopCmd
 	IkVersionCmd
+	IkScaleCmd
+	IkStatusCmd
+	IkInstallUnitCmd
 }
 
 var RootCmd = &cobra.Command{
@@ -36,4 +39,7 @@ func InitCliCmd(kPtr ICommand) {
 	InitReloadCmd(kPtr)
 	InitRQuitCmd(kPtr)
 	InitVersionCmd(kPtr)
+	InitScaleCmd(kPtr)
+	InitStatusCmd(kPtr)
+	InitInstallUnitCmd(kPtr)
 }
@@ -0,0 +1,34 @@
+package kcli
+
+import "github.com/spf13/cobra"
+
+type IkInstallUnitCmd interface {
+	InstallUnit(output string)
+}
+
+var installUnitCmd = &cobra.Command{
+	Use:   "install-unit",
+	Short: "generate a systemd unit file",
+	Long:  "generate a systemd unit file from the current config, the keeper runs as a Type=notify service",
+}
+
+func InitInstallUnitCmd(keeper ICommand) {
+	installUnitCmd.Run = func(c *cobra.Command, args []string) {
+		if kconfig, err := c.Flags().GetString("config"); err == nil {
+			keeper.ParseConfig(kconfig)
+		}
+		if kenv, err := c.Flags().GetString("env"); err == nil {
+			keeper.ParseEnv(kenv)
+		}
+		if kpid, err := c.Flags().GetString("pid"); err == nil {
+			keeper.ParsePidFilePath(kpid)
+		}
+		output, _ := c.Flags().GetString("output")
+		keeper.InstallUnit(output)
+	}
+	installUnitCmd.Flags().StringP("config", "c", "", "服务启动时载入的配置文件，同时从中读取systemd节点的配置")
+	installUnitCmd.Flags().StringP("env", "e", "", "服务启动时所在的环境,有[dev,test,product]这三种")
+	installUnitCmd.Flags().StringP("pid", "p", "", "服务的pid文件地址，默认是/tmp/[keeperName].pid")
+	installUnitCmd.Flags().StringP("output", "o", "", "unit文件的写入地址，默认是/etc/systemd/system/[keeperName].service，为-时输出到标准输出")
+	keeper.AddCommand(installUnitCmd)
+}
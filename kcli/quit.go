@@ -16,6 +16,6 @@ func InitRQuitCmd(keeper ICommand) {
 		}
 		keeper.StopKeeper("quit")
 	}
-	stopCmd.Flags().StringVarP(&pid, "pid", "p", "", "设置pid文件的地址，默认是/tmp/[keeperName].pid")
+	quitCmd.Flags().StringVarP(&pid, "pid", "p", "", "设置pid文件的地址，默认是/tmp/[keeperName].pid")
 	keeper.AddCommand(quitCmd)
 }
@@ -16,6 +16,6 @@ func InitReloadCmd(keeper ICommand) {
 		}
 		keeper.StopKeeper("reload")
 	}
-	stopCmd.Flags().StringVarP(&pid, "pid", "p", "", "设置pid文件的地址，默认是/tmp/[keeperName].pid")
+	reloadCmd.Flags().StringVarP(&pid, "pid", "p", "", "设置pid文件的地址，默认是/tmp/[keeperName].pid")
 	keeper.AddCommand(reloadCmd)
 }
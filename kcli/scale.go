@@ -0,0 +1,27 @@
+package kcli
+
+import (
+	"github.com/gogf/gf/util/gconv"
+	"github.com/spf13/cobra"
+)
+
+type IkScaleCmd interface {
+	ScaleKeeper(execName string, replicas int)
+}
+
+var scaleCmd = &cobra.Command{
+	Use:   "scale -e executor N",
+	Short: "scale replicas of an executor",
+	Long:  "scale replicas of an executor, only available in multi process mode",
+	Args:  cobra.ExactArgs(1),
+}
+
+func InitScaleCmd(keeper ICommand) {
+	scaleCmd.Run = func(c *cobra.Command, args []string) {
+		execName, _ := c.Flags().GetString("executor")
+		keeper.ScaleKeeper(execName, gconv.Int(args[0]))
+	}
+	scaleCmd.Flags().StringP("executor", "e", "", "需要调整副本数的Executor名称")
+	_ = scaleCmd.MarkFlagRequired("executor")
+	keeper.AddCommand(scaleCmd)
+}
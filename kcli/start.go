@@ -43,7 +43,7 @@ func InitStartCmd(keeper ICommand) {
 	startCmd.Flags().StringVarP(&executor, "executor", "x", "", "设置子进程需要启动的Executor名称，默认为空")
 	startCmd.Flags().StringVarP(&pid, "pid", "p", "", "设置pid文件的地址，默认是/tmp/[keeperName].pid")
 	startCmd.Flags().BoolVarP(&deamon, "deamon", "d", false, "使用守护进程模式启动")
-	startCmd.Flags().BoolVarP(&debug, "debug", "d", false, "是否开启debug 默认debug=true")
+	startCmd.Flags().BoolVar(&debug, "debug", false, "是否开启debug 默认debug=true")
 	startCmd.Flags().IntVarP(&mode, "mode", "m", 0, "进程模型，0表示单进程模型，1表示多进程模型")
 	startCmd.Run = func(c *cobra.Command, args []string) {
 		/*
@@ -0,0 +1,24 @@
+package kcli
+
+import "github.com/spf13/cobra"
+
+type IkStatusCmd interface {
+	ShowStatus()
+}
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "show keeper status",
+	Long:  "show keeper status from the state file, available even if the ctrl socket is not",
+}
+
+func InitStatusCmd(keeper ICommand) {
+	statusCmd.Run = func(c *cobra.Command, args []string) {
+		if pid, err := c.Flags().GetString("pid"); err == nil {
+			keeper.ParsePidFilePath(pid)
+		}
+		keeper.ShowStatus()
+	}
+	statusCmd.Flags().StringVarP(&pid, "pid", "p", "", "设置pid文件的地址，默认是/tmp/[keeperName].pid")
+	keeper.AddCommand(statusCmd)
+}
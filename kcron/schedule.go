@@ -0,0 +1,261 @@
+package kcron
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+)
+
+/*
+  定时App的执行计划，支持以下格式：
+    - cron表达式：5位(分 时 日 月 周)或6位(秒 分 时 日 月 周)，每一位支持 *、?、a、a-b、x/n(步长，x为*、a或a-b) 以及逗号分隔的列表，
+      月和周可以使用英文缩写，如JAN、MON；周的0和7都表示周日；日和周都不是*时，满足其中之一即可；
+    - 描述符：@yearly(@annually)、@monthly、@weekly、@daily(@midnight)、@hourly；
+    - 固定间隔：@every 10m 或者直接写 10m，从上一次计划执行的时间开始计算。
+  时间按照本地时区计算。
+*/
+
+// Schedule 执行计划
+type Schedule interface {
+	// Next 返回t之后的下一次执行时间，没有下一次时返回零值
+	Next(t time.Time) time.Time
+}
+
+// Every 固定间隔的执行计划
+type Every time.Duration
+
+func (that Every) Next(t time.Time) time.Time {
+	return t.Add(time.Duration(that))
+}
+
+// cronSchedule cron表达式对应的执行计划，每一位用一个位集合表示
+type cronSchedule struct {
+	second, minute, hour, dom, month, dow uint64
+	domStar, dowStar                      bool // 日、周是否为*，决定两者的组合方式
+}
+
+// field cron表达式中一位的取值范围
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	secondField = field{name: "second", min: 0, max: 59}
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+// descriptors 描述符对应的6位cron表达式
+var descriptors = map[string]string{
+	"@yearly":   "0 0 0 1 1 *",
+	"@annually": "0 0 0 1 1 *",
+	"@monthly":  "0 0 0 1 * *",
+	"@weekly":   "0 0 0 * * 0",
+	"@daily":    "0 0 0 * * *",
+	"@midnight": "0 0 0 * * *",
+	"@hourly":   "0 0 * * * *",
+}
+
+// Parse 解析执行计划
+func Parse(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return nil, gerror.NewCode(gcode.CodeInvalidParameter, "执行计划为空")
+	}
+	if strings.HasPrefix(spec, "@every") {
+		return parseEvery(spec, strings.TrimSpace(strings.TrimPrefix(spec, "@every")))
+	}
+	if d, err := time.ParseDuration(spec); err == nil {
+		return parseEvery(spec, d.String())
+	}
+	if v, ok := descriptors[strings.ToLower(spec)]; ok {
+		spec = v
+	}
+	fields := strings.Fields(spec)
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, gerror.NewCodef(gcode.CodeInvalidParameter, "cron表达式需要5位或6位: %s", spec)
+	}
+	s := &cronSchedule{}
+	var err error
+	if s.second, err = parseField(fields[0], secondField); err != nil {
+		return nil, err
+	}
+	if s.minute, err = parseField(fields[1], minuteField); err != nil {
+		return nil, err
+	}
+	if s.hour, err = parseField(fields[2], hourField); err != nil {
+		return nil, err
+	}
+	if s.dom, err = parseField(fields[3], domField); err != nil {
+		return nil, err
+	}
+	if s.month, err = parseField(fields[4], monthField); err != nil {
+		return nil, err
+	}
+	if s.dow, err = parseField(fields[5], dowField); err != nil {
+		return nil, err
+	}
+	// 周日可以写作0或7
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[3] == "*" || fields[3] == "?"
+	s.dowStar = fields[5] == "*" || fields[5] == "?"
+	return s, nil
+}
+
+// parseEvery 解析固定间隔
+func parseEvery(spec, value string) (Schedule, error) {
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		return nil, gerror.NewCodef(gcode.CodeInvalidParameter, "固定间隔格式错误: %s", spec)
+	}
+	return Every(d), nil
+}
+
+// parseField 解析cron表达式中的一位，返回取值的位集合
+func parseField(expr string, f field) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(expr, ",") {
+		rangePart, step := item, 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n <= 0 {
+				return 0, gerror.NewCodef(gcode.CodeInvalidParameter, "%s的步长格式错误: %s", f.name, item)
+			}
+			rangePart, step = item[:i], n
+		}
+		start, end := f.min, f.max
+		switch {
+		case rangePart == "*" || rangePart == "?":
+			if f.max == 7 {
+				end = 6 // 周的*不需要重复包含7
+			}
+		case strings.Contains(rangePart, "-"):
+			parts := strings.SplitN(rangePart, "-", 2)
+			var err error
+			if start, err = f.value(parts[0]); err != nil {
+				return 0, err
+			}
+			if end, err = f.value(parts[1]); err != nil {
+				return 0, err
+			}
+		default:
+			var err error
+			if start, err = f.value(rangePart); err != nil {
+				return 0, err
+			}
+			// a/n 表示从a开始到最大值，单独的a只包含a
+			if step == 1 {
+				end = start
+			}
+		}
+		if start > end {
+			return 0, gerror.NewCodef(gcode.CodeInvalidParameter, "%s的范围错误: %s", f.name, item)
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// value 解析一个取值，支持英文缩写
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, gerror.NewCodef(gcode.CodeInvalidParameter, "%s的取值需要在%d-%d之间: %s", f.name, f.min, f.max, s)
+	}
+	return v, nil
+}
+
+/*
+Next 逐级匹配月、日、时、分、秒，最多向后查找5年；
+时、分、秒按照绝对时间递增，夏令时开始时跳过的时间不会执行，结束时重复的时间只执行一次。
+*/
+func (that *cronSchedule) Next(t time.Time) time.Time {
+	next := that.next(t)
+	// 夏令时结束时本地时间回退，回退后与t相同或者更早的本地时间已经执行过
+	for !next.IsZero() && !wallClock(next).After(wallClock(t)) {
+		next = that.next(next)
+	}
+	return next
+}
+
+func (that *cronSchedule) next(t time.Time) time.Time {
+	loc := t.Location()
+	t = t.Add(time.Second - time.Duration(t.Nanosecond()))
+	yearLimit := t.Year() + 5
+
+WRAP:
+	if t.Year() > yearLimit {
+		return time.Time{}
+	}
+	for that.month&(1<<uint(t.Month())) == 0 {
+		t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+		if t.Month() == time.January {
+			goto WRAP
+		}
+	}
+	for !that.dayMatches(t) {
+		t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+		if t.Day() == 1 {
+			goto WRAP
+		}
+	}
+	// 本地时间在夏令时开始时不连续，time.Date可能回到更早的时间，因此按照绝对时间递增
+	for that.hour&(1<<uint(t.Hour())) == 0 {
+		t = t.Add(time.Hour - time.Duration(t.Minute())*time.Minute - time.Duration(t.Second())*time.Second)
+		if t.Hour() == 0 {
+			goto WRAP
+		}
+	}
+	for that.minute&(1<<uint(t.Minute())) == 0 {
+		t = t.Add(time.Minute - time.Duration(t.Second())*time.Second)
+		if t.Minute() == 0 {
+			goto WRAP
+		}
+	}
+	for that.second&(1<<uint(t.Second())) == 0 {
+		t = t.Add(time.Second)
+		if t.Second() == 0 {
+			goto WRAP
+		}
+	}
+	return t
+}
+
+// wallClock 本地时间的年月日时分秒，用于比较夏令时前后的本地时间
+func wallClock(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), t.Second(), 0, time.UTC)
+}
+
+// dayMatches 日和周都有限制时满足其一即可，否则需要同时满足
+func (that *cronSchedule) dayMatches(t time.Time) bool {
+	dom := that.dom&(1<<uint(t.Day())) != 0
+	dow := that.dow&(1<<uint(t.Weekday())) != 0
+	if that.domStar || that.dowStar {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,171 @@
+package kcron
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata" // 夏令时用例需要时区数据
+)
+
+func TestParseError(t *testing.T) {
+	cases := []struct {
+		name string
+		spec string
+	}{
+		{"empty", ""},
+		{"too few fields", "* * * *"},
+		{"too many fields", "* * * * * * *"},
+		{"second out of range", "60 * * * * *"},
+		{"minute out of range", "60 * * * *"},
+		{"hour out of range", "* 24 * * *"},
+		{"day of month zero", "* * 0 * *"},
+		{"month out of range", "* * * 13 *"},
+		{"day of week out of range", "* * * * 8"},
+		{"unknown name", "* * * foo *"},
+		{"reversed range", "* 10-5 * * *"},
+		{"zero step", "*/0 * * * *"},
+		{"bad step", "*/x * * * *"},
+		{"bad every", "@every abc"},
+		{"negative every", "@every -1m"},
+		{"zero duration", "0s"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if _, err := Parse(c.spec); err == nil {
+				t.Fatalf("Parse(%q) should fail", c.spec)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	at := func(s string) time.Time {
+		v, err := time.ParseInLocation("2006-01-02 15:04:05", s, time.UTC)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+	// 2024-01-01是周一
+	cases := []struct {
+		name string
+		spec string
+		from string
+		want string
+	}{
+		{"every minute", "* * * * *", "2024-01-01 10:00:00", "2024-01-01 10:01:00"},
+		{"truncates fractional seconds", "* * * * * *", "2024-01-01 10:00:00.5", "2024-01-01 10:00:01"},
+		{"fixed time later today", "30 12 * * *", "2024-01-01 10:00:00", "2024-01-01 12:30:00"},
+		{"fixed time tomorrow", "30 9 * * *", "2024-01-01 10:00:00", "2024-01-02 09:30:00"},
+		{"six fields", "15 30 12 * * *", "2024-01-01 10:00:00", "2024-01-01 12:30:15"},
+		{"minute range", "10-12 * * * *", "2024-01-01 10:11:00", "2024-01-01 10:12:00"},
+		{"range wraps to next hour", "10-12 * * * *", "2024-01-01 10:12:00", "2024-01-01 11:10:00"},
+		{"list", "5,40 * * * *", "2024-01-01 10:06:00", "2024-01-01 10:40:00"},
+		{"step from star", "*/15 * * * *", "2024-01-01 10:16:00", "2024-01-01 10:30:00"},
+		{"step from value", "5/20 * * * *", "2024-01-01 10:26:00", "2024-01-01 10:45:00"},
+		{"step in range", "0 8-18/4 * * *", "2024-01-01 12:00:00", "2024-01-01 16:00:00"},
+		{"month name", "0 0 1 mar *", "2024-01-01 10:00:00", "2024-03-01 00:00:00"},
+		{"weekday name", "0 9 * * fri", "2024-01-01 10:00:00", "2024-01-05 09:00:00"},
+		{"weekday range", "0 9 * * mon-wed", "2024-01-03 10:00:00", "2024-01-08 09:00:00"},
+		{"sunday as 7", "0 0 * * 7", "2024-01-01 10:00:00", "2024-01-07 00:00:00"},
+		{"sunday as 0", "0 0 * * 0", "2024-01-01 10:00:00", "2024-01-07 00:00:00"},
+		{"day of month or weekday", "0 0 15 * fri", "2024-01-06 00:00:00", "2024-01-12 00:00:00"},
+		{"day of month and star weekday", "0 0 31 * *", "2024-01-31 00:00:00", "2024-03-31 00:00:00"},
+		{"leap day", "0 0 29 2 *", "2024-03-01 00:00:00", "2028-02-29 00:00:00"},
+		{"question mark", "0 0 1 * ?", "2024-01-01 10:00:00", "2024-02-01 00:00:00"},
+		{"yearly", "@yearly", "2024-06-01 00:00:00", "2025-01-01 00:00:00"},
+		{"monthly", "@monthly", "2024-01-15 00:00:00", "2024-02-01 00:00:00"},
+		{"weekly", "@weekly", "2024-01-01 10:00:00", "2024-01-07 00:00:00"},
+		{"daily", "@daily", "2024-01-01 10:00:00", "2024-01-02 00:00:00"},
+		{"hourly", "@hourly", "2024-01-01 10:20:00", "2024-01-01 11:00:00"},
+		{"every", "@every 90s", "2024-01-01 10:00:00", "2024-01-01 10:01:30"},
+		{"plain duration", "10m", "2024-01-01 10:00:00", "2024-01-01 10:10:00"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, err := Parse(c.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", c.spec, err)
+			}
+			if got := s.Next(at(c.from)); !got.Equal(at(c.want)) {
+				t.Fatalf("Next(%s) = %s, want %s", c.from, got, c.want)
+			}
+		})
+	}
+}
+
+func TestNextNoMatch(t *testing.T) {
+	s, err := Parse("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Next(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Fatalf("Next = %s, want zero", got)
+	}
+}
+
+func TestEveryCountsFromPlannedTime(t *testing.T) {
+	s, err := Parse("@every 10m")
+	if err != nil {
+		t.Fatal(err)
+	}
+	planned := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	for i := 1; i <= 3; i++ {
+		planned = s.Next(planned)
+		if want := time.Date(2024, 1, 1, 10, 10*i, 0, 0, time.UTC); !planned.Equal(want) {
+			t.Fatalf("run %d planned at %s, want %s", i, planned, want)
+		}
+	}
+}
+
+func TestNextHalfHourOffset(t *testing.T) {
+	loc, err := time.LoadLocation("Asia/Kolkata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := Parse("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	from, want := time.Date(2024, 1, 1, 7, 45, 0, 0, loc), time.Date(2024, 1, 1, 9, 0, 0, 0, loc)
+	if got := s.Next(from); !got.Equal(want) {
+		t.Fatalf("Next(%s) = %s, want %s", from, got, want)
+	}
+}
+
+func TestNextDST(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 2024-03-10 02:00 跳到 03:00，2024-11-03 02:00 回到 01:00
+	cases := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"skipped hour runs next day", "30 2 * * *",
+			time.Date(2024, 3, 10, 0, 0, 0, 0, loc), time.Date(2024, 3, 11, 2, 30, 0, 0, loc)},
+		{"hour after gap", "0 3 * * *",
+			time.Date(2024, 3, 10, 0, 0, 0, 0, loc), time.Date(2024, 3, 10, 3, 0, 0, 0, loc)},
+		{"hourly across gap", "0 * * * *",
+			time.Date(2024, 3, 10, 1, 30, 0, 0, loc), time.Date(2024, 3, 10, 3, 0, 0, 0, loc)},
+		{"daily after fall back", "0 0 * * *",
+			time.Date(2024, 11, 3, 0, 0, 0, 0, loc), time.Date(2024, 11, 4, 0, 0, 0, 0, loc)},
+		{"hourly skips repeated hour", "0 * * * *",
+			time.Date(2024, 11, 3, 1, 30, 0, 0, loc), time.Date(2024, 11, 3, 2, 0, 0, 0, loc)},
+		{"repeated hour runs once", "30 1 * * *",
+			time.Date(2024, 11, 3, 1, 30, 0, 0, loc), time.Date(2024, 11, 4, 1, 30, 0, 0, loc)},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			s, err := Parse(c.spec)
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", c.spec, err)
+			}
+			if got := s.Next(c.from); !got.Equal(c.want) {
+				t.Fatalf("Next(%s) = %s, want %s", c.from, got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,401 @@
+package keeper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/gogf/gf/errors/gerror"
+	"github.com/gogf/gf/os/gfile"
+	"github.com/gogf/gf/util/gconv"
+	ktype "github.com/moqsien/gokeeper/ktype"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+  管理接口：主进程中可选开启的HTTP服务，以JSON格式提供与交互式shell相同的控制功能，便于部署工具调用；
+  同时在/metrics上以Prometheus文本格式提供监控指标(见k_metrics.go)；
+  配置示例：
+    admin:
+      enable: true
+      address: "unix:/var/run/keeper.admin.sock" # 或者 "127.0.0.1:9527"，默认为运行目录下的admin.sock
+*/
+
+const (
+	adminUnixPrefix = "unix:"   // 监听地址为unix套接字时的前缀
+	adminPathPrefix = "/api/v1" // 管理接口的路径前缀
+)
+
+// AdminRequest 管理接口的请求参数
+type AdminRequest struct {
+	Apps        []string               `json:"apps,omitempty"`        // 需要操作的App列表
+	Replicas    int                    `json:"replicas,omitempty"`    // 副本数
+	Executor    string                 `json:"executor,omitempty"`    // Executor名称
+	Level       string                 `json:"level,omitempty"`       // 日志级别
+	Concurrency int                    `json:"concurrency,omitempty"` // WorkerApp的并发数
+	Type        string                 `json:"type,omitempty"`        // 添加App时使用的App工厂类型名称
+	Config      map[string]interface{} `json:"config,omitempty"`      // 添加App时写入apps.[app]节点的配置
+	Target      string                 `json:"target,omitempty"`      // 移动App时的目标Executor
+}
+
+// AdminResponse 管理接口的返回结果，Code为0表示成功，否则与HTTP状态码相同
+type AdminResponse struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// adminHandler 管理接口的处理方法，args为路径中Executor名称之后的部分
+type adminHandler func(r *http.Request, req *AdminRequest, args []string) (interface{}, error)
+
+// adminError 带有HTTP状态码的错误
+type adminError struct {
+	code int
+	msg  string
+}
+
+func (that *adminError) Error() string {
+	return that.msg
+}
+
+func newAdminError(code int, format string, v ...interface{}) error {
+	return &adminError{code: code, msg: fmt.Sprintf(format, v...)}
+}
+
+// AdminEnabled 是否开启管理接口
+func (that *Keeper) AdminEnabled() bool {
+	return that.KConfig != nil && that.KConfig.GetBool("admin.enable")
+}
+
+// AdminAddress 管理接口的监听地址
+func (that *Keeper) AdminAddress() string {
+	if that.KConfig != nil {
+		if address := that.KConfig.GetString("admin.address"); address != "" {
+			return address
+		}
+	}
+	return adminUnixPrefix + filepath.Join(that.RunDir(), "admin.sock")
+}
+
+// adminListen 根据监听地址创建Listener；TCP地址只允许监听本地回环地址
+func (that *Keeper) adminListen(address string) (net.Listener, error) {
+	if strings.HasPrefix(address, adminUnixPrefix) {
+		path := strings.TrimPrefix(address, adminUnixPrefix)
+		if err := gfile.Mkdir(filepath.Dir(path)); err != nil {
+			return nil, err
+		}
+		_ = os.Remove(path) // 上次运行残留的套接字文件
+		ln, err := net.Listen("unix", path)
+		if err == nil {
+			_ = os.Chmod(path, 0600)
+		}
+		return ln, err
+	}
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, gerror.Newf("admin address %s is not a loopback address", address)
+	}
+	return net.Listen("tcp", address)
+}
+
+// RunAdmin 主进程中开启管理接口
+func (that *Keeper) RunAdmin() {
+	if !that.AdminEnabled() || !that.IsMaster() {
+		return
+	}
+	address := that.AdminAddress()
+	ln, err := that.adminListen(address)
+	if err != nil {
+		logger.Errorf("管理接口监听[%s]失败: %v", address, err)
+		return
+	}
+	handler := that.authHandler(that.AdminMux(), AuditSourceAdmin, adminCommand, func(w http.ResponseWriter, err error) {
+		writeAdminResponse(w, http.StatusForbidden, &AdminResponse{Code: http.StatusForbidden, Message: err.Error()})
+	})
+	that.adminServer = &http.Server{Handler: handler, ConnContext: connContext}
+	go func() {
+		if err := that.adminServer.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Errorf("管理接口运行出错: %v", err)
+		}
+	}()
+	logger.Printf("管理接口已开启: %s", address)
+}
+
+// StopAdmin 关闭管理接口
+func (that *Keeper) StopAdmin() {
+	if that.adminServer == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ktype.MinShutdownTimeout)
+	defer cancel()
+	_ = that.adminServer.Shutdown(ctx)
+	if address := that.AdminAddress(); strings.HasPrefix(address, adminUnixPrefix) {
+		_ = os.Remove(strings.TrimPrefix(address, adminUnixPrefix))
+	}
+}
+
+// AdminMux 管理接口的路由
+func (that *Keeper) AdminMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc(adminPathPrefix+"/openapi.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(adminOpenAPI))
+	})
+	mux.HandleFunc("/metrics", that.adminMetrics)
+	mux.HandleFunc(adminPathPrefix+"/keeper", that.adminRoute(map[string]adminHandler{
+		http.MethodGet: that.adminKeeper,
+	}))
+	mux.HandleFunc(adminPathPrefix+"/config", that.adminRoute(map[string]adminHandler{
+		http.MethodGet: that.adminConfig,
+	}))
+	mux.HandleFunc(adminPathPrefix+"/log", that.adminRoute(map[string]adminHandler{
+		http.MethodPut: that.adminLog,
+	}))
+	mux.HandleFunc(adminPathPrefix+"/audit", that.adminRoute(map[string]adminHandler{
+		http.MethodGet: that.adminAudit,
+	}))
+	mux.HandleFunc(adminPathPrefix+"/executors", that.adminRoute(map[string]adminHandler{
+		http.MethodGet: that.adminExecutors,
+	}))
+	mux.HandleFunc(adminPathPrefix+"/executors/", that.adminRoute(map[string]adminHandler{
+		http.MethodGet:  that.adminExecutorGet,
+		http.MethodPost: that.adminExecutorAction,
+	}))
+	return mux
+}
+
+// adminRoute 解析请求参数，按请求方法分发，并将结果以JSON格式返回
+func (that *Keeper) adminRoute(handlers map[string]adminHandler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var (
+			data interface{}
+			err  error
+			req  = &AdminRequest{}
+		)
+		handler, ok := handlers[r.Method]
+		if !ok {
+			err = newAdminError(http.StatusMethodNotAllowed, "method %s not allowed", r.Method)
+		} else if r.ContentLength != 0 && r.Body != nil && (r.Method == http.MethodPost || r.Method == http.MethodPut) {
+			if e := json.NewDecoder(r.Body).Decode(req); e != nil {
+				err = newAdminError(http.StatusBadRequest, "invalid request body: %v", e)
+			}
+		}
+		if err == nil {
+			args := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, adminPathPrefix+"/executors"), "/"), "/")
+			data, err = handler(r, req, args)
+		}
+		resp := &AdminResponse{Message: "ok", Data: data}
+		status := http.StatusOK
+		if err != nil {
+			status = ctrlStatus(err)
+			if e, ok := err.(*adminError); ok {
+				status = e.code
+			}
+			resp.Code, resp.Message, resp.Data = status, err.Error(), nil
+		}
+		writeAdminResponse(w, status, resp)
+	}
+}
+
+// writeAdminResponse 以JSON格式返回结果
+func writeAdminResponse(w http.ResponseWriter, status int, resp *AdminResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// adminCommand 管理接口请求对应的交互式shell命令名称，用于授权
+func adminCommand(r *http.Request) string {
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, adminPathPrefix), "/")
+	switch {
+	case r.Method == http.MethodGet && path == "config":
+		return "config"
+	case r.Method == http.MethodGet && path == "audit":
+		return "audit"
+	case r.Method == http.MethodGet && r.URL.Path == "/metrics":
+		return "metrics"
+	case r.Method == http.MethodGet && path == "openapi.json":
+		return "version"
+	case r.Method == http.MethodGet:
+		return "info"
+	case path == "log":
+		return "log"
+	}
+	// executors/{name}/{action}
+	if args := strings.SplitN(path, "/", 3); len(args) == 3 {
+		switch args[2] {
+		case "start":
+			return "starte"
+		case "stop":
+			return "stope"
+		case "apps/start":
+			return "starta"
+		case "apps/stop":
+			return "stopa"
+		case "apps/trigger":
+			return "trigger"
+		case "apps/resize":
+			return "resize"
+		case "apps/add":
+			return "adda"
+		case "apps/remove":
+			return "removea"
+		case "apps/move":
+			return "movea"
+		}
+		return args[2]
+	}
+	return path
+}
+
+// GET /keeper
+func (that *Keeper) adminKeeper(_ *http.Request, _ *AdminRequest, _ []string) (interface{}, error) {
+	return that.CollectState(), nil
+}
+
+// GET /config，只读角色也可以访问，因此去掉auth节点并隐藏敏感配置
+func (that *Keeper) adminConfig(_ *http.Request, _ *AdminRequest, _ []string) (interface{}, error) {
+	return redactConfig(that.KConfig.Map(), true), nil
+}
+
+// 名称中包含以下关键字的配置项视为敏感配置，如token、密码、请求头和环境变量
+var secretConfigKeys = []string{"token", "password", "passwd", "secret", "credential", "apikey", "privatekey", "dsn", "headers", "environment"}
+
+// redactedValue 敏感配置项被替换成的值
+const redactedValue = "******"
+
+// redactConfig 复制配置并隐藏其中的敏感配置项；top为true时去掉顶层的auth节点
+func redactConfig(m map[string]interface{}, top bool) map[string]interface{} {
+	result := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		if top && strings.EqualFold(k, "auth") {
+			continue
+		}
+		if isSecretConfigKey(k) {
+			result[k] = redactedValue
+			continue
+		}
+		result[k] = redactConfigValue(v)
+	}
+	return result
+}
+
+// redactConfigValue 递归隐藏配置值中的敏感配置项
+func redactConfigValue(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		return redactConfig(value, false)
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			list[i] = redactConfigValue(item)
+		}
+		return list
+	}
+	return v
+}
+
+// isSecretConfigKey 判断配置项名称是否为敏感配置，忽略大小写以及"_"和"-"
+func isSecretConfigKey(key string) bool {
+	key = strings.NewReplacer("_", "", "-", "").Replace(strings.ToLower(key))
+	for _, s := range secretConfigKeys {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// PUT /log
+func (that *Keeper) adminLog(_ *http.Request, req *AdminRequest, _ []string) (interface{}, error) {
+	if req.Level == "" {
+		return nil, newAdminError(http.StatusBadRequest, "level is required")
+	}
+	return that.SetLogLevel(req.Executor, req.Level)
+}
+
+// GET /audit?n=20
+func (that *Keeper) adminAudit(r *http.Request, _ *AdminRequest, _ []string) (interface{}, error) {
+	return that.ReadAudit(gconv.Int(r.URL.Query().Get("n")))
+}
+
+// GET /executors
+func (that *Keeper) adminExecutors(_ *http.Request, _ *AdminRequest, _ []string) (interface{}, error) {
+	return that.CollectState().Executors, nil
+}
+
+// GET /executors/{name}，GET /executors/{name}/apps
+func (that *Keeper) adminExecutorGet(_ *http.Request, _ *AdminRequest, args []string) (interface{}, error) {
+	ex, err := that.searchExecutor(args[0])
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case len(args) == 1:
+		for _, es := range that.CollectState().Executors {
+			if es.Name == ex.Name {
+				return es, nil
+			}
+		}
+		return nil, newAdminError(http.StatusNotFound, "executor %s is not found", ex.Name)
+	case len(args) == 2 && args[1] == "apps":
+		return that.executorStatus(ex), nil
+	}
+	return nil, newAdminError(http.StatusNotFound, "path %s is not found", strings.Join(args, "/"))
+}
+
+// POST /executors/{name}/{start|stop|reload|scale}，POST /executors/{name}/apps/{start|stop|trigger|resize|add|remove|move}
+func (that *Keeper) adminExecutorAction(_ *http.Request, req *AdminRequest, args []string) (interface{}, error) {
+	ex, err := that.searchExecutor(args[0])
+	if err != nil {
+		return nil, err
+	}
+	action := strings.Join(args[1:], "/")
+	switch action {
+	case "start":
+		return that.StartExecutor(ex.Name, req.Apps...)
+	case "stop":
+		return that.StopExecutor(ex.Name)
+	case "reload":
+		return that.ReloadApps(ex.Name, req.Apps...)
+	case "scale":
+		if req.Replicas < 1 {
+			return nil, newAdminError(http.StatusBadRequest, "replicas must be at least 1")
+		}
+		return that.ScaleExecutor(ex.Name, req.Replicas)
+	case "apps/add":
+		if len(req.Apps) != 1 || req.Type == "" {
+			return nil, newAdminError(http.StatusBadRequest, "one app and type are required")
+		}
+		return that.AddApp(ex.Name, req.Type, req.Apps[0], req.Config)
+	case "apps/start", "apps/stop", "apps/trigger", "apps/resize", "apps/remove", "apps/move":
+		if len(req.Apps) == 0 {
+			return nil, newAdminError(http.StatusBadRequest, "apps are required")
+		}
+		switch action {
+		case "apps/start":
+			return that.StartApps(ex.Name, req.Apps...)
+		case "apps/trigger":
+			return that.TriggerApps(ex.Name, req.Apps...)
+		case "apps/resize":
+			if req.Concurrency < 1 {
+				return nil, newAdminError(http.StatusBadRequest, "concurrency must be at least 1")
+			}
+			return that.ResizeApps(ex.Name, req.Concurrency, req.Apps...)
+		case "apps/remove":
+			return that.RemoveApps(ex.Name, req.Apps...)
+		case "apps/move":
+			return that.MoveApps(ex.Name, req.Target, req.Apps...)
+		}
+		return that.StopApps(ex.Name, req.Apps...)
+	}
+	return nil, newAdminError(http.StatusNotFound, "action %s is not found", action)
+}
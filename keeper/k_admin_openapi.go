@@ -0,0 +1,243 @@
+package keeper
+
+// adminOpenAPI 管理接口的OpenAPI描述，通过 GET /api/v1/openapi.json 获取
+const adminOpenAPI = `{
+  "openapi": "3.0.3",
+  "info": {
+    "title": "gokeeper admin api",
+    "description": "JSON control API of a running keeper, equivalent to the commands of the ctrl shell. Prometheus metrics are served in text format on GET /metrics, outside of /api/v1.",
+    "version": "v1"
+  },
+  "servers": [{"url": "/api/v1"}],
+  "paths": {
+    "/keeper": {
+      "get": {
+        "summary": "Keeper info, including executors, replicas and apps.",
+        "responses": {"200": {"$ref": "#/components/responses/Result"}}
+      }
+    },
+    "/config": {
+      "get": {
+        "summary": "Config loaded by the keeper, without the auth node; tokens, passwords, headers and other secrets are masked.",
+        "responses": {"200": {"$ref": "#/components/responses/Result"}}
+      }
+    },
+    "/log": {
+      "put": {
+        "summary": "Set log level of the keeper, or of one executor if executor is given.",
+        "requestBody": {"$ref": "#/components/requestBodies/Request"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Result"},
+          "400": {"$ref": "#/components/responses/Result"},
+          "404": {"$ref": "#/components/responses/Result"}
+        }
+      }
+    },
+    "/audit": {
+      "get": {
+        "summary": "Recent entries of the audit log.",
+        "parameters": [{"name": "n", "in": "query", "required": false, "schema": {"type": "integer"}, "description": "number of entries, all if not given."}],
+        "responses": {"200": {"$ref": "#/components/responses/Result"}}
+      }
+    },
+    "/executors": {
+      "get": {
+        "summary": "List executors.",
+        "responses": {"200": {"$ref": "#/components/responses/Result"}}
+      }
+    },
+    "/executors/{executor}": {
+      "parameters": [{"$ref": "#/components/parameters/Executor"}],
+      "get": {
+        "summary": "Executor info.",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Result"},
+          "404": {"$ref": "#/components/responses/Result"}
+        }
+      }
+    },
+    "/executors/{executor}/apps": {
+      "parameters": [{"$ref": "#/components/parameters/Executor"}],
+      "get": {
+        "summary": "App states of every replica of the executor.",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Result"},
+          "404": {"$ref": "#/components/responses/Result"}
+        }
+      }
+    },
+    "/executors/{executor}/start": {
+      "parameters": [{"$ref": "#/components/parameters/Executor"}],
+      "post": {
+        "summary": "Start the executor, optionally only the given apps.",
+        "requestBody": {"$ref": "#/components/requestBodies/Request"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Result"},
+          "404": {"$ref": "#/components/responses/Result"}
+        }
+      }
+    },
+    "/executors/{executor}/stop": {
+      "parameters": [{"$ref": "#/components/parameters/Executor"}],
+      "post": {
+        "summary": "Stop the executor.",
+        "responses": {
+          "200": {"$ref": "#/components/responses/Result"},
+          "404": {"$ref": "#/components/responses/Result"}
+        }
+      }
+    },
+    "/executors/{executor}/reload": {
+      "parameters": [{"$ref": "#/components/parameters/Executor"}],
+      "post": {
+        "summary": "Reload the given apps, or all running apps if none is given.",
+        "requestBody": {"$ref": "#/components/requestBodies/Request"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Result"},
+          "404": {"$ref": "#/components/responses/Result"}
+        }
+      }
+    },
+    "/executors/{executor}/scale": {
+      "parameters": [{"$ref": "#/components/parameters/Executor"}],
+      "post": {
+        "summary": "Scale replicas of the executor.",
+        "requestBody": {"$ref": "#/components/requestBodies/Request"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Result"},
+          "400": {"$ref": "#/components/responses/Result"},
+          "404": {"$ref": "#/components/responses/Result"}
+        }
+      }
+    },
+    "/executors/{executor}/apps/start": {
+      "parameters": [{"$ref": "#/components/parameters/Executor"}],
+      "post": {
+        "summary": "Start apps of the executor.",
+        "requestBody": {"$ref": "#/components/requestBodies/Request"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Result"},
+          "400": {"$ref": "#/components/responses/Result"},
+          "404": {"$ref": "#/components/responses/Result"}
+        }
+      }
+    },
+    "/executors/{executor}/apps/stop": {
+      "parameters": [{"$ref": "#/components/parameters/Executor"}],
+      "post": {
+        "summary": "Stop apps of the executor.",
+        "requestBody": {"$ref": "#/components/requestBodies/Request"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Result"},
+          "400": {"$ref": "#/components/responses/Result"},
+          "404": {"$ref": "#/components/responses/Result"}
+        }
+      }
+    },
+    "/executors/{executor}/apps/trigger": {
+      "parameters": [{"$ref": "#/components/parameters/Executor"}],
+      "post": {
+        "summary": "Run scheduled apps of the executor immediately.",
+        "requestBody": {"$ref": "#/components/requestBodies/Request"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Result"},
+          "400": {"$ref": "#/components/responses/Result"},
+          "404": {"$ref": "#/components/responses/Result"}
+        }
+      }
+    },
+    "/executors/{executor}/apps/resize": {
+      "parameters": [{"$ref": "#/components/parameters/Executor"}],
+      "post": {
+        "summary": "Resize concurrency of worker apps of the executor, apps and concurrency are required.",
+        "requestBody": {"$ref": "#/components/requestBodies/Request"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Result"},
+          "400": {"$ref": "#/components/responses/Result"},
+          "404": {"$ref": "#/components/responses/Result"}
+        }
+      }
+    },
+    "/executors/{executor}/apps/add": {
+      "parameters": [{"$ref": "#/components/parameters/Executor"}],
+      "post": {
+        "summary": "Add an app created by a registered factory to the executor, one app and type are required.",
+        "requestBody": {"$ref": "#/components/requestBodies/Request"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Result"},
+          "400": {"$ref": "#/components/responses/Result"},
+          "404": {"$ref": "#/components/responses/Result"}
+        }
+      }
+    },
+    "/executors/{executor}/apps/remove": {
+      "parameters": [{"$ref": "#/components/parameters/Executor"}],
+      "post": {
+        "summary": "Stop and remove apps from the executor.",
+        "requestBody": {"$ref": "#/components/requestBodies/Request"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Result"},
+          "400": {"$ref": "#/components/responses/Result"},
+          "404": {"$ref": "#/components/responses/Result"}
+        }
+      }
+    },
+    "/executors/{executor}/apps/move": {
+      "parameters": [{"$ref": "#/components/parameters/Executor"}],
+      "post": {
+        "summary": "Move apps of the executor to the target executor.",
+        "requestBody": {"$ref": "#/components/requestBodies/Request"},
+        "responses": {
+          "200": {"$ref": "#/components/responses/Result"},
+          "400": {"$ref": "#/components/responses/Result"},
+          "404": {"$ref": "#/components/responses/Result"}
+        }
+      }
+    }
+  },
+  "components": {
+    "parameters": {
+      "Executor": {"name": "executor", "in": "path", "required": true, "schema": {"type": "string"}}
+    },
+    "requestBodies": {
+      "Request": {
+        "required": false,
+        "content": {
+          "application/json": {
+            "schema": {
+              "type": "object",
+              "properties": {
+                "apps": {"type": "array", "items": {"type": "string"}},
+                "replicas": {"type": "integer", "minimum": 1},
+                "executor": {"type": "string"},
+                "level": {"type": "string", "example": "INFO"},
+                "concurrency": {"type": "integer", "minimum": 1},
+                "type": {"type": "string"},
+                "config": {"type": "object"},
+                "target": {"type": "string"}
+              }
+            }
+          }
+        }
+      }
+    },
+    "responses": {
+      "Result": {
+        "description": "code is 0 on success, otherwise the http status code.",
+        "content": {
+          "application/json": {
+            "schema": {
+              "type": "object",
+              "properties": {
+                "code": {"type": "integer"},
+                "message": {"type": "string"},
+                "data": {}
+              }
+            }
+          }
+        }
+      }
+    }
+  }
+}
+`
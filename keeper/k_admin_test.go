@@ -0,0 +1,51 @@
+package keeper
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gogf/gf/os/gcfg"
+)
+
+func TestAdminConfigRedacted(t *testing.T) {
+	const file = "k_admin_test.json"
+	gcfg.SetContent(`{
+		"auth": {"enable": true, "tokens": [{"id": "deploy", "token": "auth-secret-1", "role": "admin"}]},
+		"trace": {"endpoint": "http://127.0.0.1:4318", "headers": {"Authorization": "Bearer trace-secret-2"}},
+		"events": {"sinks": [{"type": "webhook", "url": "http://127.0.0.1/hook", "headers": {"X-Token": "hook-secret-3"}}]},
+		"apps": {"db": {"host": "127.0.0.1", "password": "db-secret-4", "api_key": "db-secret-5", "accessToken": "db-secret-6"}}
+	}`, file)
+	defer gcfg.RemoveContent(file)
+	k := &Keeper{KConfig: gcfg.New(file)}
+
+	w := httptest.NewRecorder()
+	k.AdminMux().ServeHTTP(w, httptest.NewRequest(http.MethodGet, adminPathPrefix+"/config", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status = %d, body: %s", w.Code, w.Body.String())
+	}
+	body := w.Body.String()
+	if strings.Contains(body, "secret") {
+		t.Fatalf("secret leaked: %s", body)
+	}
+
+	resp := &struct {
+		Data map[string]interface{} `json:"data"`
+	}{}
+	if err := json.Unmarshal(w.Body.Bytes(), resp); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := resp.Data["auth"]; ok {
+		t.Fatalf("auth node is returned: %s", body)
+	}
+	// 非敏感配置保持不变
+	db := resp.Data["apps"].(map[string]interface{})["db"].(map[string]interface{})
+	if db["host"] != "127.0.0.1" || db["password"] != redactedValue {
+		t.Fatalf("apps.db = %v", db)
+	}
+	if url := resp.Data["trace"].(map[string]interface{})["endpoint"]; url != "http://127.0.0.1:4318" {
+		t.Fatalf("trace.endpoint = %v", url)
+	}
+}
@@ -0,0 +1,384 @@
+package keeper
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	"github.com/gogf/gf/os/genv"
+	kapp "github.com/moqsien/gokeeper/kapp"
+	kevent "github.com/moqsien/gokeeper/kevent"
+	kexecutor "github.com/moqsien/gokeeper/kexecutor"
+	kipc "github.com/moqsien/gokeeper/kipc"
+	ktype "github.com/moqsien/gokeeper/ktype"
+	kutils "github.com/moqsien/gokeeper/kutils"
+	goktrl "github.com/moqsien/goktrl"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+  运行时添加、移除和移动App：
+    adda -e exec -t type [-c '{"key": "value"}'] app   通过已注册的App工厂(见kapp.RegisterFactory)创建App，添加到Executor并启动
+    removea -e exec app...                            停止并从Executor中移除App
+    movea -e exec -t target app...                    将App移动到另一个Executor，App正在运行时在目标Executor中重新启动
+  多进程模式下，主进程记录变更并通过IPC通道转发给对应的子进程，副本子进程重启时按照记录重新创建App；
+  变更只在本次运行中有效，keeper重启后仍然以StartFunc和配置文件为准。
+*/
+
+// AddApp 通过App工厂创建App，添加到Executor并启动；Executor不存在时创建
+func (that *Keeper) AddApp(execName, typeName, appName string, config map[string]interface{}) (string, error) {
+	if execName == "" || typeName == "" || appName == "" {
+		return "", gerror.NewCode(gcode.CodeMissingParameter, "executor, type and app name are required.")
+	}
+	if ex := that.executorOfApp(appName); ex != nil {
+		return "", gerror.NewCodef(gcode.CodeInvalidOperation, "App: [%s] already exists in executor [%s]!", appName, ex.Name)
+	}
+	spec := &kipc.AppSpec{Name: appName, Type: typeName, Config: config}
+	app, err := that.buildApp(spec)
+	if err != nil {
+		return "", err
+	}
+	if err = that.attachApp(that.executorOrNew(execName), app, spec, true); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("App: [%s] added to executor [%s].", appName, execName), nil
+}
+
+// RemoveApps 停止并从Executor中移除App
+func (that *Keeper) RemoveApps(execName string, appNames ...string) (string, error) {
+	ex, err := that.searchExecutor(execName)
+	if err != nil {
+		return "", err
+	}
+	var removed []string
+	for _, name := range appNames {
+		if _, _, err = that.detachApp(ex, name); err != nil {
+			logger.Warning(err)
+			continue
+		}
+		removed = append(removed, name)
+	}
+	if len(removed) == 0 {
+		return "", gerror.NewCodef(gcode.CodeOperationFailed, "Apps: [%s] remove failed: %v", kutils.SliceToString(appNames), err)
+	}
+	return fmt.Sprintf("Apps: [%s] removed from executor [%s].", kutils.SliceToString(removed), execName), nil
+}
+
+// MoveApps 将App移动到另一个Executor，目标Executor不存在时创建
+func (that *Keeper) MoveApps(execName, target string, appNames ...string) (string, error) {
+	ex, err := that.searchExecutor(execName)
+	if err != nil {
+		return "", err
+	}
+	if target == "" || target == execName {
+		return "", gerror.NewCodef(gcode.CodeInvalidParameter, "target executor must be different from [%s].", execName)
+	}
+	to := that.executorOrNew(target)
+	var moved []string
+	for _, name := range appNames {
+		if _, found := ex.AppList.Search(name); !found {
+			err = gerror.NewCodef(gcode.CodeNotFound, "App: [%s] is not found in executor [%s]!", name, execName)
+			logger.Warning(err)
+			continue
+		}
+		spec := ex.AppSpec(name)
+		app, running, e := that.detachApp(ex, name)
+		if e != nil {
+			err = e
+			logger.Warning(err)
+			continue
+		}
+		if err = that.attachApp(to, app, spec, running); err != nil {
+			logger.Warning(err)
+			continue
+		}
+		moved = append(moved, name)
+	}
+	if len(moved) == 0 {
+		return "", gerror.NewCodef(gcode.CodeOperationFailed, "Apps: [%s] move failed: %v", kutils.SliceToString(appNames), err)
+	}
+	return fmt.Sprintf("Apps: [%s] moved from executor [%s] to [%s].", kutils.SliceToString(moved), execName, target), nil
+}
+
+/*
+attachApp 将App添加到Executor，start为true时启动；
+多进程模式下，主进程中记录变更，Executor正在运行时通过IPC通道由子进程按照spec创建并启动App，
+Executor未运行且start为true时启动新的副本子进程来运行App，启动失败时返回错误。
+*/
+func (that *Keeper) attachApp(ex *kexecutor.Executor, app kapp.IApp, spec *kipc.AppSpec, start bool) error {
+	if err := ex.AddApp(app); err != nil {
+		return err
+	}
+	name := app.AppName()
+	that.PublishEvent(&kevent.Event{Type: kevent.AppAdded, Executor: ex.Name, App: name})
+	if !that.IsMutilProcModeAndInMaster() {
+		if start {
+			return ex.StartApp(name)
+		}
+		return nil
+	}
+	ex.RecordAddedApp(spec)
+	if ex.ProcessPlus == nil || !ex.IsRunning() {
+		if !start {
+			return nil
+		}
+		ex.ProcessPlus = nil
+		if _, err := that.StartExecutor(ex.Name, name); err != nil {
+			return gerror.WrapCodef(gcode.CodeOperationFailed, err, "App: [%s] added to executor [%s], but the executor failed to start", name, ex.Name)
+		}
+		return nil
+	}
+	msg := kipc.NewMessage(kipc.MsgAddApp).SetData(spec)
+	if start {
+		msg.Apps = []string{name}
+	}
+	replies, err := ex.RequestReplicas(msg)
+	if err = repliesError(replies, err); err != nil {
+		return gerror.WrapCodef(gcode.CodeOperationFailed, err, "App: [%s] added to executor [%s], but failed in child process", name, ex.Name)
+	}
+	if start {
+		ex.AppsRunning.Set(name, struct{}{})
+	}
+	return nil
+}
+
+// detachApp 停止并从Executor中移除App，返回App以及移除前是否正在运行
+func (that *Keeper) detachApp(ex *kexecutor.Executor, name string) (app kapp.IApp, running bool, err error) {
+	_, running = ex.AppsRunning.Search(name)
+	if that.IsMutilProcModeAndInMaster() && ex.ProcessPlus != nil && ex.IsRunning() {
+		replies, e := ex.RequestReplicas(kipc.NewMessage(kipc.MsgRemoveApp, name))
+		if e = repliesError(replies, e); e != nil {
+			return nil, false, gerror.WrapCodef(gcode.CodeOperationFailed, e, "App: [%s] remove failed in child process", name)
+		}
+	}
+	if app, err = ex.DetachApp(name); err != nil {
+		return nil, false, err
+	}
+	if that.IsMutilProcModeAndInMaster() {
+		ex.RecordRemovedApp(name)
+	}
+	that.PublishEvent(&kevent.Event{Type: kevent.AppRemoved, Executor: ex.Name, App: name})
+	return app, running, nil
+}
+
+// buildApp 按照spec创建App：通过App工厂创建，或者从StartFunc中所属的Executor取出
+func (that *Keeper) buildApp(spec *kipc.AppSpec) (kapp.IApp, error) {
+	if spec.Type == "" {
+		from, err := that.searchExecutor(spec.From)
+		if err != nil {
+			return nil, err
+		}
+		return from.TakeApp(spec.Name)
+	}
+	if len(spec.Config) > 0 {
+		if err := that.KConfig.Set(kapp.ConfigNode(spec.Name), spec.Config); err != nil {
+			return nil, err
+		}
+	}
+	return kapp.NewApp(spec.Type, spec.Name)
+}
+
+// executorOfApp 查找App所在的Executor，未找到时返回nil
+func (that *Keeper) executorOfApp(name string) (ex *kexecutor.Executor) {
+	that.Manager.Iterator(func(_ string, v interface{}) bool {
+		if _, found := v.(*kexecutor.Executor).AppList.Search(name); found {
+			ex = v.(*kexecutor.Executor)
+			return false
+		}
+		return true
+	})
+	return
+}
+
+// executorOrNew 获取Executor，不存在时创建
+func (that *Keeper) executorOrNew(name string) *kexecutor.Executor {
+	if v, found := that.Manager.Search(name); found {
+		return v.(*kexecutor.Executor)
+	}
+	ex := kexecutor.NewExecutor(name, that)
+	that.Manager.Add(name, ex)
+	return ex
+}
+
+/*
+  配置文件中声明的App：apps.[appName]节点下配置了type的App通过App工厂创建，其余配置项作为App的配置：
+    apps:
+      consumer2:
+        type: consumer      # kapp.RegisterFactory注册的类型名称
+        executor: workers   # 所属的Executor，默认为与keeper同名的Executor
+        concurrency: 4
+*/
+
+// AddConfigApps 按照配置文件创建App并添加到对应的Executor，在StartFunc之后执行；返回第一个失败的错误，失败的App不影响其他App
+func (that *Keeper) AddConfigApps() error {
+	if that.KConfig == nil {
+		return nil
+	}
+	apps := that.KConfig.GetMap(ktype.ConfigNodeNameApps)
+	names := make([]string, 0, len(apps))
+	for name := range apps {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var first error
+	for _, name := range names {
+		node := kapp.ConfigNode(name)
+		typeName := that.KConfig.GetString(node + ".type")
+		if typeName == "" {
+			continue
+		}
+		app, err := kapp.NewApp(typeName, name)
+		if err == nil {
+			err = that.AddAppToExecutor(app, that.KConfig.GetString(node+".executor"))
+		}
+		if err != nil {
+			err = gerror.WrapCodef(gcode.CodeInvalidConfiguration, err, "配置文件中的App[%s]添加失败", name)
+			logger.Error(err)
+			if first == nil {
+				first = err
+			}
+		}
+	}
+	return first
+}
+
+// restoreDynamicApps 子进程中，重新创建主进程传来的运行时添加的App
+func (that *Keeper) restoreDynamicApps() {
+	value := genv.Get(ktype.EnvDynamicApps)
+	if value == "" {
+		return
+	}
+	var specs []*kipc.AppSpec
+	if err := json.Unmarshal([]byte(value), &specs); err != nil {
+		logger.Warningf("%s格式错误: %v", ktype.EnvDynamicApps, err)
+		return
+	}
+	ex := that.executorOrNew(that.CurrentExecutor)
+	for _, spec := range specs {
+		app, err := that.buildApp(spec)
+		if err == nil {
+			err = ex.AddApp(app)
+		}
+		if err != nil {
+			logger.Warningf("重新创建App[%s]失败: %v", spec.Name, err)
+		}
+	}
+}
+
+// serveAddApp 子进程中，处理主进程发来的MsgAddApp消息
+func (that *Keeper) serveAddApp(ke *kexecutor.Executor, msg *kipc.Message) *kipc.Message {
+	spec := &kipc.AppSpec{}
+	if err := msg.GetData(spec); err != nil {
+		return &kipc.Message{Error: err.Error()}
+	}
+	app, err := that.buildApp(spec)
+	if err == nil {
+		err = ke.AddApp(app)
+	}
+	if err == nil && len(msg.Apps) > 0 {
+		err = ke.StartApp(spec.Name)
+	}
+	if err != nil {
+		return &kipc.Message{Error: err.Error()}
+	}
+	return &kipc.Message{Apps: []string{spec.Name}}
+}
+
+// repliesError 子进程的回复中出现的错误，没有子进程回复时返回err
+func repliesError(replies []*kipc.Message, err error) error {
+	if len(replies) == 0 {
+		if err == nil {
+			err = gerror.New("no replica replied")
+		}
+		return err
+	}
+	var errs []string
+	for _, reply := range replies {
+		if reply.Error != "" {
+			errs = append(errs, reply.Error)
+		}
+	}
+	if len(errs) > 0 {
+		return gerror.New(strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+// KtrlAddApp 通过App工厂创建App并添加到Executor
+func (that *Keeper) KtrlAddApp() {
+	type OptsAddApp struct {
+		Executor string `alias:"e" required:"true" descr:"executor from keeper, created if not exists."`
+		Type     string `alias:"t" required:"true" descr:"type name of a registered app factory."`
+		Config   string `alias:"c" descr:"app config in json, saved to apps.[app] node."`
+	}
+	handler := func(c *goktrl.Context) {
+		opt := c.Options.(*OptsAddApp)
+		var config map[string]interface{}
+		if opt.Config != "" {
+			if err := json.Unmarshal([]byte(opt.Config), &config); err != nil {
+				that.sendResult(c)("", gerror.WrapCode(gcode.CodeInvalidParameter, err, "config must be a json object"))
+				return
+			}
+		}
+		that.sendResult(c)(that.AddApp(opt.Executor, opt.Type, c.Args[0], config))
+	}
+
+	that.AddKtrlCommand(&goktrl.KCommand{
+		Name:            "adda",
+		Help:            "add an app created by a registered factory.",
+		Opts:            &OptsAddApp{},
+		KtrlHandler:     handler,
+		SocketName:      that.KCtrlSocket,
+		ArgsRequired:    true,
+		ArgsDescription: "name of the app to add.",
+		Auto:            true,
+	})
+}
+
+// KtrlRemoveApps 停止并从Executor中移除App
+func (that *Keeper) KtrlRemoveApps() {
+	type OptsRemoveApps struct {
+		Executor string `alias:"e" required:"true" descr:"executor from keeper."`
+	}
+	handler := func(c *goktrl.Context) {
+		opt := c.Options.(*OptsRemoveApps)
+		that.sendResult(c)(that.RemoveApps(opt.Executor, kutils.TrimEmpty(c.Args)...))
+	}
+
+	that.AddKtrlCommand(&goktrl.KCommand{
+		Name:            "removea",
+		Help:            "stop and remove apps from an executor.",
+		Opts:            &OptsRemoveApps{},
+		KtrlHandler:     handler,
+		SocketName:      that.KCtrlSocket,
+		ArgsRequired:    true,
+		ArgsDescription: "apps to remove.",
+		Auto:            true,
+	})
+}
+
+// KtrlMoveApps 将App移动到另一个Executor
+func (that *Keeper) KtrlMoveApps() {
+	type OptsMoveApps struct {
+		Executor string `alias:"e" required:"true" descr:"executor the apps belong to."`
+		Target   string `alias:"t" required:"true" descr:"executor to move the apps to, created if not exists."`
+	}
+	handler := func(c *goktrl.Context) {
+		opt := c.Options.(*OptsMoveApps)
+		that.sendResult(c)(that.MoveApps(opt.Executor, opt.Target, kutils.TrimEmpty(c.Args)...))
+	}
+
+	that.AddKtrlCommand(&goktrl.KCommand{
+		Name:            "movea",
+		Help:            "move apps to another executor.",
+		Opts:            &OptsMoveApps{},
+		KtrlHandler:     handler,
+		SocketName:      that.KCtrlSocket,
+		ArgsRequired:    true,
+		ArgsDescription: "apps to move.",
+		Auto:            true,
+	})
+}
@@ -0,0 +1,284 @@
+package keeper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gogf/gf/os/gfile"
+	"github.com/gogf/gf/os/gtime"
+	"github.com/gogf/gf/util/gconv"
+	kutils "github.com/moqsien/gokeeper/kutils"
+	goktrl "github.com/moqsien/goktrl"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+  审计日志：以追加的方式记录所有控制操作，每行一条JSON记录；
+  包括命令行发送的信号、交互式shell命令、管理接口调用、以及主进程自动重启/扩缩容子进程等操作。
+  配置示例：
+    audit:
+      enable: true                      # 默认开启
+      path: "/var/log/keeper/audit.log" # 默认为运行目录下的audit.log
+      skipReadOnly: false               # 为true时不记录执行成功的只读命令(如info、metrics)，被拒绝和执行失败的仍然记录
+*/
+
+// 审计日志中的操作来源
+const (
+	AuditSourceCli        = "cli"        // 命令行，如stop、reload、quit
+	AuditSourceSignal     = "signal"     // 主进程收到的信号
+	AuditSourceCtrl       = "ctrl"       // 交互式shell
+	AuditSourceAdmin      = "admin"      // 管理接口
+	AuditSourceSupervisor = "supervisor" // 主进程自动执行的操作
+)
+
+// 审计日志中的操作结果
+const (
+	AuditOutcomeOk     = "ok"
+	AuditOutcomeFailed = "failed"
+	AuditOutcomeDenied = "denied"
+)
+
+const (
+	auditDetailMax = 256         // 审计日志中记录的命令返回结果的最大长度
+	auditBodyMax   = 1024 * 1024 // 获取操作对象时读取的请求body的最大长度
+)
+
+// AuditEntry 审计日志记录
+type AuditEntry struct {
+	Time      string   `json:"time"`
+	Source    string   `json:"source"`
+	Actor     string   `json:"actor"`
+	Command   string   `json:"command"`
+	Targets   []string `json:"targets,omitempty"`
+	Outcome   string   `json:"outcome"`
+	Detail    string   `json:"detail,omitempty"`
+	Truncated bool     `json:"truncated,omitempty"` // 请求的body超过auditBodyMax未完整读取，Targets可能不完整
+}
+
+// AuditEnabled 是否开启审计日志，默认开启
+func (that *Keeper) AuditEnabled() bool {
+	return that.KConfig == nil || that.KConfig.GetBool("audit.enable", true)
+}
+
+// AuditSkipReadOnly 是否不记录执行成功的只读命令，默认记录
+func (that *Keeper) AuditSkipReadOnly() bool {
+	return that.KConfig != nil && that.KConfig.GetBool("audit.skipReadOnly")
+}
+
+/*
+AuditPath 审计日志路径；
+命令行进程未加载配置文件，从状态文件中读取主进程使用的审计日志路径。
+*/
+func (that *Keeper) AuditPath() string {
+	if that.KConfig != nil {
+		if path := that.KConfig.GetString("audit.path"); path != "" {
+			return path
+		}
+	}
+	if !that.IsMaster() || that.StartTime == nil {
+		if state, err := that.LoadState(); err == nil && state.AuditPath != "" {
+			return state.AuditPath
+		}
+	}
+	return filepath.Join(that.RunDir(), "audit.log")
+}
+
+// WriteAudit 写入一条审计日志
+func (that *Keeper) WriteAudit(entry *AuditEntry) {
+	if !that.AuditEnabled() {
+		return
+	}
+	if entry.Time == "" {
+		entry.Time = gtime.Now().Format("Y-m-d H:i:s.u")
+	}
+	entry.Detail = truncateDetail(entry.Detail, auditDetailMax)
+	content, _ := json.Marshal(entry)
+	path := that.AuditPath()
+	that.auditLock.Lock()
+	defer that.auditLock.Unlock()
+	if err := gfile.Mkdir(filepath.Dir(path)); err != nil {
+		logger.Warningf("写入审计日志失败: %v", err)
+		return
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		logger.Warningf("写入审计日志失败: %v", err)
+		return
+	}
+	defer f.Close()
+	if _, err = f.Write(append(content, '\n')); err != nil {
+		logger.Warningf("写入审计日志失败: %v", err)
+	}
+}
+
+// truncateDetail 截取前max个字节，不截断多字节字符
+func truncateDetail(detail string, max int) string {
+	if len(detail) <= max {
+		return detail
+	}
+	for max > 0 && !utf8.RuneStart(detail[max]) {
+		max--
+	}
+	return detail[:max] + "..."
+}
+
+// Audit 记录本进程发起的操作
+func (that *Keeper) Audit(source, command string, targets []string, err error) {
+	entry := &AuditEntry{
+		Source:  source,
+		Actor:   fmt.Sprintf("uid:%d,gid:%d,pid:%d", os.Getuid(), os.Getgid(), os.Getpid()),
+		Command: command,
+		Targets: targets,
+		Outcome: AuditOutcomeOk,
+	}
+	if err != nil {
+		entry.Outcome, entry.Detail = AuditOutcomeFailed, err.Error()
+	}
+	that.WriteAudit(entry)
+}
+
+// ReadAudit 读取最近的n条审计日志
+func (that *Keeper) ReadAudit(n int) ([]*AuditEntry, error) {
+	f, err := os.Open(that.AuditPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*AuditEntry{}, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+	var (
+		entries = []*AuditEntry{}
+		scanner = bufio.NewScanner(f)
+	)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		entry := &AuditEntry{}
+		if json.Unmarshal(scanner.Bytes(), entry) != nil {
+			continue
+		}
+		entries = append(entries, entry)
+		if n > 0 && len(entries) > n {
+			entries = entries[1:]
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// auditRecorder 记录命令的返回状态和返回结果
+type auditRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (that *auditRecorder) WriteHeader(status int) {
+	that.status = status
+	that.ResponseWriter.WriteHeader(status)
+}
+
+func (that *auditRecorder) Write(b []byte) (int, error) {
+	if that.body.Len() < auditDetailMax {
+		that.body.Write(b)
+	}
+	return that.ResponseWriter.Write(b)
+}
+
+// Flush 流式返回结果的命令(如events -f)需要及时将结果发送给客户端
+func (that *auditRecorder) Flush() {
+	if f, ok := that.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// auditRequest 记录控制命令的审计日志；配置了audit.skipReadOnly时，执行成功的只读命令不记录
+func (that *Keeper) auditRequest(source string, r *http.Request, actor *Actor, command string, status int, detail string) {
+	if status < http.StatusBadRequest && that.AuditSkipReadOnly() && that.CommandRole(command) == RoleRead {
+		return
+	}
+	truncated, _ := r.Context().Value(ctxKeyBodyTruncated).(bool)
+	entry := &AuditEntry{
+		Source:    source,
+		Actor:     actor.String(),
+		Command:   command,
+		Targets:   requestTargets(source, r, command),
+		Outcome:   auditOutcome(status),
+		Detail:    strings.TrimSpace(detail),
+		Truncated: truncated,
+	}
+	that.WriteAudit(entry)
+}
+
+// auditOutcome 根据HTTP状态码确定操作结果
+func auditOutcome(status int) string {
+	switch {
+	case status == http.StatusForbidden:
+		return AuditOutcomeDenied
+	case status >= http.StatusBadRequest:
+		return AuditOutcomeFailed
+	}
+	return AuditOutcomeOk
+}
+
+// requestTargets 获取控制命令操作的对象，如Executor和App
+func requestTargets(source string, r *http.Request, command string) (targets []string) {
+	if source == AuditSourceCtrl {
+		query := r.URL.Query()
+		if executor := query.Get("executor"); executor != "" {
+			targets = append(targets, executor)
+		}
+		args := strings.Split(query.Get(fmt.Sprintf(goktrl.ArgsFormatStr, command)), ",")
+		return append(targets, kutils.TrimEmpty(args)...)
+	}
+	if prefix := adminPathPrefix + "/executors/"; strings.HasPrefix(r.URL.Path, prefix) {
+		targets = append(targets, strings.Split(strings.TrimPrefix(r.URL.Path, prefix), "/")[0])
+	}
+	req := &AdminRequest{}
+	if body, ok := r.Context().Value(ctxKeyBody).([]byte); ok && json.Unmarshal(body, req) == nil {
+		targets = append(targets, req.Apps...)
+		if req.Executor != "" {
+			targets = append(targets, req.Executor)
+		}
+		if req.Level != "" {
+			targets = append(targets, req.Level)
+		}
+		if req.Replicas > 0 {
+			targets = append(targets, gconv.String(req.Replicas))
+		}
+	}
+	return
+}
+
+/*
+peekBody 读取请求body的前auditBodyMax个字节并保存到上下文中，同时保证后续的处理方法仍然可以读取完整的body；
+body超过auditBodyMax时在上下文中标记为已截断。
+*/
+func peekBody(r *http.Request) *http.Request {
+	if r.Body == nil || r.ContentLength == 0 {
+		return r
+	}
+	body, _ := io.ReadAll(io.LimitReader(r.Body, auditBodyMax+1))
+	ctx := r.Context()
+	if len(body) > auditBodyMax {
+		// 未读取的部分留给后续的处理方法
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		body = body[:auditBodyMax]
+		ctx = context.WithValue(ctx, ctxKeyBodyTruncated, true)
+	} else {
+		_ = r.Body.Close()
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return r.WithContext(context.WithValue(ctx, ctxKeyBody, body))
+}
@@ -0,0 +1,120 @@
+package keeper
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/gogf/gf/os/gcfg"
+)
+
+func TestTruncateDetail(t *testing.T) {
+	cases := []struct {
+		name   string
+		detail string
+		max    int
+		want   string
+	}{
+		{"short", "ok", 4, "ok"},
+		{"exact", "abcd", 4, "abcd"},
+		{"ascii", "abcdef", 4, "abcd..."},
+		{"rune boundary", "ab执行失败", 5, "ab执..."},
+		{"inside rune", "ab执行失败", 4, "ab..."},
+		{"first rune", "执行失败", 2, "..."},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := truncateDetail(c.detail, c.max)
+			if got != c.want {
+				t.Fatalf("truncateDetail(%q, %d) = %q, want %q", c.detail, c.max, got, c.want)
+			}
+			if !utf8.ValidString(got) {
+				t.Fatalf("truncateDetail(%q, %d) = %q is not valid UTF-8", c.detail, c.max, got)
+			}
+		})
+	}
+}
+
+func TestPeekBody(t *testing.T) {
+	cases := []struct {
+		name      string
+		size      int
+		truncated bool
+	}{
+		{"small", 16, false},
+		{"limit", auditBodyMax, false},
+		{"large", auditBodyMax + 10, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			body := bytes.Repeat([]byte("a"), c.size)
+			r := peekBody(httptest.NewRequest(http.MethodPost, "/", bytes.NewReader(body)))
+			peeked, _ := r.Context().Value(ctxKeyBody).([]byte)
+			truncated, _ := r.Context().Value(ctxKeyBodyTruncated).(bool)
+			if truncated != c.truncated {
+				t.Fatalf("truncated = %v, want %v", truncated, c.truncated)
+			}
+			want := c.size
+			if c.truncated {
+				want = auditBodyMax
+			}
+			if len(peeked) != want {
+				t.Fatalf("peeked %d bytes, want %d", len(peeked), want)
+			}
+			// 后续的处理方法仍然可以读取完整的body
+			read, err := io.ReadAll(r.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(read, body) {
+				t.Fatalf("handler read %d bytes, want %d", len(read), len(body))
+			}
+		})
+	}
+}
+
+func TestAuditReadOnly(t *testing.T) {
+	cases := []struct {
+		name         string
+		skipReadOnly bool
+		command      string
+		status       int
+		logged       bool
+	}{
+		{"read ok", false, "info", http.StatusOK, true},
+		{"read failed", false, "info", http.StatusInternalServerError, true},
+		{"write ok", false, "stop", http.StatusOK, true},
+		{"skip read ok", true, "info", http.StatusOK, false},
+		{"skip read denied", true, "info", http.StatusForbidden, true},
+		{"skip read failed", true, "info", http.StatusInternalServerError, true},
+		{"skip write ok", true, "stop", http.StatusOK, true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			file := fmt.Sprintf("k_audit_test_%s.json", strings.ReplaceAll(c.name, " ", "_"))
+			path := filepath.Join(t.TempDir(), "audit.log")
+			gcfg.SetContent(fmt.Sprintf(`{"audit": {"path": %q, "skipReadOnly": %v}}`, path, c.skipReadOnly), file)
+			defer gcfg.RemoveContent(file)
+			k := &Keeper{KConfig: gcfg.New(file)}
+
+			r := httptest.NewRequest(http.MethodGet, adminPathPrefix+"/"+c.command, nil)
+			k.auditRequest(AuditSourceAdmin, r, &Actor{Remote: "127.0.0.1"}, c.command, c.status, "")
+			entries, err := k.ReadAudit(0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if logged := len(entries) == 1; logged != c.logged {
+				t.Fatalf("logged = %v, want %v", logged, c.logged)
+			}
+			if c.logged && (entries[0].Command != c.command || entries[0].Outcome != auditOutcome(c.status)) {
+				t.Fatalf("entry = %+v", entries[0])
+			}
+		})
+	}
+}
@@ -0,0 +1,309 @@
+package keeper
+
+import (
+	"context"
+	"crypto/subtle"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gogf/gf/container/garray"
+	"github.com/gogf/gf/errors/gerror"
+	ktrace "github.com/moqsien/gokeeper/ktrace"
+	logger "github.com/moqsien/processes/logger"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+/*
+  控制命令的认证与授权：
+    unix套接字(交互式shell以及unix套接字上的管理接口)通过SO_PEERCRED获取对端进程的uid/gid，与配置中允许的uid/gid比对；
+    管理接口可以通过"Authorization: Bearer <token>"携带token认证，监听TCP地址时必须使用token；
+    命令分为只读(如info、version)和修改(如stope、reload)两类角色，权限不足的请求会被拒绝并记录日志。
+  配置示例：
+    auth:
+      enable: true
+      admin:             # 可以执行所有命令，未配置时默认为root和keeper进程所属的用户
+        uids: [0, 1000]
+        gids: []
+      read:              # 只能执行只读命令
+        uids: [1001]
+      tokens:
+        - id: deploy
+          token: "xxxxxx"
+          role: admin    # admin 或 read
+      commands:          # 修改命令所需的角色
+        reload: read
+*/
+
+// Role 执行控制命令所需的角色
+type Role int
+
+const (
+	RoleNone  Role = iota // 无权限
+	RoleRead              // 只读命令
+	RoleAdmin             // 所有命令
+)
+
+// ParseRole 解析配置中的角色名称
+func ParseRole(name string) Role {
+	switch strings.ToLower(name) {
+	case "admin":
+		return RoleAdmin
+	case "read":
+		return RoleRead
+	}
+	return RoleNone
+}
+
+func (that Role) String() string {
+	switch that {
+	case RoleAdmin:
+		return "admin"
+	case RoleRead:
+		return "read"
+	}
+	return "none"
+}
+
+// 默认只需要只读角色的命令，其他命令都需要admin角色
+var readOnlyCommands = garray.NewStrArrayFrom([]string{"version", "info", "config", "audit", "metrics", "events", "history"}, true)
+
+// AuthPeers 允许的unix套接字对端用户
+type AuthPeers struct {
+	Uids []int `json:"uids"`
+	Gids []int `json:"gids"`
+}
+
+// contains 判断uid或gid是否在允许列表中
+func (that *AuthPeers) contains(uid, gid int) bool {
+	for _, v := range that.Uids {
+		if v == uid {
+			return true
+		}
+	}
+	for _, v := range that.Gids {
+		if v == gid {
+			return true
+		}
+	}
+	return false
+}
+
+// AuthToken 管理接口使用的token
+type AuthToken struct {
+	Id    string `json:"id"`    // token标识，用于日志记录，避免记录token本身
+	Token string `json:"token"` // token内容
+	Role  string `json:"role"`  // token对应的角色
+}
+
+// AuthConfig 认证配置，对应配置文件中的auth节点
+type AuthConfig struct {
+	Enable   bool              `json:"enable"`
+	Admin    AuthPeers         `json:"admin"`
+	Read     AuthPeers         `json:"read"`
+	Tokens   []*AuthToken      `json:"tokens"`
+	Commands map[string]string `json:"commands"`
+}
+
+// Actor 发起控制命令的对端
+type Actor struct {
+	Uid    int    `json:"uid"`
+	Gid    int    `json:"gid"`
+	Pid    int    `json:"pid"`
+	Token  string `json:"token,omitempty"`  // 使用token认证时为token的标识
+	Remote string `json:"remote,omitempty"` // TCP连接的对端地址
+	Role   Role   `json:"-"`
+}
+
+func (that *Actor) String() string {
+	if that.Token != "" {
+		return fmt.Sprintf("token:%s", that.Token)
+	}
+	if that.Pid == 0 && that.Remote != "" {
+		return that.Remote
+	}
+	return fmt.Sprintf("uid:%d,gid:%d,pid:%d", that.Uid, that.Gid, that.Pid)
+}
+
+type ctxKey string
+
+const (
+	ctxKeyConn  ctxKey = "conn"  // 请求对应的连接
+	ctxKeyActor ctxKey = "actor" // 请求对应的Actor
+	ctxKeyBody  ctxKey = "body"  // 请求的body，用于记录审计日志
+
+	ctxKeyBodyTruncated ctxKey = "bodyTruncated" // 请求的body超过auditBodyMax，ctxKeyBody中只有前面的部分
+)
+
+// connContext 将连接保存到请求的上下文中，用于获取unix套接字对端的用户信息
+func connContext(ctx context.Context, c net.Conn) context.Context {
+	return context.WithValue(ctx, ctxKeyConn, c)
+}
+
+// LoadAuthConfig 读取认证配置
+func (that *Keeper) LoadAuthConfig() {
+	that.authConf = &AuthConfig{}
+	if that.KConfig == nil || that.KConfig.Get("auth") == nil {
+		return
+	}
+	err := that.KConfig.GetStruct("auth", that.authConf)
+	if err == nil {
+		err = that.authConf.validate()
+	}
+	if err != nil {
+		logger.Errorf("读取认证配置失败: %v", err)
+		// 配置有误时拒绝所有请求，避免在未认证的情况下开放控制命令
+		that.authConf = &AuthConfig{Enable: true}
+	}
+}
+
+// validate 检查配置中的角色名称，拼写错误的角色会使命令对所有人开放或者使token失效
+func (that *AuthConfig) validate() error {
+	for _, t := range that.Tokens {
+		if ParseRole(t.Role) == RoleNone {
+			return gerror.Newf("unknown role %q of token %s", t.Role, t.Id)
+		}
+	}
+	for command, role := range that.Commands {
+		if ParseRole(role) == RoleNone {
+			return gerror.Newf("unknown role %q of command %s", role, command)
+		}
+	}
+	return nil
+}
+
+// CommandRole 执行命令所需的角色；配置中的角色名称无法识别时需要admin角色
+func (that *Keeper) CommandRole(command string) Role {
+	if that.authConf != nil {
+		if role, ok := that.authConf.Commands[command]; ok {
+			if r := ParseRole(role); r != RoleNone {
+				return r
+			}
+			return RoleAdmin
+		}
+	}
+	if readOnlyCommands.Contains(command) {
+		return RoleRead
+	}
+	return RoleAdmin
+}
+
+// authActor 获取请求对应的Actor及其角色；携带token时使用token对应的角色，否则根据unix套接字对端的用户确定角色
+func (that *Keeper) authActor(r *http.Request) (*Actor, error) {
+	conf := that.authConf
+	actor := &Actor{Remote: r.RemoteAddr}
+	conn, _ := r.Context().Value(ctxKeyConn).(net.Conn)
+	_, isUnix := conn.(*net.UnixConn)
+	var credErr error
+	if isUnix {
+		if actor, credErr = peerCred(conn); credErr != nil {
+			actor = &Actor{}
+		}
+	}
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		token := strings.TrimPrefix(auth, "Bearer ")
+		for _, t := range conf.Tokens {
+			if t.Token != "" && subtle.ConstantTimeCompare([]byte(t.Token), []byte(token)) == 1 {
+				actor.Token, actor.Role = t.Id, ParseRole(t.Role)
+				return actor, nil
+			}
+		}
+		return actor, gerror.New("invalid token")
+	}
+	if !isUnix {
+		return actor, gerror.New("token is required")
+	}
+	if credErr != nil {
+		return actor, credErr
+	}
+	switch {
+	case conf.Admin.contains(actor.Uid, actor.Gid):
+		actor.Role = RoleAdmin
+	case len(conf.Admin.Uids) == 0 && len(conf.Admin.Gids) == 0 && (actor.Uid == 0 || actor.Uid == os.Getuid()):
+		actor.Role = RoleAdmin
+	case conf.Read.contains(actor.Uid, actor.Gid):
+		actor.Role = RoleRead
+	}
+	return actor, nil
+}
+
+// Authorize 判断请求是否可以执行命令，返回发起请求的Actor
+func (that *Keeper) Authorize(r *http.Request, command string) (*Actor, error) {
+	if that.authConf == nil {
+		that.LoadAuthConfig()
+	}
+	actor, err := that.authActor(r)
+	if !that.authConf.Enable {
+		return actor, nil
+	}
+	if err == nil && (actor.Role == RoleNone || actor.Role < that.CommandRole(command)) {
+		err = gerror.Newf("role %s is not allowed to run command %s", actor.Role, command)
+	}
+	if err != nil {
+		logger.Warningf("拒绝来自[%s]的命令[%s]: %v", actor, command, err)
+	}
+	return actor, err
+}
+
+// authHandler 认证、审计、计数及链路追踪中间件，source为请求来源，command获取请求对应的命令名称，deny返回拒绝的结果
+func (that *Keeper) authHandler(next http.Handler, source string, command func(r *http.Request) string,
+	deny func(w http.ResponseWriter, err error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = peekBody(r)
+		cmd := command(r)
+		// 每条控制命令作为一条新的链路
+		ctx, span := ktrace.Start(r.Context(), "ctrl."+cmd,
+			ktrace.AttrKeeper.String(that.KeeperName),
+			ktrace.AttrCommand.String(cmd),
+			ktrace.AttrSource.String(source),
+		)
+		r = r.WithContext(ctx)
+		actor, err := that.Authorize(r, cmd)
+		span.SetAttributes(ktrace.AttrActor.String(actor.String()))
+		if targets := requestTargets(source, r, cmd); len(targets) > 0 {
+			span.SetAttributes(attribute.StringSlice("keeper.targets", targets))
+		}
+		if err != nil {
+			deny(w, err)
+			that.auditRequest(source, r, actor, cmd, http.StatusForbidden, err.Error())
+			countCtrlCommand(source, cmd, http.StatusForbidden)
+			ktrace.End(span, err)
+			return
+		}
+		recorder := &auditRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), ctxKeyActor, actor)))
+		that.auditRequest(source, r, actor, cmd, recorder.status, recorder.body.String())
+		countCtrlCommand(source, cmd, recorder.status)
+		span.SetAttributes(attribute.Int("http.status_code", recorder.status))
+		if recorder.status >= http.StatusBadRequest {
+			err = gerror.New(strings.TrimSpace(recorder.body.String()))
+		}
+		ktrace.End(span, err)
+	})
+}
+
+// ctrlCommand 交互式shell请求对应的命令名称
+func ctrlCommand(r *http.Request) string {
+	return strings.Trim(strings.TrimPrefix(r.URL.Path, "/ktrl/"), "/")
+}
+
+// RunCtrlServer 启动交互式shell的服务端，与goktrl的Unix套接字一致，并加入认证中间件
+func (that *Keeper) RunCtrlServer() {
+	server := that.KCtrl.CtrlServer
+	server.SetUnixSocket(that.KCtrlSocket)
+	server.CheckUnixSocket()
+	ln, err := net.Listen("unix", server.UnixSocketPath)
+	if err != nil {
+		logger.Errorf("交互式shell服务端监听[%s]失败: %v", server.UnixSocketPath, err)
+		return
+	}
+	handler := that.authHandler(server.Router, AuditSourceCtrl, ctrlCommand, func(w http.ResponseWriter, err error) {
+		http.Error(w, fmt.Sprintf("Permission denied: %v", err), http.StatusForbidden)
+	})
+	srv := &http.Server{Handler: handler, ConnContext: connContext}
+	if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+		logger.Errorf("交互式shell服务端运行出错: %v", err)
+	}
+}
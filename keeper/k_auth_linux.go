@@ -0,0 +1,36 @@
+//go:build linux
+// +build linux
+
+package keeper
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/gogf/gf/errors/gerror"
+)
+
+// peerCred 通过SO_PEERCRED获取unix套接字对端进程的pid、uid、gid
+func peerCred(conn net.Conn) (*Actor, error) {
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return nil, gerror.New("not a unix socket connection")
+	}
+	raw, err := uc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var (
+		cred    *syscall.Ucred
+		credErr error
+	)
+	if err = raw.Control(func(fd uintptr) {
+		cred, credErr = syscall.GetsockoptUcred(int(fd), syscall.SOL_SOCKET, syscall.SO_PEERCRED)
+	}); err != nil {
+		return nil, err
+	}
+	if credErr != nil {
+		return nil, credErr
+	}
+	return &Actor{Uid: int(cred.Uid), Gid: int(cred.Gid), Pid: int(cred.Pid)}, nil
+}
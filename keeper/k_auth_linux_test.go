@@ -0,0 +1,93 @@
+package keeper
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+)
+
+// unixRequest 通过unix套接字发来的请求，对端为当前进程
+func unixRequest(t *testing.T) *http.Request {
+	t.Helper()
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conns := make([]net.Conn, 2)
+	for i, fd := range fds {
+		f := os.NewFile(uintptr(fd), "auth-test")
+		conns[i], err = net.FileConn(f)
+		_ = f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Cleanup(func() {
+		_ = conns[0].Close()
+		_ = conns[1].Close()
+	})
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/keeper", nil)
+	return r.WithContext(connContext(context.Background(), conns[0]))
+}
+
+func TestAuthActorPeer(t *testing.T) {
+	uid, gid := os.Getuid(), os.Getgid()
+	other := uid + 12345
+	cases := []struct {
+		name string
+		conf *AuthConfig
+		role Role
+	}{
+		{"default admin is own user", &AuthConfig{Enable: true}, RoleAdmin},
+		{"admin by uid", &AuthConfig{Enable: true, Admin: AuthPeers{Uids: []int{uid}}}, RoleAdmin},
+		{"admin by gid", &AuthConfig{Enable: true, Admin: AuthPeers{Gids: []int{gid}}}, RoleAdmin},
+		{"read by uid", &AuthConfig{Enable: true, Admin: AuthPeers{Uids: []int{other}}, Read: AuthPeers{Uids: []int{uid}}}, RoleRead},
+		{"read by gid", &AuthConfig{Enable: true, Admin: AuthPeers{Uids: []int{other}}, Read: AuthPeers{Gids: []int{gid}}}, RoleRead},
+		{"not allowed", &AuthConfig{Enable: true, Admin: AuthPeers{Uids: []int{other}}}, RoleNone},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			k := &Keeper{authConf: c.conf}
+			actor, err := k.authActor(unixRequest(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actor.Uid != uid || actor.Gid != gid || actor.Pid != os.Getpid() {
+				t.Fatalf("actor = %s, want uid:%d,gid:%d,pid:%d", actor, uid, gid, os.Getpid())
+			}
+			if actor.Role != c.role {
+				t.Fatalf("role = %s, want %s", actor.Role, c.role)
+			}
+		})
+	}
+}
+
+func TestAuthorizePeer(t *testing.T) {
+	uid := os.Getuid()
+	other := uid + 12345
+	readOnly := &AuthConfig{Enable: true, Admin: AuthPeers{Uids: []int{other}}, Read: AuthPeers{Uids: []int{uid}}}
+	cases := []struct {
+		name    string
+		conf    *AuthConfig
+		command string
+		wantErr bool
+	}{
+		{"admin runs admin command", &AuthConfig{Enable: true}, "stope", false},
+		{"read runs read command", readOnly, "info", false},
+		{"read denied admin command", readOnly, "reload", true},
+		{"unknown peer denied", &AuthConfig{Enable: true, Admin: AuthPeers{Uids: []int{other}}}, "version", true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			k := &Keeper{authConf: c.conf}
+			_, err := k.Authorize(unixRequest(t), c.command)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, c.wantErr)
+			}
+		})
+	}
+}
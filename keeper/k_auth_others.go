@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package keeper
+
+import (
+	"net"
+
+	"github.com/gogf/gf/errors/gerror"
+)
+
+// peerCred 非linux系统不支持SO_PEERCRED，开启认证时unix套接字上的请求需要使用token
+func peerCred(conn net.Conn) (*Actor, error) {
+	return nil, gerror.New("peer credentials are not supported on this platform")
+}
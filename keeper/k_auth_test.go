@@ -0,0 +1,162 @@
+package keeper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gogf/gf/os/gcfg"
+)
+
+func TestParseRole(t *testing.T) {
+	cases := []struct {
+		name string
+		want Role
+	}{
+		{"admin", RoleAdmin},
+		{"ADMIN", RoleAdmin},
+		{"read", RoleRead},
+		{"Read", RoleRead},
+		{"", RoleNone},
+		{"root", RoleNone},
+	}
+	for _, c := range cases {
+		if got := ParseRole(c.name); got != c.want {
+			t.Errorf("ParseRole(%q) = %s, want %s", c.name, got, c.want)
+		}
+	}
+}
+
+func TestCommandRole(t *testing.T) {
+	k := &Keeper{authConf: &AuthConfig{Commands: map[string]string{"reload": "read", "info": "admin", "events": "raed"}}}
+	cases := []struct {
+		command string
+		want    Role
+	}{
+		{"version", RoleRead},
+		{"metrics", RoleRead},
+		{"stope", RoleAdmin},
+		{"reload", RoleRead},  // 配置覆盖
+		{"info", RoleAdmin},   // 配置覆盖
+		{"events", RoleAdmin}, // 无法识别的角色
+		{"unknown", RoleAdmin},
+	}
+	for _, c := range cases {
+		if got := k.CommandRole(c.command); got != c.want {
+			t.Errorf("CommandRole(%q) = %s, want %s", c.command, got, c.want)
+		}
+	}
+}
+
+// tokenRequest 通过TCP连接发来的请求，token为空时不携带Authorization
+func tokenRequest(token string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/api/v1/keeper", nil)
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	return r
+}
+
+func TestAuthActorToken(t *testing.T) {
+	k := &Keeper{authConf: &AuthConfig{Enable: true, Tokens: []*AuthToken{
+		{Id: "deploy", Token: "admin-token", Role: "admin"},
+		{Id: "monitor", Token: "read-token", Role: "read"},
+		{Id: "empty", Token: "", Role: "admin"},
+	}}}
+	cases := []struct {
+		name    string
+		token   string
+		actor   string
+		role    Role
+		wantErr bool
+	}{
+		{name: "admin token", token: "admin-token", actor: "token:deploy", role: RoleAdmin},
+		{name: "read token", token: "read-token", actor: "token:monitor", role: RoleRead},
+		{name: "invalid token", token: "guess", wantErr: true},
+		{name: "token prefix", token: "admin", wantErr: true},
+		{name: "missing token over tcp", wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			actor, err := k.authActor(tokenRequest(c.token))
+			if c.wantErr {
+				if err == nil {
+					t.Fatalf("authActor should fail, got role %s", actor.Role)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if actor.String() != c.actor || actor.Role != c.role {
+				t.Fatalf("actor = %s (%s), want %s (%s)", actor, actor.Role, c.actor, c.role)
+			}
+		})
+	}
+}
+
+func TestAuthorize(t *testing.T) {
+	tokens := []*AuthToken{
+		{Id: "deploy", Token: "admin-token", Role: "admin"},
+		{Id: "monitor", Token: "read-token", Role: "read"},
+	}
+	cases := []struct {
+		name    string
+		conf    *AuthConfig
+		token   string
+		command string
+		wantErr bool
+	}{
+		{name: "disabled allows anonymous", conf: &AuthConfig{}, command: "stope"},
+		{name: "admin runs admin command", conf: &AuthConfig{Enable: true, Tokens: tokens}, token: "admin-token", command: "stope"},
+		{name: "read runs read command", conf: &AuthConfig{Enable: true, Tokens: tokens}, token: "read-token", command: "info"},
+		{name: "read denied admin command", conf: &AuthConfig{Enable: true, Tokens: tokens}, token: "read-token", command: "stope", wantErr: true},
+		{name: "read allowed by override", conf: &AuthConfig{Enable: true, Tokens: tokens, Commands: map[string]string{"reload": "read"}},
+			token: "read-token", command: "reload"},
+		{name: "invalid token denied", conf: &AuthConfig{Enable: true, Tokens: tokens}, token: "guess", command: "info", wantErr: true},
+		{name: "anonymous tcp denied", conf: &AuthConfig{Enable: true, Tokens: tokens}, command: "version", wantErr: true},
+		{name: "misspelled override needs admin", conf: &AuthConfig{Enable: true, Tokens: tokens, Commands: map[string]string{"reload": "raed"}},
+			token: "read-token", command: "reload", wantErr: true},
+		{name: "misspelled token role denied", conf: &AuthConfig{Enable: true, Tokens: []*AuthToken{{Id: "typo", Token: "typo-token", Role: "amdin"}}},
+			token: "typo-token", command: "version", wantErr: true},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			k := &Keeper{authConf: c.conf}
+			_, err := k.Authorize(tokenRequest(c.token), c.command)
+			if (err != nil) != c.wantErr {
+				t.Fatalf("err = %v, wantErr %v", err, c.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadAuthConfig(t *testing.T) {
+	cases := []struct {
+		name     string
+		content  string
+		denyAll  bool
+		wantToks int
+	}{
+		{"no auth node", `{}`, false, 0},
+		{"valid", `{"auth": {"enable": true, "tokens": [{"id": "deploy", "token": "t", "role": "admin"}], "commands": {"reload": "read"}}}`, false, 1},
+		{"unknown token role", `{"auth": {"enable": true, "tokens": [{"id": "deploy", "token": "t", "role": "amdin"}]}}`, true, 0},
+		{"unknown command role", `{"auth": {"enable": false, "commands": {"reload": "raed"}}}`, true, 0},
+	}
+	const file = "k_auth_test.json"
+	defer gcfg.RemoveContent(file)
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			gcfg.SetContent(c.content, file)
+			k := &Keeper{KConfig: gcfg.New(file)}
+			k.LoadAuthConfig()
+			conf := k.authConf
+			// 配置有误时开启认证且不允许任何token和用户
+			denyAll := conf.Enable && len(conf.Tokens) == 0 && len(conf.Commands) == 0 &&
+				len(conf.Admin.Uids) == 0 && len(conf.Read.Uids) == 0
+			if denyAll != c.denyAll || len(conf.Tokens) != c.wantToks {
+				t.Fatalf("authConf = %+v, want deny all %v", conf, c.denyAll)
+			}
+		})
+	}
+}
@@ -23,7 +23,7 @@ const (
 )
 
 var resourceTryFiles = []string{"", "/", "config/", "config", "/config", "/config/"}
-var searchPaths *garray.StrArray
+var searchPaths = garray.NewStrArray(true)
 
 // 传入配置文件地址，获取gcfg对象
 func (that *Keeper) GetGFConf(confFile string) *gcfg.Config {
@@ -0,0 +1,359 @@
+package keeper
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/gogf/gf/errors/gerror"
+	"github.com/gogf/gf/os/gfile"
+	goktrl "github.com/moqsien/goktrl"
+)
+
+/*
+  非交互式执行交互式shell的命令，便于在脚本中使用：
+    binary ctrl <command> [opts] [args]     执行一条命令
+    binary ctrl -o json <command> ...       以JSON格式输出结果
+    binary ctrl -f script.txt               依次执行文件中的命令，每行一条，#开头为注释；遇到失败的命令时停止；
+                                            参数可以像shell中一样使用引号和\转义，见splitCtrlLine
+  退出码见CtrlExit*。
+*/
+
+// 非交互式执行命令的退出码
+const (
+	CtrlExitOk          = 0 // 命令执行成功
+	CtrlExitFailed      = 1 // 命令执行失败
+	CtrlExitUsage       = 2 // 命令或参数有误
+	CtrlExitUnavailable = 3 // 无法连接keeper，keeper未运行
+	CtrlExitDenied      = 4 // 没有权限执行命令
+)
+
+// CtrlResult 非交互式执行命令的结果，--output json时输出
+type CtrlResult struct {
+	Command  string      `json:"command"`
+	Args     []string    `json:"args"`
+	ExitCode int         `json:"exitCode"`
+	Status   int         `json:"status,omitempty"` // 服务端返回的HTTP状态码
+	Result   interface{} `json:"result,omitempty"`
+	Error    string      `json:"error,omitempty"`
+}
+
+// AddKtrlCommand 注册交互式shell命令，同时记录命令以便非交互式执行
+func (that *Keeper) AddKtrlCommand(kcmd *goktrl.KCommand) {
+	that.KCtrl.AddKtrlCommand(kcmd)
+	that.ktrlCommands.Set(kcmd.Name, kcmd)
+}
+
+// RunCtrl 非交互式执行命令，返回退出码
+func (that *Keeper) RunCtrl(args []string) int {
+	var output, file string
+	for len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		name, value := args[0], ""
+		if i := strings.Index(name, "="); i > 0 {
+			name, value = name[:i], name[i+1:]
+		} else if len(args) > 1 {
+			value, args = args[1], args[1:]
+		}
+		args = args[1:]
+		switch name {
+		case "-o", "--output":
+			output = value
+		case "-f", "--file":
+			file = value
+		default:
+			fmt.Printf("Unknown option: %s\n", name)
+			return CtrlExitUsage
+		}
+	}
+	if output != "" && output != "json" && output != "text" {
+		fmt.Printf("Unknown output format: %s\n", output)
+		return CtrlExitUsage
+	}
+	if file != "" {
+		return that.runCtrlScript(file, output)
+	}
+	if len(args) == 0 {
+		that.ctrlUsage()
+		return CtrlExitUsage
+	}
+	return that.runCtrlCommand(args, output)
+}
+
+// runCtrlScript 依次执行文件中的命令，遇到失败的命令时停止，返回失败命令的退出码
+func (that *Keeper) runCtrlScript(file, output string) int {
+	var r io.Reader = os.Stdin
+	if file != "-" {
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Println(err)
+			return CtrlExitUsage
+		}
+		defer f.Close()
+		r = f
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if output != "json" {
+			fmt.Printf("> %s\n", line)
+		}
+		args, err := splitCtrlLine(line)
+		if err != nil {
+			fmt.Println(err)
+			return CtrlExitUsage
+		}
+		if code := that.runCtrlCommand(args, output); code != CtrlExitOk {
+			return code
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		fmt.Println(err)
+		return CtrlExitUsage
+	}
+	return CtrlExitOk
+}
+
+/*
+splitCtrlLine 按照shell的规则拆分脚本中的一行命令，如 adda -e web -t worker -c '{"concurrency": 4}' consumer；
+空白字符分隔参数，单引号内的内容原样保留，双引号内只有\"和\\需要转义，引号外的\转义下一个字符。
+*/
+func splitCtrlLine(line string) ([]string, error) {
+	var (
+		args    []string
+		current strings.Builder
+		inArg   bool // 当前是否有未结束的参数，用于保留''这样的空参数
+		quote   rune // 当前所在的引号，0表示不在引号内
+		escaped bool
+	)
+	for _, c := range line {
+		switch {
+		case escaped:
+			if quote == '"' && c != '"' && c != '\\' {
+				current.WriteRune('\\')
+			}
+			current.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped, inArg = true, true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote, inArg = c, true
+		case c == ' ' || c == '\t':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(c)
+			inArg = true
+		}
+	}
+	if escaped {
+		return nil, gerror.Newf("trailing backslash in: %s", line)
+	}
+	if quote != 0 {
+		return nil, gerror.Newf("unterminated %c quote in: %s", quote, line)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	return args, nil
+}
+
+// runCtrlCommand 执行一条命令，args[0]为命令名称
+func (that *Keeper) runCtrlCommand(args []string, output string) int {
+	name := args[0]
+	if name == "help" {
+		that.ctrlUsage()
+		return CtrlExitOk
+	}
+	v, ok := that.ktrlCommands.Search(name)
+	if !ok {
+		fmt.Printf("Unknown command: %s\n", name)
+		that.ctrlUsage()
+		return CtrlExitUsage
+	}
+	kcmd := v.(*goktrl.KCommand)
+	// 与交互式shell相同，通过os.Args传入命令的参数
+	os.Args = args[1:]
+	kc := &goktrl.Context{
+		Type:          goktrl.ContextClient,
+		KtrlPath:      kcmd.GetKtrlPath(),
+		DefaultSocket: kcmd.SocketName,
+		ShellCmdName:  kcmd.Name,
+	}
+	kc.Options, kc.Parser = goktrl.ParseShellOptions(kcmd.Opts, kcmd)
+	if kc.Parser == nil {
+		return CtrlExitUsage
+	}
+	kc.Args = kc.Parser.GetArgAll()
+	if kcmd.ArgsRequired && len(kc.Args) == 0 {
+		fmt.Println("At least one argument must be provided!")
+		return CtrlExitUsage
+	}
+	if !kcmd.Auto {
+		// 无需自动请求服务端的命令，如version，直接在本地执行；需要请求服务端的命令通过ctrlExit设置退出码
+		that.ctrlExit = CtrlExitOk
+		if kcmd.Func != nil {
+			kcmd.Func(kc)
+		}
+		return that.ctrlExit
+	}
+
+	result := &CtrlResult{Command: name, Args: args[1:]}
+	params := kc.Parser.Params
+	params[fmt.Sprintf(goktrl.ArgsFormatStr, kcmd.Name)] = strings.Join(kc.Args, ",")
+	status, body, err := that.ctrlRequest(kcmd.SocketName, kcmd.GetKtrlPath(), params)
+	result.ExitCode = ctrlExitCode(status, err)
+	switch {
+	case err != nil:
+		result.Error = err.Error()
+	case result.ExitCode != CtrlExitOk:
+		result.Error = strings.TrimSpace(string(body))
+	}
+	result.Status = status
+	if output == "json" {
+		if err == nil && status < http.StatusBadRequest {
+			if json.Valid(body) {
+				result.Result = json.RawMessage(body)
+			} else {
+				result.Result = strings.TrimSpace(string(body))
+			}
+		}
+		content, _ := json.Marshal(result)
+		fmt.Println(string(content))
+		return result.ExitCode
+	}
+	switch {
+	case result.Error != "":
+		fmt.Println(result.Error)
+	case kcmd.ShowTable && kcmd.TableObject != nil:
+		if err := json.Unmarshal(body, kcmd.TableObject); err != nil {
+			fmt.Println(string(body))
+			break
+		}
+		table := goktrl.NewKtrlTable()
+		table.AddRowsByListObject(kcmd.TableObject)
+		table.Render()
+	default:
+		fmt.Println(string(body))
+	}
+	return result.ExitCode
+}
+
+// ctrlRequest 请求交互式shell的服务端，返回HTTP状态码和返回结果
+func (that *Keeper) ctrlRequest(sockName, path string, params map[string]string) (int, []byte, error) {
+	resp, err := that.ctrlGet(context.Background(), sockName, path, params)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return resp.StatusCode, body, err
+}
+
+// ctrlGet 通过Unix套接字向交互式shell的服务端发送GET请求
+func (that *Keeper) ctrlGet(ctx context.Context, sockName, path string, params map[string]string) (*http.Response, error) {
+	if !strings.HasSuffix(sockName, ".sock") {
+		sockName += ".sock"
+	}
+	sockPath := gfile.TempDir(sockName)
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+			return net.Dial("unix", sockPath)
+		},
+	}}
+	query := url.Values{}
+	for k, v := range params {
+		query.Set(k, v)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("http://%s%s?%s", sockName, path, query.Encode()), nil)
+	if err != nil {
+		return nil, err
+	}
+	return client.Do(req)
+}
+
+// ctrlFetch 客户端中，请求服务端并返回HTTP状态码和原始结果，用于需要自行处理结果的命令
+func (that *Keeper) ctrlFetch(kc *goktrl.Context) (int, []byte, error) {
+	params := kc.Parser.Params
+	params[fmt.Sprintf(goktrl.ArgsFormatStr, kc.ShellCmdName)] = strings.Join(kc.Args, ",")
+	return that.ctrlRequest(kc.DefaultSocket, kc.KtrlPath, params)
+}
+
+// ctrlStatusError 服务端返回的错误
+type ctrlStatusError struct {
+	status int
+	msg    string
+}
+
+func (that *ctrlStatusError) Error() string {
+	return that.msg
+}
+
+// ctrlStream 客户端中，请求服务端并逐行处理返回结果，直到服务端结束返回或ctx被取消
+func (that *Keeper) ctrlStream(ctx context.Context, kc *goktrl.Context, handle func(line []byte)) error {
+	params := kc.Parser.Params
+	params[fmt.Sprintf(goktrl.ArgsFormatStr, kc.ShellCmdName)] = strings.Join(kc.Args, ",")
+	resp, err := that.ctrlGet(ctx, kc.DefaultSocket, kc.KtrlPath, params)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	if resp.StatusCode >= http.StatusBadRequest {
+		var msg strings.Builder
+		for scanner.Scan() {
+			msg.Write(scanner.Bytes())
+		}
+		return &ctrlStatusError{status: resp.StatusCode, msg: strings.TrimSpace(msg.String())}
+	}
+	for scanner.Scan() {
+		handle(scanner.Bytes())
+	}
+	return scanner.Err()
+}
+
+// ctrlExitCode 根据服务端返回的状态码确定非交互式执行命令的退出码
+func ctrlExitCode(status int, err error) int {
+	switch {
+	case err != nil:
+		return CtrlExitUnavailable
+	case status == http.StatusForbidden:
+		return CtrlExitDenied
+	case status >= http.StatusBadRequest:
+		return CtrlExitFailed
+	}
+	return CtrlExitOk
+}
+
+// ctrlUsage 显示非交互式执行命令的用法
+func (that *Keeper) ctrlUsage() {
+	fmt.Println("Usage: ctrl [-o json] <command> [opts] [args]")
+	fmt.Println("       ctrl [-o json] -f script.txt")
+	fmt.Println("Commands:")
+	names := that.ktrlCommands.Keys()
+	sort.Strings(names)
+	for _, name := range names {
+		kcmd := that.ktrlCommands.Get(name).(*goktrl.KCommand)
+		fmt.Printf("  %-10s %s\n", name, kcmd.Help)
+	}
+}
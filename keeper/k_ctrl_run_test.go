@@ -0,0 +1,84 @@
+package keeper
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/gogf/gf/container/gmap"
+	"github.com/gogf/gf/os/gfile"
+	goktrl "github.com/moqsien/goktrl"
+)
+
+func TestSplitCtrlLine(t *testing.T) {
+	cases := []struct {
+		line string
+		want []string
+	}{
+		{"info", []string{"info"}},
+		{"  stopa  -e web\tapi ", []string{"stopa", "-e", "web", "api"}},
+		{`adda -e web -c '{"concurrency": 4}' consumer`, []string{"adda", "-e", "web", "-c", `{"concurrency": 4}`, "consumer"}},
+		{`adda -c "{\"a\": \"b c\"}"`, []string{"adda", "-c", `{"a": "b c"}`}},
+		{`echo "a\tb" 'c\d'`, []string{"echo", `a\tb`, `c\d`}},
+		{`echo a\ b \'c`, []string{"echo", "a b", "'c"}},
+		{`echo '' ""`, []string{"echo", "", ""}},
+		{`echo ab'c d'"e"`, []string{"echo", "abc de"}},
+	}
+	for _, c := range cases {
+		got, err := splitCtrlLine(c.line)
+		if err != nil || !reflect.DeepEqual(got, c.want) {
+			t.Errorf("splitCtrlLine(%s) = %q, %v, want %q", c.line, got, err, c.want)
+		}
+	}
+	for _, line := range []string{`echo 'a`, `echo "a`, `echo a\`} {
+		if _, err := splitCtrlLine(line); err == nil {
+			t.Errorf("splitCtrlLine(%s) should fail", line)
+		}
+	}
+}
+
+// 脚本中带引号的JSON参数作为一个参数传给服务端
+func TestRunCtrlScriptQuotedArg(t *testing.T) {
+	sockName := fmt.Sprintf("keeper_ctrl_test_%d", os.Getpid())
+	sockPath := gfile.TempDir(sockName + ".sock")
+	ln, err := net.Listen("unix", sockPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(sockPath)
+	queries := make(chan url.Values, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ktrl/adda" {
+			queries <- r.URL.Query()
+		}
+		_, _ = w.Write([]byte("ok"))
+	})}
+	go func() { _ = srv.Serve(ln) }()
+	defer srv.Close()
+
+	args := os.Args
+	defer func() { os.Args = args }()
+	k := &Keeper{KCtrl: goktrl.NewKtrl(), KCtrlSocket: sockName, ktrlCommands: gmap.NewStrAnyMap(true)}
+	k.KtrlAddApp()
+	script := filepath.Join(t.TempDir(), "script.txt")
+	content := "# add a worker\nadda -e web -t worker -c '{\"concurrency\": 4}' consumer\n"
+	if err := os.WriteFile(script, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if code := k.RunCtrl([]string{"-f", script}); code != CtrlExitOk {
+		t.Fatalf("exit code = %d", code)
+	}
+	query := <-queries
+	found := false
+	for _, v := range query {
+		found = found || (len(v) == 1 && v[0] == `{"concurrency": 4}`)
+	}
+	if !found || query.Get(fmt.Sprintf(goktrl.ArgsFormatStr, "adda")) != "consumer" {
+		t.Fatalf("query = %v", query)
+	}
+}
@@ -0,0 +1,255 @@
+package keeper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/gogf/gf/container/garray"
+	"github.com/gogf/gf/errors/gerror"
+	kevent "github.com/moqsien/gokeeper/kevent"
+	kipc "github.com/moqsien/gokeeper/kipc"
+	ktype "github.com/moqsien/gokeeper/ktype"
+	goktrl "github.com/moqsien/goktrl"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+  生命周期事件，事件类型见kevent包；
+  在StartFunc中订阅事件：
+    k.Events().Subscribe(func(e *kevent.Event) { ... }, kevent.ReplicaExited, kevent.ReplicaCrashLoop)
+  在交互式shell中查看事件：
+    events [-n 20]            最近的事件
+    events -f [-e exec]       持续输出新的事件，Ctrl+C结束
+*/
+
+// Events 事件总线；多进程模式下，主进程的事件总线中包含所有子进程的事件
+func (that *Keeper) Events() *kevent.Bus {
+	return that.events
+}
+
+// PublishEvent 发布事件；子进程中的事件同时转发给主进程
+func (that *Keeper) PublishEvent(e *kevent.Event) {
+	if e.Keeper == "" {
+		e.Keeper = that.KeeperName
+	}
+	if e.Pid == 0 {
+		e.Pid = os.Getpid()
+	}
+	if e.Executor == "" && !that.IsMaster() {
+		e.Executor = that.CurrentExecutor
+	}
+	that.events.Publish(e)
+	if that.IPC == nil {
+		return
+	}
+	if err := that.IPC.Notify(kipc.NewMessage(kipc.MsgEvent).SetData(e)); err != nil && !that.Exiting {
+		logger.Warningf("向主进程发送事件失败: %v", err)
+	}
+}
+
+// SetupEventSinks 根据配置添加内置的订阅者，只在主进程中执行
+func (that *Keeper) SetupEventSinks() {
+	if !that.IsMaster() || that.KConfig == nil || that.KConfig.Get(ktype.ConfigNodeNameEvents) == nil {
+		return
+	}
+	conf := &kevent.Config{}
+	if err := that.KConfig.GetStruct(ktype.ConfigNodeNameEvents, conf); err != nil {
+		logger.Warningf("读取事件配置失败: %v", err)
+		return
+	}
+	for _, sc := range conf.Sinks {
+		sink, err := kevent.NewSink(sc)
+		if err != nil {
+			logger.Warningf("添加事件订阅者失败: %v", err)
+			continue
+		}
+		sinkType := sc.Type
+		that.events.Subscribe(kevent.SinkHandler(sink, func(e *kevent.Event, err error) {
+			logger.Warningf("事件订阅者[%s]处理事件[%s]失败: %v", sinkType, e.Type, err)
+		}), kevent.ParseTypes(sc.Types)...)
+	}
+}
+
+// eventFilter 根据事件类型和Executor过滤事件
+func eventFilter(types, execName string) func(e *kevent.Event) bool {
+	typeList := garray.NewStrArray()
+	if types != "" {
+		typeList.Append(strings.Split(types, ",")...)
+	}
+	return func(e *kevent.Event) bool {
+		if typeList.Len() > 0 && !typeList.Contains(string(e.Type)) {
+			return false
+		}
+		return execName == "" || e.Executor == execName
+	}
+}
+
+// streamEvents 持续将事件以JSON逐行发送给客户端，直到客户端断开连接
+func (that *Keeper) streamEvents(c *goktrl.Context, recent []*kevent.Event, match func(e *kevent.Event) bool) {
+	ch := make(chan *kevent.Event, 64)
+	id := that.events.Subscribe(func(e *kevent.Event) {
+		if !match(e) {
+			return
+		}
+		select {
+		case ch <- e:
+		default: // 客户端读取过慢时丢弃事件
+		}
+	})
+	defer that.events.Unsubscribe(id)
+
+	c.Writer.Header().Set("Content-Type", "application/x-ndjson")
+	c.Writer.WriteHeader(http.StatusOK)
+	write := func(e *kevent.Event) bool {
+		content, _ := json.Marshal(e)
+		if _, err := c.Writer.Write(append(content, '\n')); err != nil {
+			return false
+		}
+		c.Writer.Flush()
+		return true
+	}
+	for _, e := range recent {
+		if !write(e) {
+			return
+		}
+	}
+	c.Writer.Flush()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case e := <-ch:
+			if !write(e) {
+				return
+			}
+		}
+	}
+}
+
+// KtrlEvents 查看生命周期事件
+func (that *Keeper) KtrlEvents() {
+	type OptsEvents struct {
+		Follow   bool   `alias:"f" descr:"keep printing new events until interrupted."`
+		Number   int    `alias:"n" descr:"number of recent events, default 20."`
+		Type     string `alias:"t" descr:"only show events of these types, separated by comma."`
+		Executor string `alias:"e" descr:"only show events of the executor."`
+	}
+	type Data struct {
+		Time     string `order:"1"`
+		Type     string `order:"2"`
+		Executor string `order:"3"`
+		Replica  string `order:"4"`
+		App      string `order:"5"`
+		Pid      int    `order:"6"`
+		ExitCode string `order:"7"`
+		Message  string `order:"8"`
+	}
+	toData := func(e *kevent.Event) *Data {
+		d := &Data{Time: e.Time, Type: string(e.Type), Executor: e.Executor, Replica: e.Replica, App: e.App, Pid: e.Pid, Message: e.Message}
+		if e.ExitCode != nil {
+			d.ExitCode = fmt.Sprint(*e.ExitCode)
+		}
+		return d
+	}
+
+	handler := func(c *goktrl.Context) {
+		opt := c.Options.(*OptsEvents)
+		if opt.Number <= 0 {
+			opt.Number = 20
+		}
+		match := eventFilter(opt.Type, opt.Executor)
+		recent := []*kevent.Event{}
+		for _, e := range that.events.Recent(0) {
+			if match(e) {
+				recent = append(recent, e)
+			}
+		}
+		if len(recent) > opt.Number {
+			recent = recent[len(recent)-opt.Number:]
+		}
+		if opt.Follow {
+			that.streamEvents(c, recent, match)
+			return
+		}
+		c.Send(recent)
+	}
+	// 客户端：-f时逐行输出服务端发来的事件，否则以表格形式输出最近的事件
+	client := func(kc *goktrl.Context) {
+		opt := kc.Options.(*OptsEvents)
+		if !opt.Follow {
+			status, body, err := that.ctrlFetch(kc)
+			if code := ctrlExitCode(status, err); code != CtrlExitOk {
+				that.ctrlExit = code
+				if err == nil {
+					err = gerror.New(strings.TrimSpace(string(body)))
+				}
+				fmt.Println(err)
+				return
+			}
+			var events []*kevent.Event
+			if err = json.Unmarshal(body, &events); err != nil {
+				that.ctrlExit = CtrlExitFailed
+				fmt.Println(err)
+				return
+			}
+			result := []*Data{}
+			for _, e := range events {
+				result = append(result, toData(e))
+			}
+			table := goktrl.NewKtrlTable()
+			table.AddRowsByListObject(result)
+			table.Render()
+			return
+		}
+		// Ctrl+C时结束请求，而不是结束整个交互式shell
+		ctx, cancel := context.WithCancel(context.Background())
+		sigChan := make(chan os.Signal, 1)
+		signal.Notify(sigChan, os.Interrupt)
+		defer signal.Stop(sigChan)
+		go func() {
+			select {
+			case <-sigChan:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		defer cancel()
+		err := that.ctrlStream(ctx, kc, func(line []byte) {
+			e := &kevent.Event{}
+			if json.Unmarshal(line, e) != nil {
+				fmt.Println(string(line))
+				return
+			}
+			d := toData(e)
+			fmt.Printf("%s %-20s %s %s %s pid=%d", d.Time, d.Type, d.Executor, d.Replica, d.App, d.Pid)
+			if d.ExitCode != "" {
+				fmt.Printf(" exit=%s", d.ExitCode)
+			}
+			if d.Message != "" {
+				fmt.Printf(" %s", d.Message)
+			}
+			fmt.Println()
+		})
+		if err != nil && ctx.Err() == nil {
+			that.ctrlExit = CtrlExitUnavailable
+			if se, ok := err.(*ctrlStatusError); ok {
+				that.ctrlExit = ctrlExitCode(se.status, nil)
+			}
+			fmt.Println(err)
+		}
+	}
+
+	that.AddKtrlCommand(&goktrl.KCommand{
+		Name:        "events",
+		Help:        "show recent lifecycle events, or follow new events with -f.",
+		Opts:        &OptsEvents{},
+		Func:        client,
+		KtrlHandler: handler,
+		SocketName:  that.KCtrlSocket,
+	})
+}
@@ -0,0 +1,129 @@
+package keeper
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/gogf/gf/errors/gerror"
+	kexecutor "github.com/moqsien/gokeeper/kexecutor"
+	goktrl "github.com/moqsien/goktrl"
+)
+
+/*
+  副本子进程的退出记录，配置见kexecutor.SupervisePolicy：
+    history -e exec [-n 10]      以表格形式查看Executor最近的退出记录
+    history -e exec -v           同时查看每次退出前标准错误的最后几行
+  keeper status 也会显示每个Executor最近的几条退出记录。
+*/
+
+// statusExits keeper status中每个Executor显示的退出记录数
+const statusExits = 5
+
+// ExitHistory Executor的副本子进程最近的n条退出记录，n小于等于0时返回所有保存的记录
+func (that *Keeper) ExitHistory(execName string, n int) ([]*kexecutor.ExitRecord, error) {
+	ex, err := that.searchExecutor(execName)
+	if err != nil {
+		return nil, err
+	}
+	records := ex.ExitHistory()
+	if n > 0 && len(records) > n {
+		records = records[len(records)-n:]
+	}
+	return records, nil
+}
+
+// exitRow 以表格形式显示的退出记录
+type exitRow struct {
+	Replica  string `order:"1"`
+	Pid      int    `order:"2"`
+	ExitTime string `order:"3"`
+	Runtime  string `order:"4"`
+	ExitCode int    `order:"5"`
+	Signal   string `order:"6"`
+	Core     bool   `order:"7"`
+	LastLine string `order:"8"`
+}
+
+// renderExits 以表格形式输出退出记录
+func renderExits(records []*kexecutor.ExitRecord) {
+	rows := []*exitRow{}
+	for _, r := range records {
+		row := &exitRow{
+			Replica:  r.Replica,
+			Pid:      r.Pid,
+			ExitTime: r.ExitTime,
+			Runtime:  r.Runtime,
+			ExitCode: r.ExitCode,
+			Signal:   r.Signal,
+			Core:     r.CoreDumped,
+		}
+		if len(r.LastLines) > 0 {
+			row.LastLine = r.LastLines[len(r.LastLines)-1]
+		}
+		rows = append(rows, row)
+	}
+	table := goktrl.NewKtrlTable()
+	table.AddRowsByListObject(rows)
+	table.Render()
+}
+
+// KtrlHistory 查看副本子进程的退出记录
+func (that *Keeper) KtrlHistory() {
+	type OptsHistory struct {
+		Executor string `alias:"e" required:"true" descr:"executor from keeper."`
+		Number   int    `alias:"n" descr:"number of recent exits, all saved exits if not provided."`
+		Verbose  bool   `alias:"v" descr:"show the last lines of stderr before each exit."`
+	}
+	handler := func(c *goktrl.Context) {
+		opt := c.Options.(*OptsHistory)
+		records, err := that.ExitHistory(opt.Executor, opt.Number)
+		if err != nil {
+			c.Send(err.Error(), ctrlStatus(err))
+			return
+		}
+		c.Send(records)
+	}
+	// 客户端：默认以表格形式输出，-v时逐条输出退出记录和标准错误的最后几行
+	client := func(kc *goktrl.Context) {
+		opt := kc.Options.(*OptsHistory)
+		status, body, err := that.ctrlFetch(kc)
+		if code := ctrlExitCode(status, err); code != CtrlExitOk {
+			that.ctrlExit = code
+			if err == nil {
+				err = gerror.New(strings.TrimSpace(string(body)))
+			}
+			fmt.Println(err)
+			return
+		}
+		var records []*kexecutor.ExitRecord
+		if err = json.Unmarshal(body, &records); err != nil {
+			that.ctrlExit = CtrlExitFailed
+			fmt.Println(err)
+			return
+		}
+		if len(records) == 0 {
+			fmt.Printf("Executor: [%s] has no exit records.\n", opt.Executor)
+			return
+		}
+		if !opt.Verbose {
+			renderExits(records)
+			return
+		}
+		for _, r := range records {
+			fmt.Printf("=== %s pid: %d, started: %s, exited: %s, %s ===\n", r.Replica, r.Pid, r.StartTime, r.ExitTime, r.Summary())
+			for _, line := range r.LastLines {
+				fmt.Println(line)
+			}
+		}
+	}
+
+	that.AddKtrlCommand(&goktrl.KCommand{
+		Name:        "history",
+		Help:        "show recent exits of the replicas of an executor.",
+		Opts:        &OptsHistory{},
+		Func:        client,
+		KtrlHandler: handler,
+		SocketName:  that.KCtrlSocket,
+	})
+}
@@ -0,0 +1,133 @@
+package keeper
+
+import (
+	"time"
+
+	"github.com/gogf/gf/os/genv"
+
+	kexecutor "github.com/moqsien/gokeeper/kexecutor"
+	kipc "github.com/moqsien/gokeeper/kipc"
+	kmetrics "github.com/moqsien/gokeeper/kmetrics"
+	ktype "github.com/moqsien/gokeeper/ktype"
+	kutils "github.com/moqsien/gokeeper/kutils"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+ServeIPC 子进程中，处理主进程通过IPC通道发来的消息；
+主进程退出后通道会被关闭，子进程随之退出，避免成为孤儿进程。
+*/
+func (that *Keeper) ServeIPC(ke *kexecutor.Executor) {
+	channel, err := kipc.FromEnv()
+	if err != nil {
+		logger.Warningf("子进程IPC通道初始化失败: %v", err)
+		return
+	}
+	that.IPC = channel
+	channel.Handle(kipc.MsgStartApps, func(msg *kipc.Message) *kipc.Message {
+		return &kipc.Message{Apps: ke.StartApps(msg.Apps...)}
+	})
+	channel.Handle(kipc.MsgStopApps, func(msg *kipc.Message) *kipc.Message {
+		return &kipc.Message{Apps: ke.StopApps(msg.Apps...)}
+	})
+	channel.Handle(kipc.MsgReload, func(msg *kipc.Message) *kipc.Message {
+		return &kipc.Message{Apps: ke.ReloadApps(msg.Apps...)}
+	})
+	channel.Handle(kipc.MsgTrigger, func(msg *kipc.Message) *kipc.Message {
+		return &kipc.Message{Apps: ke.TriggerApps(msg.Apps...)}
+	})
+	channel.Handle(kipc.MsgResize, func(msg *kipc.Message) *kipc.Message {
+		req := &kipc.ResizeRequest{}
+		if err := msg.GetData(req); err != nil {
+			return &kipc.Message{Error: err.Error()}
+		}
+		return &kipc.Message{Apps: ke.ResizeApps(req.Concurrency, msg.Apps...)}
+	})
+	channel.Handle(kipc.MsgAddApp, func(msg *kipc.Message) *kipc.Message {
+		return that.serveAddApp(ke, msg)
+	})
+	channel.Handle(kipc.MsgRemoveApp, func(msg *kipc.Message) *kipc.Message {
+		var removed []string
+		for _, name := range msg.Apps {
+			if err := ke.RemoveApp(name); err != nil {
+				return &kipc.Message{Apps: removed, Error: err.Error()}
+			}
+			removed = append(removed, name)
+		}
+		return &kipc.Message{Apps: removed}
+	})
+	channel.Handle(kipc.MsgStatus, func(msg *kipc.Message) *kipc.Message {
+		return (&kipc.Message{}).SetData(ke.Status())
+	})
+	channel.Handle(kipc.MsgLogLevel, func(msg *kipc.Message) *kipc.Message {
+		if err := logger.SetLevelStr(msg.Level); err != nil {
+			return &kipc.Message{Error: err.Error()}
+		}
+		return &kipc.Message{Level: msg.Level}
+	})
+	channel.Handle(kipc.MsgStack, func(msg *kipc.Message) *kipc.Message {
+		return (&kipc.Message{}).SetData(kutils.AllStacks())
+	})
+	channel.Handle(kipc.MsgProfile, func(msg *kipc.Message) *kipc.Message {
+		req := &kipc.ProfileRequest{}
+		if err := msg.GetData(req); err != nil {
+			return &kipc.Message{Error: err.Error()}
+		}
+		data, err := that.profile(req.Kind, req.Seconds)
+		if err != nil {
+			return &kipc.Message{Error: err.Error()}
+		}
+		return (&kipc.Message{}).SetData(data)
+	})
+	channel.Handle(kipc.MsgMetrics, func(msg *kipc.Message) *kipc.Message {
+		return (&kipc.Message{}).SetData(kmetrics.DefaultRegistry.Gather())
+	})
+	go that.sendHeartbeats(ke)
+	go func() {
+		channel.Serve()
+		if !that.Exiting {
+			logger.Warning("与主进程的IPC通道已关闭，子进程退出")
+			that.Shutdown()
+		}
+	}()
+}
+
+/*
+RedirectChildStderr 子进程中，将标准错误重定向到主进程传来的管道；
+主进程读取管道中的内容后写入自己的标准输出，并在子进程退出时记录最后几行，便于排查子进程退出的原因。
+*/
+func (that *Keeper) RedirectChildStderr() {
+	fd := genv.GetVar(ktype.EnvStderrFd, 0).Int()
+	if fd <= 0 {
+		return
+	}
+	if err := kutils.RedirectStderr(fd); err != nil {
+		logger.Warningf("子进程重定向标准错误失败: %v", err)
+	}
+}
+
+/*
+sendHeartbeats 子进程中，定时向主进程发送心跳，心跳中携带子进程的运行状态；
+发送前通过Executor.CheckLiveness检查App是否仍在正常工作，检查失败时不发送心跳，
+主进程超过心跳超时未收到心跳时，按照失去响应处理子进程。
+*/
+func (that *Keeper) sendHeartbeats(ke *kexecutor.Executor) {
+	interval := ke.GetSupervisePolicy().HeartbeatInterval
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-that.IPC.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := ke.CheckLiveness(interval); err != nil {
+			logger.Warningf("存活检查失败，不发送心跳: %v", err)
+			continue
+		}
+		msg := kipc.NewMessage(kipc.MsgHeartbeat).SetData(ke.Status())
+		if err := that.IPC.Notify(msg); err != nil {
+			logger.Warningf("发送心跳失败: %v", err)
+		}
+	}
+}
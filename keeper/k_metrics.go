@@ -0,0 +1,187 @@
+package keeper
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	kexecutor "github.com/moqsien/gokeeper/kexecutor"
+	kipc "github.com/moqsien/gokeeper/kipc"
+	kmetrics "github.com/moqsien/gokeeper/kmetrics"
+	kutils "github.com/moqsien/gokeeper/kutils"
+	process "github.com/moqsien/processes"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+  Prometheus指标：开启管理接口后，通过 GET /metrics 获取；
+  包括主进程及各副本子进程的存活状态、重启次数、运行时长、CPU和内存占用，App的运行状态和重启次数，以及控制命令的执行次数；
+  多进程模式下，主进程会通过IPC通道收集各子进程中通过kmetrics注册的指标，并加上executor和replica标签。
+*/
+
+// ctrlCommandsTotal 交互式shell和管理接口执行的控制命令数
+var ctrlCommandsTotal = kmetrics.NewCounter("gokeeper_ctrl_commands_total",
+	"Control commands served, by source, command and outcome.", "source", "command", "outcome")
+
+// metricsScrapeTimeout 一次收集指标时等待副本子进程响应的最长时间，需要小于Prometheus默认的抓取超时(10s)
+const metricsScrapeTimeout = 3 * time.Second
+
+// replicaScrape 一次收集指标时从副本子进程获取的数据
+type replicaScrape struct {
+	ke      *kexecutor.Executor
+	r       *kexecutor.Replica
+	labels  []string
+	status  *kipc.Status
+	metrics []*kmetrics.Metric
+}
+
+// CollectMetrics 收集keeper的所有指标，只在主进程中执行；各副本子进程并发查询，共用metricsScrapeTimeout的期限
+func (that *Keeper) CollectMetrics() []*kmetrics.Metric {
+	var (
+		up       = &kmetrics.Metric{Name: "gokeeper_executor_child_up", Help: "Whether the child process of the executor replica is up.", Type: kmetrics.TypeGauge}
+		restarts = &kmetrics.Metric{Name: "gokeeper_executor_child_restarts_total", Help: "Restarts of the child process of the executor replica.", Type: kmetrics.TypeCounter}
+		uptime   = &kmetrics.Metric{Name: "gokeeper_process_uptime_seconds", Help: "Seconds since the process started.", Type: kmetrics.TypeGauge}
+		cpu      = &kmetrics.Metric{Name: "gokeeper_process_cpu_seconds_total", Help: "User and system CPU time of the process in seconds.", Type: kmetrics.TypeCounter}
+		rss      = &kmetrics.Metric{Name: "gokeeper_process_resident_memory_bytes", Help: "Resident memory size of the process in bytes.", Type: kmetrics.TypeGauge}
+		appUp    = &kmetrics.Metric{Name: "gokeeper_app_up", Help: "Whether the app is running.", Type: kmetrics.TypeGauge}
+		appState = &kmetrics.Metric{Name: "gokeeper_app_state", Help: "Current state of the app, the value is always 1.", Type: kmetrics.TypeGauge}
+		appRe    = &kmetrics.Metric{Name: "gokeeper_app_restarts_total", Help: "Restarts of the app.", Type: kmetrics.TypeCounter}
+		children []*kmetrics.Metric
+	)
+	procMetrics := func(pid int, start time.Time, labels ...string) {
+		if !start.IsZero() {
+			uptime.AddSample(time.Since(start).Seconds(), labels...)
+		}
+		if d, err := kutils.ProcCPUTime(pid); err == nil {
+			cpu.AddSample(d.Seconds(), labels...)
+		}
+		if n, err := kutils.ProcRSS(pid); err == nil {
+			rss.AddSample(float64(n), labels...)
+		}
+	}
+	running := process.Running
+	appMetrics := func(status *kipc.Status, labels ...string) {
+		if status == nil {
+			return
+		}
+		for _, app := range status.Apps {
+			l := append([]string{"app", app.Name}, labels...)
+			appUp.AddSample(boolValue(app.State == running.ToString()), l...)
+			appState.AddSample(1, append(l, "state", app.State)...)
+			appRe.AddSample(float64(app.Restarts), l...)
+		}
+	}
+
+	var masterStart time.Time
+	if that.StartTime != nil {
+		masterStart = that.StartTime.Time
+	}
+	procMetrics(os.Getpid(), masterStart, "role", "master")
+	var scrapes []*replicaScrape
+	that.Manager.Iterator(func(_ string, v interface{}) bool {
+		ke := v.(*kexecutor.Executor)
+		if !that.IsMutilProcModeAndInMaster() {
+			appMetrics(ke.Status(), "executor", ke.Name)
+			return true
+		}
+		for _, r := range ke.ReplicaList() {
+			labels := []string{"executor", ke.Name, "replica", strconv.Itoa(r.Index)}
+			alive := r.Process != nil && r.IsRunning()
+			up.AddSample(boolValue(alive && !r.IsUnresponsive()), labels...)
+			restarts.AddSample(float64(r.Restarts), labels...)
+			if !alive {
+				continue
+			}
+			procMetrics(r.Process.Pid, r.StartTime, append([]string{"role", "child"}, labels...)...)
+			scrapes = append(scrapes, &replicaScrape{ke: ke, r: r, labels: labels})
+		}
+		return true
+	})
+	deadline := time.Now().Add(metricsScrapeTimeout)
+	var wg sync.WaitGroup
+	for _, sc := range scrapes {
+		wg.Add(1)
+		go func(sc *replicaScrape) {
+			defer wg.Done()
+			sc.status = that.replicaStatus(sc.r, time.Until(deadline))
+			sc.metrics = that.childMetrics(sc.ke, sc.r, time.Until(deadline))
+		}(sc)
+	}
+	wg.Wait()
+	for _, sc := range scrapes {
+		appMetrics(sc.status, sc.labels...)
+		children = append(children, sc.metrics...)
+	}
+	metrics := []*kmetrics.Metric{up, restarts, uptime, cpu, rss, appUp, appState, appRe}
+	metrics = append(metrics, ctrlCommandsTotal.Collect()...)
+	metrics = append(metrics, kmetrics.DefaultRegistry.Gather()...)
+	return append(metrics, children...)
+}
+
+// replicaStatus 获取副本子进程当前的运行状态，获取失败或者超时时使用最近一次心跳中的状态
+func (that *Keeper) replicaStatus(r *kexecutor.Replica, timeout time.Duration) *kipc.Status {
+	if timeout <= 0 {
+		return r.LastStatus()
+	}
+	reply, err := r.Channel.Request(kipc.NewMessage(kipc.MsgStatus), timeout)
+	if err != nil {
+		return r.LastStatus()
+	}
+	status := &kipc.Status{}
+	if reply.GetData(status) != nil {
+		return r.LastStatus()
+	}
+	return status
+}
+
+// childMetrics 获取副本子进程中注册的指标，并加上executor和replica标签
+func (that *Keeper) childMetrics(ke *kexecutor.Executor, r *kexecutor.Replica, timeout time.Duration) []*kmetrics.Metric {
+	if timeout <= 0 {
+		logger.Warningf("获取Executor[%s]的副本[%d]的指标超时", ke.Name, r.Index)
+		return nil
+	}
+	reply, err := r.Channel.Request(kipc.NewMessage(kipc.MsgMetrics), timeout)
+	if err != nil {
+		logger.Warningf("获取Executor[%s]的副本[%d]的指标失败: %v", ke.Name, r.Index, err)
+		return nil
+	}
+	var metrics []*kmetrics.Metric
+	if err = reply.GetData(&metrics); err != nil {
+		logger.Warningf("获取Executor[%s]的副本[%d]的指标失败: %v", ke.Name, r.Index, err)
+		return nil
+	}
+	labels := map[string]string{"executor": ke.Name, "replica": strconv.Itoa(r.Index)}
+	for _, m := range metrics {
+		m.WithLabels(labels)
+	}
+	return metrics
+}
+
+// countCtrlCommand 记录一次控制命令的执行结果
+func countCtrlCommand(source, command string, status int) {
+	ctrlCommandsTotal.Inc(source, command, auditOutcome(status))
+}
+
+// GET /metrics
+func (that *Keeper) adminMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeAdminResponse(w, http.StatusMethodNotAllowed, &AdminResponse{
+			Code:    http.StatusMethodNotAllowed,
+			Message: "method " + r.Method + " not allowed",
+		})
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	if err := kmetrics.WriteText(w, that.CollectMetrics()); err != nil {
+		logger.Warningf("输出指标失败: %v", err)
+	}
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,214 @@
+package keeper
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gogf/gf/container/garray"
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	"github.com/gogf/gf/os/gfile"
+	kexecutor "github.com/moqsien/gokeeper/kexecutor"
+	kipc "github.com/moqsien/gokeeper/kipc"
+	ktype "github.com/moqsien/gokeeper/ktype"
+	kutils "github.com/moqsien/gokeeper/kutils"
+	goktrl "github.com/moqsien/goktrl"
+)
+
+/*
+  运行时诊断：通过交互式shell获取主进程或子进程的pprof profile和goroutine调用栈，默认关闭；
+    pprof -e exec [-r 0] [-s 30] -o cpu.pprof cpu   获取Executor的副本子进程的cpu profile，未指定Executor时为主进程
+    stack -e exec                                   获取Executor所有副本子进程的goroutine调用栈
+  配置示例：
+    pprof:
+      enable: true
+      maxSeconds: 60   # 采样时长的上限，默认60秒
+*/
+
+// PprofEnabled 是否开启运行时诊断
+func (that *Keeper) PprofEnabled() bool {
+	return that.KConfig != nil && that.KConfig.GetBool(ktype.ConfigNodeNamePprof+".enable", false)
+}
+
+// pprofMaxSeconds 采样时长的上限
+func (that *Keeper) pprofMaxSeconds() int {
+	n := 60
+	if that.KConfig != nil {
+		n = that.KConfig.GetInt(ktype.ConfigNodeNamePprof+".maxSeconds", n)
+	}
+	return n
+}
+
+var errPprofDisabled = gerror.NewCode(gcode.CodeNotSupported, "pprof is disabled, set pprof.enable to true in config")
+
+// profile 生成当前进程的profile
+func (that *Keeper) profile(kind string, seconds int) ([]byte, error) {
+	if !that.PprofEnabled() {
+		return nil, errPprofDisabled
+	}
+	if max := that.pprofMaxSeconds(); seconds > max {
+		return nil, gerror.NewCodef(gcode.CodeInvalidParameter, "seconds must not be greater than %d", max)
+	}
+	return kutils.Profile(kind, seconds)
+}
+
+/*
+ProfileExecutor 获取profile；未指定Executor或单进程模式下为主进程的profile，
+多进程模式下为Executor的第replica个副本子进程的profile。
+*/
+func (that *Keeper) ProfileExecutor(execName string, replica int, kind string, seconds int) ([]byte, error) {
+	if !that.PprofEnabled() {
+		return nil, errPprofDisabled
+	}
+	if !garray.NewStrArrayFrom(kutils.ProfileKinds).Contains(kind) {
+		return nil, gerror.NewCodef(gcode.CodeInvalidParameter, "unknown profile: %s, supported: %v", kind, kutils.ProfileKinds)
+	}
+	if max := that.pprofMaxSeconds(); seconds > max {
+		return nil, gerror.NewCodef(gcode.CodeInvalidParameter, "seconds must not be greater than %d", max)
+	}
+	if execName == "" || !that.IsMutilProcModeAndInMaster() {
+		if execName != "" {
+			if _, err := that.searchExecutor(execName); err != nil {
+				return nil, err
+			}
+		}
+		return that.profile(kind, seconds)
+	}
+	r, err := that.searchReplica(execName, replica)
+	if err != nil {
+		return nil, err
+	}
+	// 子进程未指定采样时长时使用默认时长，等待时间在采样时长之外再留出kipc.DefaultTimeout的余量
+	sampling := seconds
+	if sampling <= 0 {
+		sampling = kutils.DefaultProfileSeconds
+	}
+	msg := kipc.NewMessage(kipc.MsgProfile).SetData(&kipc.ProfileRequest{Kind: kind, Seconds: seconds})
+	reply, err := r.Channel.Request(msg, time.Duration(sampling)*time.Second+kipc.DefaultTimeout)
+	if err != nil {
+		return nil, gerror.NewCodef(gcode.CodeOperationFailed, "Executor[%s]的副本[%d]: %v", execName, replica, err)
+	}
+	var data []byte
+	err = reply.GetData(&data)
+	return data, err
+}
+
+// StackExecutor 获取goroutine调用栈；未指定Executor或单进程模式下为主进程的调用栈，多进程模式下为所有副本子进程的调用栈
+func (that *Keeper) StackExecutor(execName string) (string, error) {
+	if !that.PprofEnabled() {
+		return "", errPprofDisabled
+	}
+	if execName == "" || !that.IsMutilProcModeAndInMaster() {
+		if execName != "" {
+			if _, err := that.searchExecutor(execName); err != nil {
+				return "", err
+			}
+		}
+		return fmt.Sprintf("=== keeper[%s] pid: %d ===\n%s", that.KeeperName, os.Getpid(), kutils.AllStacks()), nil
+	}
+	ex, err := that.searchExecutor(execName)
+	if err != nil {
+		return "", err
+	}
+	var result strings.Builder
+	for _, r := range ex.ReplicaList() {
+		if !r.IsRunning() {
+			continue
+		}
+		var stack string
+		reply, e := r.Channel.Request(kipc.NewMessage(kipc.MsgStack), kipc.DefaultTimeout)
+		if e == nil {
+			e = reply.GetData(&stack)
+		}
+		if e != nil {
+			stack = e.Error()
+		}
+		fmt.Fprintf(&result, "=== %s pid: %d ===\n%s\n", r.Name, r.Process.Pid, stack)
+	}
+	return result.String(), nil
+}
+
+// searchReplica 查找正在运行的副本子进程
+func (that *Keeper) searchReplica(execName string, index int) (*kexecutor.Replica, error) {
+	ex, err := that.searchExecutor(execName)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := ex.Replicas.Search(index)
+	if !ok || !v.(*kexecutor.Replica).IsRunning() {
+		return nil, gerror.NewCodef(gcode.CodeNotFound, "Executor[%s]的副本[%d]未运行", execName, index)
+	}
+	return v.(*kexecutor.Replica), nil
+}
+
+// KtrlPprof 获取pprof profile并写入文件
+func (that *Keeper) KtrlPprof() {
+	type OptsPprof struct {
+		Executor string `alias:"e" descr:"executor from keeper, the master if not provided."`
+		Replica  int    `alias:"r" descr:"replica of the executor, default 0."`
+		Seconds  int    `alias:"s" descr:"seconds to sample cpu, block and mutex profiles."`
+		Out      string `alias:"o" required:"true" descr:"file to write the profile to."`
+	}
+	handler := func(c *goktrl.Context) {
+		opt := c.Options.(*OptsPprof)
+		data, err := that.ProfileExecutor(opt.Executor, opt.Replica, c.Args[0], opt.Seconds)
+		if err != nil {
+			c.Send(err.Error(), ctrlStatus(err))
+			return
+		}
+		c.Send(data)
+	}
+	// 客户端：将服务端返回的profile写入文件
+	client := func(kc *goktrl.Context) {
+		opt := kc.Options.(*OptsPprof)
+		status, body, err := that.ctrlFetch(kc)
+		if code := ctrlExitCode(status, err); code != CtrlExitOk {
+			that.ctrlExit = code
+			if err == nil {
+				err = gerror.New(strings.TrimSpace(string(body)))
+			}
+			fmt.Println(err)
+			return
+		}
+		if err = gfile.PutBytes(opt.Out, body); err != nil {
+			that.ctrlExit = CtrlExitFailed
+			fmt.Println(err)
+			return
+		}
+		fmt.Printf("%s profile written to %s (%d bytes), run `go tool pprof %s` to analyze it.\n",
+			kc.Args[0], opt.Out, len(body), opt.Out)
+	}
+
+	that.AddKtrlCommand(&goktrl.KCommand{
+		Name:            "pprof",
+		Help:            "write a pprof profile of the master or an executor to file.",
+		Opts:            &OptsPprof{},
+		Func:            client,
+		KtrlHandler:     handler,
+		SocketName:      that.KCtrlSocket,
+		ArgsRequired:    true,
+		ArgsDescription: "profile: " + strings.Join(kutils.ProfileKinds, ", ") + ".",
+	})
+}
+
+// KtrlStack 获取goroutine调用栈
+func (that *Keeper) KtrlStack() {
+	type OptsStack struct {
+		Executor string `alias:"e" descr:"executor from keeper, the master if not provided."`
+	}
+	handler := func(c *goktrl.Context) {
+		opt := c.Options.(*OptsStack)
+		that.sendResult(c)(that.StackExecutor(opt.Executor))
+	}
+
+	that.AddKtrlCommand(&goktrl.KCommand{
+		Name:        "stack",
+		Help:        "dump goroutine stacks of the master or an executor.",
+		Opts:        &OptsStack{},
+		KtrlHandler: handler,
+		SocketName:  that.KCtrlSocket,
+		Auto:        true,
+	})
+}
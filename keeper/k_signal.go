@@ -0,0 +1,101 @@
+package keeper
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gogf/gf/os/genv"
+	"github.com/gogf/gf/os/gfile"
+	kevent "github.com/moqsien/gokeeper/kevent"
+	kexecutor "github.com/moqsien/gokeeper/kexecutor"
+	ktype "github.com/moqsien/gokeeper/ktype"
+	logger "github.com/moqsien/processes/logger"
+)
+
+// keeper需要处理的退出信号
+var exitSignals = []os.Signal{syscall.SIGTERM, syscall.SIGINT, syscall.SIGQUIT}
+
+/*
+waitSignals 阻塞等待退出信号或者Shutdown调用，然后结束keeper；
+如果设置了BeforeStopFunc且其返回false，则忽略本次退出。
+*/
+func (that *Keeper) waitSignals() {
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, exitSignals...)
+	for {
+		timeout := ktype.MinShutdownTimeout
+		select {
+		case sig := <-sigChan:
+			logger.Printf("%d: 收到信号[%v]", os.Getpid(), sig)
+			if that.IsMaster() {
+				that.WriteAudit(&AuditEntry{Source: AuditSourceSignal, Actor: "unknown", Command: sig.String(), Outcome: AuditOutcomeOk})
+			}
+		case timeout = <-that.shutdownChan:
+		}
+		if that.BeforeStopFunc != nil && !that.BeforeStopFunc(that) {
+			continue
+		}
+		that.exit(timeout)
+		return
+	}
+}
+
+/*
+exit 结束keeper；
+多进程模式下的主进程中，关闭所有Executor的子进程；其他情况下关闭当前进程中运行的所有App；
+超过timeout仍未结束时强制退出。
+*/
+func (that *Keeper) exit(timeout time.Duration) {
+	that.Exiting = true
+	if that.IsMaster() {
+		that.PublishEvent(&kevent.Event{Type: kevent.KeeperStopping})
+		that.notifySystemdStopping()
+	}
+	time.AfterFunc(timeout, func() {
+		logger.Warningf("%d: 超过%v仍未结束，强制退出", os.Getpid(), timeout)
+		os.Exit(1)
+	})
+	that.StopAdmin()
+	var wg sync.WaitGroup
+	that.Manager.Iterator(func(_ string, v interface{}) bool {
+		wg.Add(1)
+		go func(ke *kexecutor.Executor) {
+			defer wg.Done()
+			if that.IsMutilProcModeAndInMaster() {
+				ke.StopReplicas()
+			} else {
+				ke.StopExecutor()
+			}
+		}(v.(*kexecutor.Executor))
+		return true
+	})
+	wg.Wait()
+	if that.IsSingleProcMode() || that.IsMutilProcModeAndInMaster() {
+		_ = gfile.Remove(that.PidFilePath)
+	}
+	that.StopTracing()
+	// 等待订阅者处理完keeper.stopping等事件
+	that.events.Close(ktype.MinShutdownTimeout / 3)
+	logger.Printf("%d: keeper已结束", os.Getpid())
+	os.Exit(0)
+}
+
+// watchMaster 子进程中，定时检查父进程是否仍为主进程，作为Pdeathsig的补充
+func (that *Keeper) watchMaster() {
+	masterPid := genv.GetVar(ktype.EnvMasterPid, 0).Int()
+	if masterPid <= 0 {
+		return
+	}
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for range ticker.C {
+		if os.Getppid() != masterPid {
+			logger.Warningf("%d: 主进程[%d]已退出，子进程随之退出", os.Getpid(), masterPid)
+			that.Shutdown()
+			return
+		}
+	}
+}
@@ -0,0 +1,211 @@
+package keeper
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"github.com/gogf/gf/crypto/gmd5"
+	"github.com/gogf/gf/os/gfile"
+	"github.com/gogf/gf/os/gtime"
+	"github.com/gogf/gf/util/gconv"
+	kexecutor "github.com/moqsien/gokeeper/kexecutor"
+	kipc "github.com/moqsien/gokeeper/kipc"
+	ktype "github.com/moqsien/gokeeper/ktype"
+	kutils "github.com/moqsien/gokeeper/kutils"
+	logger "github.com/moqsien/processes/logger"
+	signals "github.com/moqsien/processes/signals"
+)
+
+// ReplicaState 状态文件中记录的副本子进程信息
+type ReplicaState struct {
+	Index         int              `json:"index"`                   // 副本序号
+	Pid           int              `json:"pid"`                     // 子进程pid
+	StartTime     string           `json:"startTime"`               // 子进程启动时间
+	Restarts      int              `json:"restarts"`                // 子进程被重启的次数
+	Unresponsive  bool             `json:"unresponsive"`            // 子进程是否已失去响应
+	LastHeartbeat string           `json:"lastHeartbeat,omitempty"` // 最近一次收到心跳的时间
+	Apps          []*kipc.AppState `json:"apps,omitempty"`          // 子进程中App的运行状态，来自最近一次心跳
+}
+
+// ExecutorState 状态文件中记录的Executor信息
+type ExecutorState struct {
+	Name        string                  `json:"name"`               // Executor名称
+	AppsRunning []string                `json:"appsRunning"`        // 正在运行的App
+	Apps        []*kipc.AppState        `json:"apps,omitempty"`     // 单进程模式下App的运行状态
+	Replicas    []*ReplicaState         `json:"replicas,omitempty"` // 多进程模式下的副本子进程
+	Exits       []*kexecutor.ExitRecord `json:"exits,omitempty"`    // 多进程模式下副本子进程最近的退出记录
+}
+
+// KeeperState 状态文件的内容，由主进程维护
+type KeeperState struct {
+	Keeper     string           `json:"keeper"`     // keeper名称
+	MasterPid  int              `json:"masterPid"`  // 主进程pid
+	ProcMode   string           `json:"procMode"`   // 进程模式
+	StartTime  string           `json:"startTime"`  // keeper启动时间
+	UpdateTime string           `json:"updateTime"` // 状态文件更新时间
+	ConfigPath string           `json:"configPath"` // 配置文件路径
+	ConfigHash string           `json:"configHash"` // 配置内容的md5，用于判断配置是否发生变化
+	AuditPath  string           `json:"auditPath"`  // 审计日志路径
+	Executors  []*ExecutorState `json:"executors"`  // Executor列表
+}
+
+// 主进程定时更新状态文件的间隔
+const stateSaveInterval = 5 * time.Second
+
+// RunDir 运行目录，与pid文件位于同一目录下，用于保存状态文件等运行时文件
+func (that *Keeper) RunDir() string {
+	return filepath.Join(filepath.Dir(that.PidFilePath), fmt.Sprintf("%s.run", that.KeeperName))
+}
+
+// StateFilePath 状态文件路径
+func (that *Keeper) StateFilePath() string {
+	return filepath.Join(that.RunDir(), "state.json")
+}
+
+// CollectState 收集keeper当前的状态
+func (that *Keeper) CollectState() *KeeperState {
+	state := &KeeperState{
+		Keeper:     that.KeeperName,
+		MasterPid:  os.Getpid(),
+		ProcMode:   that.ProcMode.String(),
+		UpdateTime: gtime.Now().String(),
+		ConfigPath: that.KConfigPath,
+		AuditPath:  that.AuditPath(),
+		Executors:  []*ExecutorState{},
+	}
+	if that.StartTime != nil {
+		state.StartTime = that.StartTime.String()
+	}
+	if that.KConfig != nil {
+		content, _ := json.Marshal(that.KConfig.Map())
+		state.ConfigHash, _ = gmd5.EncryptBytes(content)
+	}
+	that.Manager.Iterator(func(_ string, v interface{}) bool {
+		ke := v.(*kexecutor.Executor)
+		es := &ExecutorState{Name: ke.Name, AppsRunning: gconv.Strings(ke.AppsRunning.Keys()), Exits: ke.ExitHistory()}
+		if !that.IsMutilProcModeAndInMaster() {
+			es.Apps = ke.Status().Apps
+		}
+		for _, r := range ke.ReplicaList() {
+			if r.Process == nil {
+				continue
+			}
+			rs := &ReplicaState{
+				Index:        r.Index,
+				Pid:          r.Process.Pid,
+				StartTime:    gtime.New(r.StartTime).String(),
+				Restarts:     r.Restarts,
+				Unresponsive: r.IsUnresponsive(),
+			}
+			if status := r.LastStatus(); status != nil {
+				rs.LastHeartbeat = gtime.New(r.LastHeartbeat()).String()
+				rs.Apps = status.Apps
+			}
+			es.Replicas = append(es.Replicas, rs)
+		}
+		state.Executors = append(state.Executors, es)
+		return true
+	})
+	return state
+}
+
+// SaveState 将keeper的状态写入状态文件；先写入临时文件再重命名，保证状态文件总是完整的
+func (that *Keeper) SaveState() {
+	if !that.IsMaster() || that.PidFilePath == "" {
+		return
+	}
+	that.stateLock.Lock()
+	defer that.stateLock.Unlock()
+	content, _ := json.MarshalIndent(that.CollectState(), "", "  ")
+	tmpFile := that.StateFilePath() + ".tmp"
+	if err := gfile.PutBytes(tmpFile, content); err != nil {
+		logger.Warningf("写入状态文件失败: %v", err)
+		return
+	}
+	if err := os.Rename(tmpFile, that.StateFilePath()); err != nil {
+		logger.Warningf("写入状态文件失败: %v", err)
+	}
+}
+
+// RunStateSaver 主进程中定时更新状态文件，子进程的心跳等信息通过定时更新写入
+func (that *Keeper) RunStateSaver() {
+	that.SaveState()
+	go func() {
+		ticker := time.NewTicker(stateSaveInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			if that.Exiting {
+				return
+			}
+			that.SaveState()
+		}
+	}()
+}
+
+// LoadState 读取状态文件
+func (that *Keeper) LoadState() (*KeeperState, error) {
+	content, err := os.ReadFile(that.StateFilePath())
+	if err != nil {
+		return nil, err
+	}
+	state := &KeeperState{}
+	return state, json.Unmarshal(content, state)
+}
+
+// isOrphan 判断pid对应的进程是否为本keeper残留的子进程
+func (that *Keeper) isOrphan(pid int) bool {
+	if pid <= 0 || !kutils.PidExists(pid) {
+		return false
+	}
+	environ, err := kutils.ProcEnviron(pid)
+	if err != nil {
+		return false
+	}
+	mark := fmt.Sprintf("%s=%s", ktype.EnvKeeperName, that.KeeperName)
+	for _, env := range environ {
+		if env == mark {
+			return true
+		}
+	}
+	return false
+}
+
+/*
+CleanOrphans 主进程启动时，结束状态文件中记录的、上次运行残留的子进程；
+先发送SIGTERM让子进程平滑退出，超过ktype.MinShutdownTimeout仍未退出的子进程会被强制结束。
+*/
+func (that *Keeper) CleanOrphans() {
+	state, err := that.LoadState()
+	if err != nil {
+		return
+	}
+	var orphans []int
+	for _, es := range state.Executors {
+		for _, rs := range es.Replicas {
+			if that.isOrphan(rs.Pid) {
+				logger.Warningf("发现残留的子进程[%s#%d], pid: %d, 正在结束", es.Name, rs.Index, rs.Pid)
+				_ = signals.KillPid(rs.Pid, syscall.SIGTERM, false)
+				orphans = append(orphans, rs.Pid)
+			}
+		}
+	}
+	deadline := time.Now().Add(ktype.MinShutdownTimeout)
+	for len(orphans) > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		alive := orphans[:0]
+		for _, pid := range orphans {
+			if that.isOrphan(pid) {
+				alive = append(alive, pid)
+			}
+		}
+		orphans = alive
+	}
+	for _, pid := range orphans {
+		logger.Warningf("残留的子进程[%d]未能平滑退出，强制结束", pid)
+		_ = signals.KillPid(pid, syscall.SIGKILL, false)
+	}
+}
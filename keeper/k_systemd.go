@@ -0,0 +1,235 @@
+package keeper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	"github.com/gogf/gf/os/genv"
+	"github.com/gogf/gf/os/gfile"
+	"github.com/gogf/gf/text/gstr"
+	kevent "github.com/moqsien/gokeeper/kevent"
+	kexecutor "github.com/moqsien/gokeeper/kexecutor"
+	ksystemd "github.com/moqsien/gokeeper/ksystemd"
+	ktype "github.com/moqsien/gokeeper/ktype"
+	process "github.com/moqsien/processes"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+  以systemd服务(Type=notify)运行时，主进程通过NOTIFY_SOCKET向systemd报告状态：
+    - 单进程模式下App启动后，多进程模式下所有Executor的副本子进程都报告App已启动后，发送READY=1；
+    - 副本子进程启动、退出、扩缩容时更新STATUS=；
+    - 设置了WatchdogSec时，每隔WATCHDOG_USEC/2发送一次WATCHDOG=1，副本监控卡住或者有副本子进程失去响应时不发送；
+    - 以守护进程方式重新执行二进制文件后，发送MAINPID=；
+    - 结束时发送STOPPING=1。
+  unit文件可以通过 keeper install-unit 生成，配置见ksystemd.UnitConfig。
+*/
+
+const (
+	systemdReadyCheckInterval = 200 * time.Millisecond // 检查副本子进程是否都已启动完成的间隔
+	systemdSupervisorTimeout  = 10 * time.Second       // 副本监控超过该时间未运行时，认为主进程已卡住
+)
+
+// systemdStatusEvents 需要更新STATUS的事件
+var systemdStatusEvents = []kevent.Type{
+	kevent.ExecutorStarted,
+	kevent.ExecutorStopped,
+	kevent.ExecutorScaled,
+	kevent.ReplicaStarted,
+	kevent.ReplicaExited,
+	kevent.ReplicaRestarted,
+	kevent.ReplicaUnresponsive,
+	kevent.ReplicaCrashLoop,
+}
+
+// systemdReady 是否所有Executor都已启动完成，同时返回当前状态的描述
+func (that *Keeper) systemdReady() (bool, string) {
+	if !that.IsMutilProcModeAndInMaster() {
+		apps, running := 0, process.Running
+		that.Manager.Iterator(func(_ string, v interface{}) bool {
+			for _, app := range v.(*kexecutor.Executor).Status().Apps {
+				if app.State == running.ToString() {
+					apps++
+				}
+			}
+			return true
+		})
+		return true, fmt.Sprintf("%d executors, %d apps running", that.Manager.Size(), apps)
+	}
+	// 按照Manager中的Executor计算，未能启动的Executor按照配置的副本数计入总数，避免全部启动失败时报告启动完成
+	ready, total, executors := 0, 0, 0
+	that.Manager.Iterator(func(name string, v interface{}) bool {
+		ke := v.(*kexecutor.Executor)
+		if ke.AppList.Size() == 0 {
+			// 没有App的Executor不会启动子进程
+			return true
+		}
+		executors++
+		if _, running := that.ExecutorsRunning.Search(name); !running || ke.Replicas.Size() == 0 {
+			total += ke.InitReplicas()
+			return true
+		}
+		ready += ke.ReadyReplicas()
+		total += ke.Replicas.Size()
+		return true
+	})
+	return total > 0 && ready == total, fmt.Sprintf("%d executors, %d/%d replicas ready", executors, ready, total)
+}
+
+// systemdHealthy 是否可以发送看门狗心跳：多进程模式下，运行中的Executor的副本监控正常工作，且没有失去响应的副本子进程
+func (that *Keeper) systemdHealthy() error {
+	if !that.IsMutilProcModeAndInMaster() {
+		return nil
+	}
+	var err error
+	that.ExecutorsRunning.Iterator(func(_ string, v interface{}) bool {
+		ke := v.(*kexecutor.Executor)
+		if !ke.SupervisorAlive(systemdSupervisorTimeout) {
+			err = gerror.NewCodef(gcode.CodeInternalError, "Executor[%s]的副本监控已超过%v未运行", ke.Name, systemdSupervisorTimeout)
+			return false
+		}
+		for _, r := range ke.ReplicaList() {
+			if r.IsUnresponsive() {
+				err = gerror.NewCodef(gcode.CodeInternalError, "Executor[%s]的副本[%d]已失去响应", ke.Name, r.Index)
+				return false
+			}
+		}
+		return true
+	})
+	return err
+}
+
+// RunSystemdNotify 主进程中向systemd报告启动完成，并定时发送看门狗心跳
+func (that *Keeper) RunSystemdNotify() {
+	if !that.IsMaster() || !ksystemd.Enabled() {
+		return
+	}
+	if interval := ksystemd.WatchdogInterval(); interval > 0 {
+		go that.runSystemdWatchdog(interval)
+	}
+	go func() {
+		ready, status := that.systemdReady()
+		for !ready {
+			if that.Exiting {
+				return
+			}
+			time.Sleep(systemdReadyCheckInterval)
+			ready, status = that.systemdReady()
+		}
+		if _, err := ksystemd.Ready(status); err != nil {
+			logger.Warningf("向systemd报告启动完成失败: %v", err)
+			return
+		}
+		logger.Printf("%d: 已向systemd报告启动完成: %s", os.Getpid(), status)
+		that.events.Subscribe(func(e *kevent.Event) {
+			if that.Exiting {
+				return
+			}
+			_, status := that.systemdReady()
+			if _, err := ksystemd.Status(status); err != nil {
+				logger.Warningf("向systemd更新状态失败: %v", err)
+			}
+		}, systemdStatusEvents...)
+	}()
+}
+
+// runSystemdWatchdog 定时发送看门狗心跳，直到keeper退出
+func (that *Keeper) runSystemdWatchdog(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		that.systemdWatchdog()
+	}
+}
+
+// systemdWatchdog 发送一次看门狗心跳，keeper不健康时不发送，由systemd按照WatchdogSec处理；返回是否已发送
+func (that *Keeper) systemdWatchdog() bool {
+	if err := that.systemdHealthy(); err != nil {
+		logger.Warningf("不发送看门狗心跳: %v", err)
+		return false
+	}
+	sent, err := ksystemd.Watchdog()
+	if err != nil {
+		logger.Warningf("发送看门狗心跳失败: %v", err)
+	}
+	return sent
+}
+
+// notifySystemdStopping 主进程开始结束时通知systemd
+func (that *Keeper) notifySystemdStopping() {
+	if !that.IsMaster() {
+		return
+	}
+	if _, err := ksystemd.Stopping("stopping"); err != nil {
+		logger.Warningf("向systemd报告正在结束失败: %v", err)
+	}
+}
+
+// UnitConfig 根据当前配置生成unit文件需要的配置
+func (that *Keeper) UnitConfig() *ksystemd.UnitConfig {
+	unit := &ksystemd.UnitConfig{
+		Name:        that.KeeperName,
+		ExecStart:   []string{gfile.SelfPath(), "start"},
+		PidFile:     that.PidFilePath,
+		StopTimeout: ktype.MinShutdownTimeout + 5*time.Second,
+	}
+	if conf := that.KConfigPath; conf != "" {
+		// 只指定了文件名时，使用在默认目录中搜索到的配置文件
+		if !gstr.Contains(conf, gfile.Separator) {
+			if path, _ := getFilePath(conf); path != "" {
+				conf = path
+			}
+		} else {
+			conf = gfile.Abs(conf)
+		}
+		unit.ExecStart = append(unit.ExecStart, fmt.Sprintf("--config=%s", conf))
+	}
+	if env := genv.Get("ENV_NAME"); env != "" {
+		unit.ExecStart = append(unit.ExecStart, fmt.Sprintf("--env=%s", env))
+	}
+	if that.PidFilePath != "" {
+		unit.ExecStart = append(unit.ExecStart, fmt.Sprintf("--pid=%s", that.PidFilePath))
+	}
+	if that.KConfig == nil {
+		return unit
+	}
+	node := ktype.ConfigNodeNameSystemd
+	cfg := that.KConfig
+	unit.Description = cfg.GetString(node + ".description")
+	unit.User = cfg.GetString(node + ".user")
+	unit.Group = cfg.GetString(node + ".group")
+	unit.WorkingDirectory = cfg.GetString(node + ".workingDirectory")
+	unit.Restart = cfg.GetString(node + ".restart")
+	unit.RestartSec = cfg.GetDuration(node + ".restartSec")
+	unit.Watchdog = cfg.GetDuration(node + ".watchdog")
+	unit.LimitNOFILE = cfg.GetInt(node + ".limitNOFILE")
+	unit.After = cfg.GetStrings(node + ".after")
+	unit.Environment = cfg.GetMapStrStr(node + ".environment")
+	return unit
+}
+
+/*
+InstallUnit keeper的install-unit命令的执行入口，根据当前配置生成systemd的unit文件；
+output为"-"时输出到标准输出，为空时写入/etc/systemd/system/[keeperName].service。
+*/
+func (that *Keeper) InstallUnit(output string) {
+	content := that.UnitConfig().Render()
+	if output == "-" {
+		fmt.Print(string(content))
+		os.Exit(0)
+	}
+	if output == "" {
+		output = filepath.Join("/etc/systemd/system", that.KeeperName+".service")
+	}
+	if err := gfile.PutBytes(output, content); err != nil {
+		logger.Printf("error:%v", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Unit file written to %s, run the following commands to enable it:\n", output)
+	fmt.Printf("  systemctl daemon-reload && systemctl enable --now %s\n", filepath.Base(output))
+	os.Exit(0)
+}
@@ -0,0 +1,48 @@
+package keeper
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gogf/gf/container/gmap"
+	kexecutor "github.com/moqsien/gokeeper/kexecutor"
+	ksystemd "github.com/moqsien/gokeeper/ksystemd"
+	ktype "github.com/moqsien/gokeeper/ktype"
+)
+
+func TestSystemdWatchdogWithheld(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	t.Setenv(ksystemd.EnvNotifySocket, socket)
+	k := &Keeper{ProcMode: ktype.MultiProcs, KeeperIsMaster: true, ExecutorsRunning: gmap.NewStrAnyMap(true)}
+
+	// 没有运行中的Executor时正常发送心跳
+	if !k.systemdWatchdog() {
+		t.Fatal("watchdog not sent while healthy")
+	}
+	buf := make([]byte, 64)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(buf[:n]); got != "WATCHDOG=1" {
+		t.Fatalf("payload = %q, want %q", got, "WATCHDOG=1")
+	}
+
+	// 副本监控没有运行的Executor使keeper不健康，不发送心跳
+	k.ExecutorsRunning.Set("web", kexecutor.NewExecutor("web", k))
+	if k.systemdWatchdog() {
+		t.Fatal("watchdog sent while supervisor is not alive")
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if n, err := conn.Read(buf); err == nil {
+		t.Fatalf("unexpected payload %q", buf[:n])
+	}
+}
@@ -0,0 +1,71 @@
+package keeper
+
+import (
+	"context"
+	"os"
+
+	ktrace "github.com/moqsien/gokeeper/ktrace"
+	ktype "github.com/moqsien/gokeeper/ktype"
+	logger "github.com/moqsien/processes/logger"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.10.0"
+)
+
+/*
+  链路追踪：keeper启动、子进程启动和重启、App的启动/退出/重启以及控制命令都会产生span，配置见ktrace包；
+  子进程从环境变量中读取主进程传来的trace context，子进程中的span与主进程属于同一条链路。
+*/
+
+// SetupTracing 根据配置初始化链路追踪，在StartFunc之后执行，以便用户通过ktrace.SetTracerProvider自定义
+func (that *Keeper) SetupTracing() {
+	conf := &ktrace.Config{}
+	if that.KConfig != nil && that.KConfig.Get(ktype.ConfigNodeNameTrace) != nil {
+		if err := that.KConfig.GetStruct(ktype.ConfigNodeNameTrace, conf); err != nil {
+			logger.Warningf("读取链路追踪配置失败: %v", err)
+			return
+		}
+	}
+	attrs := append([]attribute.KeyValue{
+		semconv.ServiceNameKey.String(that.KeeperName),
+		semconv.ProcessPIDKey.Int(os.Getpid()),
+	}, that.traceAttrs()...)
+	shutdown, err := ktrace.Setup(conf, attrs...)
+	if err != nil {
+		logger.Warningf("初始化链路追踪失败: %v", err)
+		return
+	}
+	that.traceShutdown = shutdown
+	that.traceCtx = ktrace.ExtractEnv(context.Background())
+}
+
+// StopTracing 导出剩余的span，在进程退出前执行
+func (that *Keeper) StopTracing() {
+	if that.traceShutdown == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), ktype.MinShutdownTimeout/3)
+	defer cancel()
+	if err := that.traceShutdown(ctx); err != nil {
+		logger.Warningf("导出span失败: %v", err)
+	}
+}
+
+// TraceContext 当前进程的trace context，子进程中包含主进程传来的trace context
+func (that *Keeper) TraceContext() context.Context {
+	if that.traceCtx == nil {
+		return context.Background()
+	}
+	return that.traceCtx
+}
+
+// traceAttrs keeper相关span的属性
+func (that *Keeper) traceAttrs() []attribute.KeyValue {
+	if that.IsMaster() {
+		return []attribute.KeyValue{ktrace.AttrKeeper.String(that.KeeperName), ktrace.AttrRole.String("master")}
+	}
+	return []attribute.KeyValue{
+		ktrace.AttrKeeper.String(that.KeeperName),
+		ktrace.AttrRole.String("child"),
+		ktrace.AttrExecutor.String(that.CurrentExecutor),
+	}
+}
@@ -1,18 +1,24 @@
 package keeper
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
+	"sync"
 	"time"
 
 	"github.com/gogf/gf/container/garray"
 	"github.com/gogf/gf/container/gmap"
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
 	"github.com/gogf/gf/os/gcfg"
 	"github.com/gogf/gf/os/genv"
 	"github.com/gogf/gf/os/gtime"
 	"github.com/moqsien/gokeeper/kapp"
 	kcli "github.com/moqsien/gokeeper/kcli"
-	kexecutor "github.com/moqsien/gokeeper/kexecutor"
+	kevent "github.com/moqsien/gokeeper/kevent"
+	kipc "github.com/moqsien/gokeeper/kipc"
 	ktype "github.com/moqsien/gokeeper/ktype"
 	goktrl "github.com/moqsien/goktrl"
 	process "github.com/moqsien/processes"
@@ -54,6 +60,17 @@ type Keeper struct {
 	KCtrl            *goktrl.Ktrl     // 交互式shell
 	KCtrlSocket      string           // 默认Unix套接字名称
 	IsCtrlInitiated  bool             // KCtrl是否已经初始化
+	ktrlCommands     *gmap.StrAnyMap  // 已注册的交互式shell命令，key: 命令名称，value: *goktrl.KCommand
+	ctrlExit         int              // 非交互式执行命令时，自行请求服务端的命令设置的退出码
+	IPC              *kipc.Channel    // 子进程中，与主进程通信的通道
+	shutdownChan     chan time.Duration
+	stateLock        sync.Mutex
+	adminServer      *http.Server // 管理接口的HTTP服务
+	authConf         *AuthConfig  // 控制命令的认证配置
+	auditLock        sync.Mutex   // 写入审计日志时加锁
+	traceCtx         context.Context
+	traceShutdown    func(ctx context.Context) error
+	events           *kevent.Bus // 生命周期事件总线
 	// InheritAddrList      []grace.InheritAddr // 多进程模式，开启平滑重启逻辑模式下需要监听的列表
 	// Graceful             *graceful.Graceful
 	// ExecutorList     *gtree.AVLTree        // Executor列表
@@ -66,9 +83,13 @@ func NewKeeper(name string) *Keeper {
 		Manager:          process.NewManager(),
 		ExecutorsRunning: gmap.NewStrAnyMap(true),
 		AppsToOperate:    garray.NewStrArray(true),
+		ProcMode:         ktype.SingleProc,
 		KeeperIsMaster:   genv.GetVar(ktype.EnvIsMaster, true).Bool(), // 通过环境变量判断是否是在主进程中执行
 		CanCtrl:          genv.GetVar(ktype.EnvCanCtrl, true).Bool(),  // 默认true
 		KCtrl:            goktrl.NewKtrl(),
+		ktrlCommands:     gmap.NewStrAnyMap(true),
+		shutdownChan:     make(chan time.Duration, 1),
+		events:           kevent.NewBus(),
 	}
 	svr.InitCli() // 初始化命令行
 	return svr
@@ -107,10 +128,16 @@ func (that *Keeper) PutMasterPidInFile() {
 	logger.Printf("写入Pid:[%d]到文件[%s]", pid, that.PidFilePath)
 }
 
-// Shutdown 主动结束进程
+// Shutdown 主动结束进程，timeout为结束过程的最长等待时间，默认为ktype.MinShutdownTimeout
 func (that *Keeper) Shutdown(timeout ...time.Duration) {
-	// TODO: graceful shutdownSingle
-	// that.graceful.shutdownSingle(timeout...)
+	t := ktype.MinShutdownTimeout
+	if len(timeout) > 0 && timeout[0] > 0 {
+		t = timeout[0]
+	}
+	select {
+	case that.shutdownChan <- t:
+	default: // 已经在结束中
+	}
 }
 
 /*
@@ -125,6 +152,10 @@ func (that *Keeper) SetupStartFunc(startFunction StartFunc) {
 			_ = logger.SetLevelStr("ERROR")
 			// 初始化Ktrl
 			that.InitKtrl()
+			// ctrl之后带有命令时，非交互式执行命令并以退出码表示执行结果
+			if len(os.Args) > 1 {
+				os.Exit(that.RunCtrl(os.Args[1:]))
+			}
 			// 启动交互式shell客户端
 			that.KCtrl.RunShell(that.KeeperName)
 			return
@@ -147,8 +178,10 @@ func (that *Keeper) SetupStartFunc(startFunction StartFunc) {
 		that.Help()
 		os.Exit(0)
 	}
-	// TODO: 监听重启信号
-	// that.graceful.graceSignal()
+	// start命令执行完毕后，阻塞等待退出信号
+	if that.StartTime != nil {
+		that.waitSignals()
+	}
 }
 
 /*
@@ -157,20 +190,23 @@ func (that *Keeper) SetupStartFunc(startFunction StartFunc) {
   如果未传入executorName，则使用与Keeper同名的默认Executor；
   如果要使用的Executor不存在，则先创建；
   本方法主要是在用户编写的startFunction中调用，以便将用户编写的App加入到对应的Executor中；
+  App为nil、名称为空或者同名App已存在于任一Executor中时返回错误，App不会被添加；
 */
-func (that *Keeper) AddAppToExecutor(app kapp.IApp, executorName ...string) {
+func (that *Keeper) AddAppToExecutor(app kapp.IApp, executorName ...string) error {
 	eName := that.KeeperName
 	if len(executorName) > 0 && len(executorName[0]) > 0 {
 		eName = executorName[0]
 	}
-
-	if execu, ok := that.Manager.Search(eName); ok {
-		execu.(*kexecutor.Executor).AddApp(app)
-	} else {
-		executor := kexecutor.NewExecutor(eName, that)
-		executor.AddApp(app)
-		that.Manager.Add(eName, executor) // Manager中保存Executor对象
+	if app == nil {
+		return gerror.NewCodef(gcode.CodeMissingParameter, "添加到Executor[%s]的App为nil", eName)
+	}
+	if ex := that.executorOfApp(app.AppName()); ex != nil {
+		return gerror.NewCodef(gcode.CodeInvalidOperation, "App[%s]已存在于Executor[%s]中", app.AppName(), ex.Name)
+	}
+	if err := that.executorOrNew(eName).AddApp(app); err != nil {
+		return gerror.WrapCodef(gcode.CodeOperationFailed, err, "添加App到Executor[%s]失败", eName)
 	}
+	return nil
 }
 
 // 是否开启多进程模式，也是由用户在startFunction中调用，开启多进程模式
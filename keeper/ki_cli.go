@@ -16,12 +16,18 @@ import (
 	"github.com/gogf/gf/util/gconv"
 	"github.com/gogf/gf/util/gutil"
 	gokeeper "github.com/moqsien/gokeeper"
+	kevent "github.com/moqsien/gokeeper/kevent"
 	kexecutor "github.com/moqsien/gokeeper/kexecutor"
+	kgrace "github.com/moqsien/gokeeper/kgrace"
+	kipc "github.com/moqsien/gokeeper/kipc"
+	ktrace "github.com/moqsien/gokeeper/ktrace"
 	ktype "github.com/moqsien/gokeeper/ktype"
 	kutils "github.com/moqsien/gokeeper/kutils"
+	goktrl "github.com/moqsien/goktrl"
 	logger "github.com/moqsien/processes/logger"
 	signals "github.com/moqsien/processes/signals"
 	"github.com/spf13/cobra"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // 将配置文件中的配置信息写入到logger配置中
@@ -61,7 +67,8 @@ func (that *Keeper) SetRootCommand(c *cobra.Command) {
 }
 
 func (that *Keeper) SetAppsToOperate(appNames []string) {
-	// 设置要操作的App的名称列表
+	// 设置要操作的App的名称列表，替换之前设置的列表
+	that.AppsToOperate.Clear()
 	for _, name := range appNames {
 		if gstr.ContainsI(name, ",") {
 			appNameArray := gstr.SplitAndTrim(name, ",")
@@ -163,21 +170,24 @@ func (that *Keeper) InitLogSetting(config *gcfg.Config) error {
 
 /*
   CheckKeeperStart 检查keeper是否已经启动过；
-  如果主进程的pid文件名存在，则说明keeper已经启动过。
+  如果主进程的pid文件名存在，则说明keeper已经启动过；
+  如果主进程已经不存在，则清理状态文件中记录的残留子进程。
 */
 func (that *Keeper) CheckKeeperForStart() {
+	// 子进程由主进程启动，无需检查
+	if !that.IsMaster() {
+		return
+	}
 	pidFile := that.PidFilePath
 	var keeperPid = 0
 	if gfile.IsFile(pidFile) {
 		keeperPid = gconv.Int(gstr.Trim(gfile.GetContents(pidFile)))
 	}
-	if keeperPid == 0 {
-		return
-	}
-	if signals.CheckPidExist(keeperPid) {
+	if keeperPid != 0 && kutils.PidExists(keeperPid) {
 		logger.Fatalf("Keeper [%d] is already running.", keeperPid)
 	}
-	return
+	// 上次运行的主进程已经不存在，结束其残留的子进程，避免重复运行
+	that.CleanOrphans()
 }
 
 /*
@@ -197,11 +207,23 @@ func (that *Keeper) RunExecutors() {
 		})
 	} else if that.ProcMode == ktype.MultiProcs && !that.IsMaster() {
 		// 多进程模式下，且在子进程中，执行对应的Executor中的所有App
+		// 运行时添加的App由主进程通过环境变量传来，需要先重新创建
+		that.restoreDynamicApps()
 		if exec, existed := that.Manager.Search(that.CurrentExecutor); existed {
-			ke, ok := exec.(kexecutor.Executor)
+			ke, ok := exec.(*kexecutor.Executor)
 			if ok {
+				// 通过IPC通道接收主进程的控制消息，App启动时产生的事件也通过IPC通道转发给主进程
+				that.ServeIPC(ke)
 				ke.StartAllApps()
 				ke.Pid = os.Getpid()
+				// 通知主进程App已全部启动，主进程据此判断keeper是否启动完成
+				if that.IPC != nil {
+					if err := that.IPC.Notify(kipc.NewMessage(kipc.MsgReady).SetData(ke.Status())); err != nil {
+						logger.Warningf("向主进程报告启动完成失败: %v", err)
+					}
+				}
+				// 主进程退出后，子进程随之退出
+				go that.watchMaster()
 			}
 		}
 	} else {
@@ -210,6 +232,7 @@ func (that *Keeper) RunExecutors() {
 			ke := v.(*kexecutor.Executor)
 			ke.StartAllApps()
 			ke.Pid = os.Getpid()
+			that.PublishEvent(&kevent.Event{Type: kevent.ExecutorStarted, Executor: ke.Name})
 			return true
 		})
 	}
@@ -223,6 +246,15 @@ func (that *Keeper) RunKeeper() {
 	}
 	//记录启动时间
 	that.StartTime = gtime.Now()
+	// 子进程的标准错误写入与主进程之间的管道，需要在执行StartFunction之前重定向，以便记录启动过程中的panic
+	that.RedirectChildStderr()
+
+	// 读取systemd或者主进程传来的监听套接字，App通过kgrace.Listen按名称获取
+	if err := kgrace.Inherit(); err != nil {
+		logger.Warningf("读取继承的监听套接字失败: %v", err)
+	} else if files := kgrace.Files(); len(files) > 0 && that.IsMaster() {
+		logger.Printf("%d: 继承了%d个监听套接字", os.Getpid(), len(files))
+	}
 
 	// TODO: 初始化平滑重启的钩子函数
 	// that.InitGraceful()
@@ -236,24 +268,44 @@ func (that *Keeper) RunKeeper() {
 		return
 	}
 	that.StartFunction(that)
+	// 配置文件中声明的App通过StartFunc中注册的App工厂创建
+	_ = that.AddConfigApps()
 
 	// TODO: 设置优雅退出时候需要做的工作
 	// that.Graceful.SetShutdown(15*time.Second, that.FirstStop, that.BeforeExiting)
 
-	// 启动交互式shell的服务端
-	if that.CanCtrl {
+	// 启动交互式shell的服务端；多进程模式下子进程通过IPC通道接收控制消息，无需启动
+	that.LoadAuthConfig()
+	// 初始化链路追踪，keeper启动过程中的span都属于keeper.start
+	that.SetupTracing()
+	// 添加配置中的事件订阅者
+	that.SetupEventSinks()
+	ctx, span := ktrace.Start(that.TraceContext(), "keeper.start",
+		append(that.traceAttrs(), attribute.String("keeper.mode", that.ProcMode.String()))...)
+	that.traceCtx = ctx
+	defer span.End()
+	if that.CanCtrl && that.IsMaster() {
 		that.InitKtrl()
-		go that.KCtrl.RunCtrl()
+		go that.RunCtrlServer()
 	}
+	// 启动管理接口的HTTP服务，只在主进程中执行
+	that.RunAdmin()
 
 	// 执行Manager中的Executor
 	that.RunExecutors()
 
 	if that.ProcMode == ktype.SingleProc || that.IsMutilProcModeAndInMaster() {
 		that.PutMasterPidInFile()
+		// 定时将keeper的状态写入状态文件
+		that.RunStateSaver()
+		// 以systemd服务运行时，报告启动完成并发送看门狗心跳
+		that.RunSystemdNotify()
 	}
 
 	logger.Printf("%d: 服务已经初始化完成, %d 个协程被创建.", os.Getpid(), runtime.NumGoroutine())
+	if that.IsMaster() {
+		that.PublishEvent(&kevent.Event{Type: kevent.KeeperStarted})
+	}
 }
 
 // StopKeeper keeper的stop、reload、quit命令的执行入口
@@ -281,12 +333,106 @@ func (that *Keeper) StopKeeper(sig string) {
 		os.Exit(0)
 	}
 	err := signals.KillPid(keeperPid, signals.ToSignal(sigNo), false)
+	that.Audit(AuditSourceCli, sig, []string{sigNo, gconv.String(keeperPid)}, err)
 	if err != nil {
 		logger.Printf("error:%v", err)
 	}
 	os.Exit(0)
 }
 
+// ShowStatus keeper的status命令的执行入口，从状态文件中读取keeper的状态，无需连接交互式shell的服务端
+func (that *Keeper) ShowStatus() {
+	state, err := that.LoadState()
+	if err != nil {
+		logger.Printf("Keeper is not running, no state file found: %v", err)
+		os.Exit(1)
+	}
+	running := "not running"
+	if kutils.PidExists(state.MasterPid) {
+		running = "running"
+	}
+	fmt.Printf("Keeper:      %s\n", state.Keeper)
+	fmt.Printf("Master Pid:  %d (%s)\n", state.MasterPid, running)
+	fmt.Printf("ProcMode:    %s\n", state.ProcMode)
+	fmt.Printf("Start Time:  %s\n", state.StartTime)
+	fmt.Printf("Update Time: %s\n", state.UpdateTime)
+	fmt.Printf("Config:      %s (md5: %s)\n", state.ConfigPath, state.ConfigHash)
+
+	type Data struct {
+		Executor    string `order:"1"`
+		Replica     string `order:"2"`
+		Pid         int    `order:"3"`
+		StartTime   string `order:"4"`
+		Restarts    int    `order:"5"`
+		Responsive  bool   `order:"6"`
+		AppsRunning string `order:"7"`
+	}
+	result := []*Data{}
+	for _, es := range state.Executors {
+		if len(es.Replicas) == 0 {
+			result = append(result, &Data{
+				Executor:    es.Name,
+				Replica:     "-",
+				Pid:         state.MasterPid,
+				StartTime:   state.StartTime,
+				Responsive:  true,
+				AppsRunning: kutils.SliceToString(es.AppsRunning),
+			})
+			continue
+		}
+		for _, rs := range es.Replicas {
+			var apps []string
+			for _, app := range rs.Apps {
+				if app.State == "Running" {
+					apps = append(apps, app.Name)
+				}
+			}
+			result = append(result, &Data{
+				Executor:    es.Name,
+				Replica:     gconv.String(rs.Index),
+				Pid:         rs.Pid,
+				StartTime:   rs.StartTime,
+				Restarts:    rs.Restarts,
+				Responsive:  !rs.Unresponsive,
+				AppsRunning: kutils.SliceToString(apps),
+			})
+		}
+	}
+	table := goktrl.NewKtrlTable()
+	table.AddRowsByListObject(result)
+	table.Render()
+
+	// 每个Executor最近的几条退出记录
+	var exits []*kexecutor.ExitRecord
+	for _, es := range state.Executors {
+		if len(es.Exits) > statusExits {
+			es.Exits = es.Exits[len(es.Exits)-statusExits:]
+		}
+		exits = append(exits, es.Exits...)
+	}
+	if len(exits) > 0 {
+		fmt.Println("Recent Exits:")
+		renderExits(exits)
+	}
+	os.Exit(0)
+}
+
+// ScaleKeeper keeper的scale命令的执行入口，通过交互式shell的服务端调整Executor的副本数；退出码见CtrlExit*
+func (that *Keeper) ScaleKeeper(execName string, replicas int) {
+	params := map[string]string{
+		"executor": execName,
+		fmt.Sprintf(goktrl.ArgsFormatStr, "scale"): gconv.String(replicas),
+	}
+	status, body, err := that.ctrlRequest(that.KeeperName, "/ktrl/scale", params)
+	code := ctrlExitCode(status, err)
+	if err != nil {
+		logger.Printf("error:%v", err)
+	} else {
+		fmt.Println(gstr.Trim(string(body)))
+	}
+	os.Exit(code)
+}
+
 /*
   Version相关信息
 */
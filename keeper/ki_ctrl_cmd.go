@@ -2,13 +2,25 @@ package keeper
 
 import (
 	"fmt"
-	"strings"
 
+	"github.com/gogf/gf/util/gconv"
 	kexecutor "github.com/moqsien/gokeeper/kexecutor"
 	kutils "github.com/moqsien/gokeeper/kutils"
 	goktrl "github.com/moqsien/goktrl"
+	logger "github.com/moqsien/processes/logger"
 )
 
+// sendResult 返回命令的执行结果，执行失败时返回错误信息以及对应的HTTP状态码
+func (that *Keeper) sendResult(c *goktrl.Context) func(r string, err error) {
+	return func(r string, err error) {
+		if err != nil {
+			c.Send(err.Error(), ctrlStatus(err))
+			return
+		}
+		c.Send(r)
+	}
+}
+
 func (that *Keeper) KCtrlCheckExecutor(c *goktrl.Context) bool {
 	eName := c.Parser.GetOpt("executor")
 	_, ok := that.Manager.Search(eName)
@@ -23,7 +35,7 @@ func (that *Keeper) KCtrlCheckExecutor(c *goktrl.Context) bool {
 */
 
 func (that *Keeper) kCtrlVersion() {
-	that.KCtrl.AddKtrlCommand(&goktrl.KCommand{
+	that.AddKtrlCommand(&goktrl.KCommand{
 		Name: "version",
 		Help: "show keeper version info.",
 		Func: func(k *goktrl.Context) {
@@ -40,29 +52,40 @@ func (that *Keeper) kCtrlInfo() {
 		ProcMode   string `order:"2"`
 		Executor   string `order:"3"`
 		Pid        int    `order:"4"`
-		Apps       string `order:"6"`
-		AppsRunnig string `order:"5"`
+		Replicas   int    `order:"5"`
+		Apps       string `order:"7"`
+		AppsRunnig string `order:"6"`
+		Scheduled  string `order:"8"`
+		Jobs       string `order:"9"`
+		Workers    string `order:"10"`
 	}
 
 	var Result = []*Data{} // 客户端和服务端在不同进程中，此处无影响
 
-	that.KCtrl.AddKtrlCommand(&goktrl.KCommand{
+	that.AddKtrlCommand(&goktrl.KCommand{
 		Name: "info",
 		Help: "show keeper info",
 		KtrlHandler: func(c *goktrl.Context) {
+			result := []*Data{}
 			that.Manager.Iterator(func(_ string, v interface{}) bool {
 				executor := v.(*kexecutor.Executor)
-				Result = append(Result, &Data{
+				// 多进程模式下每个Executor只通过IPC通道获取一次子进程的运行状态
+				statuses := that.executorStatus(executor)
+				result = append(result, &Data{
 					Keeper:     that.KeeperName,
 					ProcMode:   that.ProcMode.String(),
 					Executor:   executor.Name,
 					Pid:        executor.Pid,
+					Replicas:   executor.Replicas.Size(),
 					Apps:       kutils.SliceToString(executor.AppList.Keys()),
-					AppsRunnig: kutils.SliceToString(executor.AppsRunning.Keys()),
+					AppsRunnig: kutils.SliceToString(that.appsRunning(executor, statuses)),
+					Scheduled:  scheduledApps(statuses),
+					Jobs:       jobApps(statuses),
+					Workers:    workerApps(statuses),
 				})
 				return true
 			})
-			c.Send(Result)
+			c.Send(result)
 		},
 		Auto:        true,
 		ShowTable:   true,
@@ -76,12 +99,12 @@ func (that *Keeper) KtrlStartExecutor() {
 	type OptsStartExecutor struct {
 		Executor string `alias:"e" required:"true" descr:"executor from keeper."`
 	}
-	that.KCtrl.AddKtrlCommand(&goktrl.KCommand{
+	that.AddKtrlCommand(&goktrl.KCommand{
 		Name: "starte",
 		Help: "start an executor.",
 		KtrlHandler: func(c *goktrl.Context) {
 			opt := c.Options.(*OptsStartExecutor)
-			c.Send(that.StartExecutor(opt.Executor, c.Args...))
+			that.sendResult(c)(that.StartExecutor(opt.Executor, kutils.TrimEmpty(c.Args)...))
 		},
 		Opts:            &OptsStartExecutor{},
 		ArgsDescription: "apps to start.",
@@ -98,42 +121,10 @@ func (that *Keeper) KtrlStartApps() {
 	}
 	handler := func(c *goktrl.Context) {
 		opt := c.Options.(*OptsStartApps)
-		exec, found := that.Manager.Search(opt.Executor)
-		if !found {
-			c.Send(fmt.Sprintf("Executor: %s is not found!", opt.Executor))
-		} else {
-			if that.IsMutilProcModeAndInMaster() {
-				ex := exec.(*kexecutor.Executor)
-				if ex.ProcessPlus != nil && ex.IsRunning() {
-					result, _ := c.GetResult(opt.Executor) // 转发给子进程，由子进程运行app
-					for _, v := range strings.Split(string(result), ",") {
-						ex.AppsRunning.Set(v, struct{}{})
-					}
-					c.Send(fmt.Sprintf("Apps: [%s] started running.", string(result)))
-				} else {
-					ex.ProcessPlus = nil
-					c.Send(that.StartExecutor(opt.Executor, c.Args...)) // 启动新进程来运行app
-				}
-			} else {
-				ex := exec.(*kexecutor.Executor)
-				started := []string{}
-				var err error
-				for _, name := range c.Args {
-					err = ex.StartApp(name)
-					if err == nil {
-						started = append(started, name)
-					}
-				}
-				if that.IsSingleProcMode() {
-					c.Send(fmt.Sprintf("Apps: [%s] started running.", kutils.SliceToString(started)))
-				} else {
-					c.Send(kutils.SliceToString(started))
-				}
-			}
-		}
+		that.sendResult(c)(that.StartApps(opt.Executor, c.Args...))
 	}
 
-	that.KCtrl.AddKtrlCommand(&goktrl.KCommand{
+	that.AddKtrlCommand(&goktrl.KCommand{
 		Name:            "starta",
 		Help:            "start apps.",
 		KtrlHandler:     handler,
@@ -152,19 +143,10 @@ func (that *Keeper) KtrlStopExecutor() {
 	}
 	handler := func(c *goktrl.Context) {
 		opt := c.Options.(*OptsStopExecutor)
-		if exec, found := that.Manager.Search(opt.Executor); !found {
-			c.Send(fmt.Sprintf("Executor: %s is not found!", opt.Executor))
-		} else {
-			ex := exec.(*kexecutor.Executor)
-			ex.StopProc(true)
-			ex.AppsRunning.Clear()
-			ex.Pid = 0
-			that.ExecutorsRunning.Remove(ex.Name)
-			c.Send(fmt.Sprintf("Executor: %s stopped!", opt.Executor))
-		}
+		that.sendResult(c)(that.StopExecutor(opt.Executor))
 	}
 
-	that.KCtrl.AddKtrlCommand(&goktrl.KCommand{
+	that.AddKtrlCommand(&goktrl.KCommand{
 		Name:        "stope",
 		Help:        "stop an Executor.",
 		Opts:        &OptsStopExecutor{},
@@ -180,41 +162,10 @@ func (that *Keeper) KtrlStopApps() {
 	}
 	handler := func(c *goktrl.Context) {
 		opt := c.Options.(*OptsStopApps)
-		exec, found := that.Manager.Search(opt.Executor)
-		if !found {
-			c.Send(fmt.Sprintf("Executor: %s is not found!", opt.Executor))
-		} else {
-			if that.IsMutilProcModeAndInMaster() {
-				ex := exec.(*kexecutor.Executor)
-				if ex.ProcessPlus != nil && ex.IsRunning() {
-					result, _ := c.GetResult(opt.Executor) // 转发给子进程，由子进程运行app
-					for _, v := range strings.Split(string(result), ",") {
-						ex.AppsRunning.Remove(v)
-					}
-					c.Send(fmt.Sprintf("Apps: [%s] stopped running.", string(result)))
-				} else {
-					c.Send(fmt.Sprintf("Executor: %s is not running!", opt.Executor))
-				}
-			} else {
-				ex := exec.(*kexecutor.Executor)
-				stopped := []string{}
-				var err error
-				for _, name := range c.Args {
-					err = ex.StopApp(name)
-					if err == nil {
-						stopped = append(stopped, name)
-					}
-				}
-				if that.IsSingleProcMode() {
-					c.Send(fmt.Sprintf("Apps: [%s] stopped running.", kutils.SliceToString(stopped)))
-				} else {
-					c.Send(kutils.SliceToString(stopped))
-				}
-			}
-		}
+		that.sendResult(c)(that.StopApps(opt.Executor, c.Args...))
 	}
 
-	that.KCtrl.AddKtrlCommand(&goktrl.KCommand{
+	that.AddKtrlCommand(&goktrl.KCommand{
 		Name:            "stopa",
 		Help:            "stop apps.",
 		Opts:            &OptsStopApps{},
@@ -226,16 +177,91 @@ func (that *Keeper) KtrlStopApps() {
 	})
 }
 
+// KtrlScale 调整Executor的副本数
+func (that *Keeper) KtrlScale() {
+	type OptsScale struct {
+		Executor string `alias:"e" required:"true" descr:"executor from keeper."`
+	}
+	handler := func(c *goktrl.Context) {
+		opt := c.Options.(*OptsScale)
+		that.sendResult(c)(that.ScaleExecutor(opt.Executor, gconv.Int(c.Args[0])))
+	}
+
+	that.AddKtrlCommand(&goktrl.KCommand{
+		Name:            "scale",
+		Help:            "scale replicas of an executor.",
+		Opts:            &OptsScale{},
+		KtrlHandler:     handler,
+		SocketName:      that.KCtrlSocket,
+		ArgsRequired:    true,
+		ArgsDescription: "number of replicas.",
+		Auto:            true,
+	})
+}
+
+// KtrlReload 重启Executor中的App，未传入AppName时重启所有正在运行的App
 func (that *Keeper) KtrlReload() {
-	reload := func(k *goktrl.Context) {}
-	handler := func(c *goktrl.Context) {}
+	type OptsReload struct {
+		Executor string `alias:"e" required:"true" descr:"executor from keeper."`
+	}
+	handler := func(c *goktrl.Context) {
+		opt := c.Options.(*OptsReload)
+		that.sendResult(c)(that.ReloadApps(opt.Executor, kutils.TrimEmpty(c.Args)...))
+	}
 
-	that.KCtrl.AddKtrlCommand(&goktrl.KCommand{
-		Name:        "reload",
-		Help:        "reload apps or an executor.",
-		Func:        reload,
-		KtrlHandler: handler,
-		SocketName:  that.KCtrlSocket,
+	that.AddKtrlCommand(&goktrl.KCommand{
+		Name:            "reload",
+		Help:            "reload apps of an executor.",
+		Opts:            &OptsReload{},
+		KtrlHandler:     handler,
+		SocketName:      that.KCtrlSocket,
+		ArgsDescription: "apps to reload, reload all running apps if not provided.",
+		Auto:            true,
+	})
+}
+
+// KtrlTrigger 立即执行一次定时App
+func (that *Keeper) KtrlTrigger() {
+	type OptsTrigger struct {
+		Executor string `alias:"e" required:"true" descr:"executor from keeper."`
+	}
+	handler := func(c *goktrl.Context) {
+		opt := c.Options.(*OptsTrigger)
+		that.sendResult(c)(that.TriggerApps(opt.Executor, kutils.TrimEmpty(c.Args)...))
+	}
+
+	that.AddKtrlCommand(&goktrl.KCommand{
+		Name:            "trigger",
+		Help:            "run scheduled apps immediately.",
+		Opts:            &OptsTrigger{},
+		KtrlHandler:     handler,
+		SocketName:      that.KCtrlSocket,
+		ArgsRequired:    true,
+		ArgsDescription: "scheduled apps to run.",
+		Auto:            true,
+	})
+}
+
+// KtrlResize 调整WorkerApp的并发数
+func (that *Keeper) KtrlResize() {
+	type OptsResize struct {
+		Executor    string `alias:"e" required:"true" descr:"executor from keeper."`
+		Concurrency int    `alias:"n" required:"true" descr:"number of workers."`
+	}
+	handler := func(c *goktrl.Context) {
+		opt := c.Options.(*OptsResize)
+		that.sendResult(c)(that.ResizeApps(opt.Executor, opt.Concurrency, kutils.TrimEmpty(c.Args)...))
+	}
+
+	that.AddKtrlCommand(&goktrl.KCommand{
+		Name:            "resize",
+		Help:            "resize concurrency of worker apps.",
+		Opts:            &OptsResize{},
+		KtrlHandler:     handler,
+		SocketName:      that.KCtrlSocket,
+		ArgsRequired:    true,
+		ArgsDescription: "worker apps to resize.",
+		Auto:            true,
 	})
 }
 
@@ -243,7 +269,7 @@ func (that *Keeper) KtrlDebug() {
 	debug := func(k *goktrl.Context) {}
 	handler := func(c *goktrl.Context) {}
 
-	that.KCtrl.AddKtrlCommand(&goktrl.KCommand{
+	that.AddKtrlCommand(&goktrl.KCommand{
 		Name:        "debug",
 		Help:        "set debug mode",
 		Func:        debug,
@@ -252,8 +278,79 @@ func (that *Keeper) KtrlDebug() {
 	})
 }
 
+// KtrlLog 修改日志级别，未传入Executor时修改keeper中所有进程的日志级别
 func (that *Keeper) KtrlLog() {
+	type OptsLog struct {
+		Executor string `alias:"e" descr:"executor from keeper."`
+	}
+	handler := func(c *goktrl.Context) {
+		opt := c.Options.(*OptsLog)
+		that.sendResult(c)(that.SetLogLevel(opt.Executor, c.Args[0]))
+	}
+
+	that.AddKtrlCommand(&goktrl.KCommand{
+		Name:            "log",
+		Help:            "set log level.",
+		Opts:            &OptsLog{},
+		KtrlHandler:     handler,
+		SocketName:      that.KCtrlSocket,
+		ArgsRequired:    true,
+		ArgsDescription: "log level, such as ALL, DEV, INFO, NOTI, WARN, ERRO, CRIT.",
+		Auto:            true,
+	})
+}
+
+// KtrlAudit 查看最近的审计日志
+func (that *Keeper) KtrlAudit() {
+	type OptsAudit struct {
+		Number int `alias:"n" descr:"number of recent entries, default 20."`
+	}
+	type Data struct {
+		Time    string `order:"1"`
+		Source  string `order:"2"`
+		Actor   string `order:"3"`
+		Command string `order:"4"`
+		Targets string `order:"5"`
+		Outcome string `order:"6"`
+		Detail  string `order:"7"`
+	}
+
+	var Result = []*Data{}
+
+	handler := func(c *goktrl.Context) {
+		opt := c.Options.(*OptsAudit)
+		if opt.Number <= 0 {
+			opt.Number = 20
+		}
+		entries, err := that.ReadAudit(opt.Number)
+		if err != nil {
+			logger.Warningf("读取审计日志失败: %v", err)
+		}
+		result := []*Data{}
+		for _, e := range entries {
+			result = append(result, &Data{
+				Time:    e.Time,
+				Source:  e.Source,
+				Actor:   e.Actor,
+				Command: e.Command,
+				Targets: kutils.SliceToString(e.Targets),
+				Outcome: e.Outcome,
+				Detail:  e.Detail,
+			})
+		}
+		c.Send(result)
+	}
 
+	that.AddKtrlCommand(&goktrl.KCommand{
+		Name:        "audit",
+		Help:        "show recent entries of the audit log.",
+		Opts:        &OptsAudit{},
+		KtrlHandler: handler,
+		Auto:        true,
+		ShowTable:   true,
+		TableObject: &Result,
+		SocketName:  that.KCtrlSocket,
+	})
 }
 
 func (that *Keeper) InitKtrl() {
@@ -268,10 +365,21 @@ func (that *Keeper) InitKtrl() {
 		that.KtrlStartExecutor()
 		that.KtrlStartApps()
 		that.KtrlStopExecutor()
-		that.KtrlStartApps()
+		that.KtrlStopApps()
+		that.KtrlScale()
 		that.KtrlReload()
+		that.KtrlTrigger()
+		that.KtrlResize()
+		that.KtrlAddApp()
+		that.KtrlRemoveApps()
+		that.KtrlMoveApps()
 		that.KtrlDebug()
 		that.KtrlLog()
+		that.KtrlAudit()
+		that.KtrlPprof()
+		that.KtrlStack()
+		that.KtrlEvents()
+		that.KtrlHistory()
 	}
 	that.IsCtrlInitiated = true // KCtrl标记为已初始化
 }
@@ -2,51 +2,392 @@ package keeper
 
 import (
 	"fmt"
+	"net/http"
+	"strings"
 
+	"github.com/gogf/gf/container/garray"
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	"github.com/gogf/gf/os/glog"
+	"github.com/gogf/gf/util/gconv"
 	"github.com/moqsien/gokeeper/kexecutor"
+	kipc "github.com/moqsien/gokeeper/kipc"
+	kutils "github.com/moqsien/gokeeper/kutils"
+	logger "github.com/moqsien/processes/logger"
 )
 
 /*
   交互式shell命令服务端调用的相关的Keeper方法
 */
 
+// searchExecutor 根据名称查找Executor
+func (that *Keeper) searchExecutor(execName string) (*kexecutor.Executor, error) {
+	executor, found := that.Manager.Search(execName)
+	if !found {
+		return nil, gerror.NewCodef(gcode.CodeNotFound, "Executor: [%s] is not found!", execName)
+	}
+	return executor.(*kexecutor.Executor), nil
+}
+
 // StartExecutor 交互式shell开启Executor，只在主进程中执行
-func (that *Keeper) StartExecutor(execName string, appNames ...string) (r string) {
+func (that *Keeper) StartExecutor(execName string, appNames ...string) (string, error) {
+	if !that.IsMutilProcModeAndInMaster() {
+		return "", gerror.NewCode(gcode.CodeNotSupported, "Cannot start executor in single process mode.")
+	}
+	ex, err := that.searchExecutor(execName)
+	if err != nil {
+		return "", err
+	}
+	if _, ok := that.ExecutorsRunning.Search(execName); ok {
+		return "", gerror.NewCodef(gcode.CodeInvalidOperation, "Executor: [%s] is already running!", execName)
+	}
+	// 要启动的App列表只对本次启动有效，启动后清空，避免影响之后的操作
+	that.SetAppsToOperate(appNames)
+	ex.NewChildProcForStart(that.KConfigPath)
+	that.AppsToOperate.Clear()
+	if ex.Replicas.Size() == 0 {
+		return "", gerror.NewCodef(gcode.CodeOperationFailed, "Executor: [%s] start failed!", execName)
+	}
+	return fmt.Sprintf("Executor: [%s] started!", execName), nil
+}
+
+// StartApps 交互式shell启动Apps；多进程模式下由主进程通过IPC通道转发给子进程，子进程未运行时启动新的子进程
+func (that *Keeper) StartApps(execName string, appNames ...string) (string, error) {
+	ex, err := that.searchExecutor(execName)
+	if err != nil {
+		return "", err
+	}
+	var started []string
+	if that.IsMutilProcModeAndInMaster() {
+		if ex.ProcessPlus == nil || !ex.IsRunning() {
+			ex.ProcessPlus = nil
+			return that.StartExecutor(execName, appNames...) // 启动新进程来运行app
+		}
+		// 通过IPC通道转发给子进程，由子进程运行app
+		replies, err := ex.RequestReplicas(kipc.NewMessage(kipc.MsgStartApps, appNames...))
+		started = appsFromReplies(replies)
+		for _, v := range started {
+			ex.AppsRunning.Set(v, struct{}{})
+		}
+		if err != nil {
+			return "", replicasError("start", appNames, started, err)
+		}
+	} else {
+		started = ex.StartApps(appNames...)
+	}
+	if len(started) == 0 {
+		return "", gerror.NewCodef(gcode.CodeOperationFailed, "Apps: [%s] start failed.", kutils.SliceToString(appNames))
+	}
+	return fmt.Sprintf("Apps: [%s] started running.", kutils.SliceToString(started)), nil
+}
+
+// ScaleExecutor 交互式shell调整Executor的副本数，只在多进程模式的主进程中执行
+func (that *Keeper) ScaleExecutor(execName string, replicas int) (string, error) {
+	if !that.IsMutilProcModeAndInMaster() {
+		return "", gerror.NewCode(gcode.CodeNotSupported, "Cannot scale executor in single process mode.")
+	}
+	ex, err := that.searchExecutor(execName)
+	if err != nil {
+		return "", err
+	}
+	if err := ex.Scale(replicas); err != nil {
+		return "", gerror.NewCodef(gcode.CodeOperationFailed, "Executor: [%s] scale failed: %v", execName, err)
+	}
+	return fmt.Sprintf("Executor: [%s] scaled to %d replicas.", execName, replicas), nil
+}
+
+// StopExecutor 交互式shell停止Executor；多进程模式下结束其所有副本子进程
+func (that *Keeper) StopExecutor(execName string) (string, error) {
+	ex, err := that.searchExecutor(execName)
+	if err != nil {
+		return "", err
+	}
+	if that.IsMutilProcModeAndInMaster() {
+		ex.StopReplicas()
+	} else {
+		ex.StopExecutor()
+	}
+	ex.AppsRunning.Clear()
+	that.ExecutorsRunning.Remove(ex.Name)
+	return fmt.Sprintf("Executor: [%s] stopped!", execName), nil
+}
+
+// StopApps 交互式shell停止Apps；多进程模式下由主进程通过IPC通道转发给子进程
+func (that *Keeper) StopApps(execName string, appNames ...string) (string, error) {
+	ex, err := that.searchExecutor(execName)
+	if err != nil {
+		return "", err
+	}
+	var stopped []string
+	if that.IsMutilProcModeAndInMaster() {
+		if ex.ProcessPlus == nil || !ex.IsRunning() {
+			return "", gerror.NewCodef(gcode.CodeInvalidOperation, "Executor: [%s] is not running!", execName)
+		}
+		// 通过IPC通道转发给子进程，由子进程停止app
+		replies, err := ex.RequestReplicas(kipc.NewMessage(kipc.MsgStopApps, appNames...))
+		stopped = appsFromReplies(replies)
+		for _, v := range stopped {
+			ex.AppsRunning.Remove(v)
+		}
+		if err != nil {
+			return "", replicasError("stop", appNames, stopped, err)
+		}
+	} else {
+		stopped = ex.StopApps(appNames...)
+	}
+	if len(stopped) == 0 {
+		return "", gerror.NewCodef(gcode.CodeOperationFailed, "Apps: [%s] stop failed.", kutils.SliceToString(appNames))
+	}
+	return fmt.Sprintf("Apps: [%s] stopped running.", kutils.SliceToString(stopped)), nil
+}
+
+// ReloadApps 交互式shell重启Executor中的App；多进程模式下由主进程通过IPC通道转发给子进程
+func (that *Keeper) ReloadApps(execName string, appNames ...string) (string, error) {
+	ex, err := that.searchExecutor(execName)
+	if err != nil {
+		return "", err
+	}
+	var reloaded []string
+	if that.IsMutilProcModeAndInMaster() {
+		replies, err := ex.RequestReplicas(kipc.NewMessage(kipc.MsgReload, appNames...))
+		reloaded = appsFromReplies(replies)
+		if err != nil {
+			return "", replicasError("reload", appNames, reloaded, err)
+		}
+	} else {
+		reloaded = ex.ReloadApps(appNames...)
+	}
+	if len(appNames) > 0 && len(reloaded) == 0 {
+		return "", gerror.NewCodef(gcode.CodeOperationFailed, "Apps: [%s] reload failed.", kutils.SliceToString(appNames))
+	}
+	return fmt.Sprintf("Apps: [%s] reloaded.", kutils.SliceToString(reloaded)), nil
+}
+
+// TriggerApps 交互式shell立即执行一次定时App；多进程模式下由主进程通过IPC通道转发给子进程
+func (that *Keeper) TriggerApps(execName string, appNames ...string) (string, error) {
+	ex, err := that.searchExecutor(execName)
+	if err != nil {
+		return "", err
+	}
+	var triggered []string
+	if that.IsMutilProcModeAndInMaster() {
+		if ex.ProcessPlus == nil || !ex.IsRunning() {
+			return "", gerror.NewCodef(gcode.CodeInvalidOperation, "Executor: [%s] is not running!", execName)
+		}
+		replies, err := ex.RequestReplicas(kipc.NewMessage(kipc.MsgTrigger, appNames...))
+		triggered = appsFromReplies(replies)
+		if err != nil {
+			return "", replicasError("trigger", appNames, triggered, err)
+		}
+	} else {
+		triggered = ex.TriggerApps(appNames...)
+	}
+	if len(triggered) == 0 {
+		return "", gerror.NewCodef(gcode.CodeOperationFailed, "Apps: [%s] trigger failed.", kutils.SliceToString(appNames))
+	}
+	return fmt.Sprintf("Apps: [%s] triggered.", kutils.SliceToString(triggered)), nil
+}
+
+// ResizeApps 交互式shell调整WorkerApp的并发数；多进程模式下由主进程通过IPC通道转发给子进程
+func (that *Keeper) ResizeApps(execName string, concurrency int, appNames ...string) (string, error) {
+	if concurrency < 1 {
+		return "", gerror.NewCodef(gcode.CodeInvalidParameter, "concurrency must be at least 1: %d", concurrency)
+	}
+	ex, err := that.searchExecutor(execName)
+	if err != nil {
+		return "", err
+	}
+	var resized []string
 	if that.IsMutilProcModeAndInMaster() {
-		if _, ok := that.ExecutorsRunning.Search(execName); !ok {
-			r = fmt.Sprintf("Executor: [%s] is already running!", execName)
-		} else {
-			executor, found := that.Manager.Search(execName)
-			if !found {
-				r = fmt.Sprintf("Executor: [%s] is not found!", execName)
-				return
+		if ex.ProcessPlus == nil || !ex.IsRunning() {
+			return "", gerror.NewCodef(gcode.CodeInvalidOperation, "Executor: [%s] is not running!", execName)
+		}
+		msg := kipc.NewMessage(kipc.MsgResize, appNames...).SetData(&kipc.ResizeRequest{Concurrency: concurrency})
+		replies, err := ex.RequestReplicas(msg)
+		resized = appsFromReplies(replies)
+		if err != nil {
+			return "", replicasError("resize", appNames, resized, err)
+		}
+	} else {
+		resized = ex.ResizeApps(concurrency, appNames...)
+	}
+	if len(resized) == 0 {
+		return "", gerror.NewCodef(gcode.CodeOperationFailed, "Apps: [%s] resize failed.", kutils.SliceToString(appNames))
+	}
+	return fmt.Sprintf("Apps: [%s] resized to %d workers.", kutils.SliceToString(resized), concurrency), nil
+}
+
+/*
+SetLogLevel 交互式shell修改日志级别；execName为空时修改主进程以及所有子进程的日志级别；
+多进程模式下有副本子进程修改失败时返回错误，其他副本子进程的修改仍然生效。
+*/
+func (that *Keeper) SetLogLevel(execName string, level string) (string, error) {
+	// 先检查日志级别，避免主进程和子进程只有一部分修改成功
+	if err := glog.New().SetLevelStr(level); err != nil {
+		return "", gerror.NewCodef(gcode.CodeInvalidParameter, "Set log level failed: %v", err)
+	}
+	if execName == "" {
+		_ = logger.SetLevelStr(level)
+	} else if _, err := that.searchExecutor(execName); err != nil {
+		return "", err
+	}
+	var failed []string
+	that.Manager.Iterator(func(name string, v interface{}) bool {
+		if execName != "" && execName != name {
+			return true
+		}
+		if that.IsMutilProcModeAndInMaster() {
+			if _, err := v.(*kexecutor.Executor).RequestReplicas(&kipc.Message{Type: kipc.MsgLogLevel, Level: level}); err != nil {
+				failed = append(failed, err.Error())
 			}
-			if that.IsMutilProcModeAndInMaster() {
-				that.SetAppsToOperate(appNames)
-				executor.(*kexecutor.Executor).NewChildProcForStart(that.KConfigPath)
+		} else if execName != "" {
+			// 单进程模式下，所有Executor共用一个进程的日志配置
+			_ = logger.SetLevelStr(level)
+		}
+		return true
+	})
+	if len(failed) > 0 {
+		return "", gerror.NewCodef(gcode.CodeOperationFailed, "Set log level to %s failed: %s", level, strings.Join(failed, "; "))
+	}
+	return fmt.Sprintf("Log level set to %s.", level), nil
+}
+
+// ctrlStatus 根据错误码获取控制命令返回的HTTP状态码
+func ctrlStatus(err error) int {
+	switch gerror.Code(err) {
+	case gcode.CodeNil:
+		return http.StatusOK
+	case gcode.CodeNotFound:
+		return http.StatusNotFound
+	case gcode.CodeInvalidParameter, gcode.CodeMissingParameter:
+		return http.StatusBadRequest
+	case gcode.CodeNotSupported, gcode.CodeInvalidOperation:
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// appsRunning 获取Executor中正在运行的App；多进程模式下从statuses中的子进程运行状态获取
+func (that *Keeper) appsRunning(ex *kexecutor.Executor, statuses []*kipc.Status) []string {
+	if !that.IsMutilProcModeAndInMaster() || ex.Replicas.Size() == 0 {
+		return gconv.Strings(ex.AppsRunning.Keys())
+	}
+	apps := garray.NewSortedStrArray().SetUnique(true)
+	for _, status := range statuses {
+		apps.Append(status.AppsRunning...)
+	}
+	return apps.Slice()
+}
+
+/*
+scheduledApps 获取Executor中定时App的执行情况，用于info命令显示；
+格式为 app[schedule next: 下一次执行时间 last: 最近一次执行时间 runs: 执行次数 error: 最近一次的错误]，多进程模式下按子进程的pid分别显示。
+*/
+func scheduledApps(statuses []*kipc.Status) string {
+	var items []string
+	for _, status := range statuses {
+		for _, app := range status.Apps {
+			if app.Schedule == "" {
+				continue
+			}
+			item := fmt.Sprintf("%s[%s", app.Name, app.Schedule)
+			if len(statuses) > 1 {
+				item = fmt.Sprintf("%s@%d[%s", app.Name, status.Pid, app.Schedule)
+			}
+			if app.NextRun != "" {
+				item += " next: " + app.NextRun
+			}
+			if app.LastRun != "" {
+				item += fmt.Sprintf(" last: %s runs: %d", app.LastRun, app.Runs)
 			}
-			r = fmt.Sprintf("Executor: [%s] started!", execName)
+			if app.LastError != "" {
+				item += " error: " + app.LastError
+			}
+			items = append(items, item+"]")
 		}
-	} else if that.IsSingleProcMode() {
-		r = "Cannot start executor in single process mode."
 	}
-	return
+	return strings.Join(items, ", ")
 }
 
-// StartApps 交互式shell启动Apps，只在子进程中或者单进程模式下执行
-func (that *Keeper) StartApps(execName string, appNames ...string) (r string) {
-	if !that.IsMaster() && !that.IsSingleProcMode() {
+/*
+jobApps 获取Executor中一次性任务的执行情况，用于info命令显示；
+格式为 app[状态 last: 最近一次执行时间 runs: 执行次数 error: 最近一次的错误]，多进程模式下按子进程的pid分别显示。
+*/
+func jobApps(statuses []*kipc.Status) string {
+	var items []string
+	for _, status := range statuses {
+		for _, app := range status.Apps {
+			if !app.Job {
+				continue
+			}
+			item := fmt.Sprintf("%s[%s", app.Name, app.State)
+			if len(statuses) > 1 {
+				item = fmt.Sprintf("%s@%d[%s", app.Name, status.Pid, app.State)
+			}
+			if app.LastRun != "" {
+				item += fmt.Sprintf(" last: %s runs: %d", app.LastRun, app.Runs)
+			}
+			if app.LastError != "" {
+				item += " error: " + app.LastError
+			}
+			items = append(items, item+"]")
+		}
+	}
+	return strings.Join(items, ", ")
+}
 
-	} else if that.IsSingleProcMode() {
+/*
+workerApps 获取Executor中WorkerApp的并发数和任务数，用于info命令显示；
+格式为 app[workers: worker数 inflight: 正在处理的任务数 processed: 处理成功的任务数 failed: 处理失败的任务数]，多进程模式下按子进程的pid分别显示。
+*/
+func workerApps(statuses []*kipc.Status) string {
+	var items []string
+	for _, status := range statuses {
+		for _, app := range status.Apps {
+			if app.Workers == 0 && app.Processed == 0 && app.Failed == 0 {
+				continue
+			}
+			name := app.Name
+			if len(statuses) > 1 {
+				name = fmt.Sprintf("%s@%d", app.Name, status.Pid)
+			}
+			items = append(items, fmt.Sprintf("%s[workers: %d inflight: %d processed: %d failed: %d]",
+				name, app.Workers, app.InFlight, app.Processed, app.Failed))
+		}
+	}
+	return strings.Join(items, ", ")
+}
 
+// executorStatus 获取Executor的运行状态；多进程模式下通过IPC通道从每个副本子进程获取
+func (that *Keeper) executorStatus(ex *kexecutor.Executor) []*kipc.Status {
+	if !that.IsMutilProcModeAndInMaster() {
+		return []*kipc.Status{ex.Status()}
+	}
+	replies, _ := ex.RequestReplicas(kipc.NewMessage(kipc.MsgStatus))
+	result := []*kipc.Status{}
+	for _, reply := range replies {
+		status := &kipc.Status{}
+		if err := reply.GetData(status); err == nil {
+			result = append(result, status)
+		}
 	}
-	return
+	return result
 }
 
-func (that *Keeper) StopExecutor(execName string) (r string) {
-	return
+// replicasError 多进程模式下有副本子进程处理失败时返回的错误，done为处理成功的副本子进程中的App
+func replicasError(action string, appNames, done []string, err error) error {
+	if len(done) == 0 {
+		return gerror.NewCodef(gcode.CodeOperationFailed, "Apps: [%s] %s failed: %v", kutils.SliceToString(appNames), action, err)
+	}
+	return gerror.NewCodef(gcode.CodeOperationFailed, "Apps: [%s] %s failed on some replicas, succeeded: [%s]: %v",
+		kutils.SliceToString(appNames), action, kutils.SliceToString(done), err)
 }
 
-func (that *Keeper) StopApps(appName string) (r string) {
-	return
+// appsFromReplies 合并子进程回复消息中的App列表
+func appsFromReplies(replies []*kipc.Message) []string {
+	apps := garray.NewSortedStrArray().SetUnique(true)
+	for _, reply := range replies {
+		apps.Append(reply.Apps...)
+	}
+	return apps.Slice()
 }
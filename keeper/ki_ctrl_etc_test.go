@@ -0,0 +1,22 @@
+package keeper
+
+import (
+	"net/http"
+	"testing"
+
+	logger "github.com/moqsien/processes/logger"
+)
+
+func TestSetLogLevelInvalid(t *testing.T) {
+	level := logger.GetLevel()
+	k := &Keeper{}
+	for _, execName := range []string{"", "web"} {
+		_, err := k.SetLogLevel(execName, "verbose")
+		if status := ctrlStatus(err); status != http.StatusBadRequest {
+			t.Fatalf("SetLogLevel(%q) status = %d, err: %v", execName, status, err)
+		}
+	}
+	if logger.GetLevel() != level {
+		t.Fatalf("log level changed to %d", logger.GetLevel())
+	}
+}
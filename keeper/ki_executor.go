@@ -34,3 +34,12 @@ func (that *Keeper) ProcManager() *process.Manager {
 func (that *Keeper) GetExecutorsRunning() *gmap.StrAnyMap {
 	return that.ExecutorsRunning
 }
+
+func (that *Keeper) GetKeeperName() string {
+	return that.KeeperName
+}
+
+// AuditSupervisor 记录主进程自动执行的操作，如重启、结束子进程
+func (that *Keeper) AuditSupervisor(command string, targets []string, err error) {
+	that.Audit(AuditSourceSupervisor, command, targets, err)
+}
@@ -0,0 +1,202 @@
+package kevent
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogf/gf/container/garray"
+	"github.com/gogf/gf/os/gtime"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+  kevent keeper的生命周期事件；
+  keeper在Executor、副本子进程和App的状态发生变化时发布事件，用户可以在StartFunc中通过Keeper.Events().Subscribe订阅，
+  也可以在配置中添加webhook、执行命令、写入文件等内置的订阅者(见sink.go)。
+  多进程模式下，子进程中产生的事件会通过IPC通道转发给主进程，内置的订阅者只在主进程中运行。
+*/
+
+// Type 事件类型
+type Type string
+
+const (
+	KeeperStarted       Type = "keeper.started"       // keeper启动完成
+	KeeperStopping      Type = "keeper.stopping"      // keeper开始退出
+	ExecutorStarted     Type = "executor.started"     // Executor启动
+	ExecutorStopped     Type = "executor.stopped"     // Executor关闭
+	ExecutorScaled      Type = "executor.scaled"      // Executor的副本数发生变化
+	ReplicaStarted      Type = "replica.started"      // 副本子进程启动
+	ReplicaExited       Type = "replica.exited"       // 副本子进程非主动退出
+	ReplicaRestarted    Type = "replica.restarted"    // 副本子进程被重启
+	ReplicaUnresponsive Type = "replica.unresponsive" // 副本子进程失去响应
+	ReplicaCrashLoop    Type = "replica.crashloop"    // 副本子进程在短时间内反复退出
+	AppStarted          Type = "app.started"          // App启动
+	AppStopped          Type = "app.stopped"          // App关闭
+	AppFailed           Type = "app.failed"           // App的Execute返回错误
+	AppReloaded         Type = "app.reloaded"         // App重启完成
+	AppRunFailed        Type = "app.runfailed"        // 定时App或一次性任务的一次执行返回错误或者超时
+	AppCompleted        Type = "app.completed"        // 一次性任务执行成功
+	AppAdded            Type = "app.added"            // 运行时添加App
+	AppRemoved          Type = "app.removed"          // 运行时移除App
+)
+
+// Event 事件
+type Event struct {
+	Id       uint64                 `json:"id"`                 // 事件序号，在当前进程中递增
+	Type     Type                   `json:"type"`               // 事件类型
+	Time     string                 `json:"time"`               // 事件发生时间
+	Keeper   string                 `json:"keeper"`             // keeper名称
+	Pid      int                    `json:"pid"`                // 产生事件的进程pid
+	Executor string                 `json:"executor,omitempty"` // Executor名称
+	Replica  string                 `json:"replica,omitempty"`  // 副本名称，如 myExecutor#0
+	App      string                 `json:"app,omitempty"`      // App名称
+	ExitCode *int                   `json:"exitCode,omitempty"` // 子进程的退出码
+	Message  string                 `json:"message,omitempty"`  // 事件描述，如错误信息
+	Data     map[string]interface{} `json:"data,omitempty"`     // 其他数据
+}
+
+// New 创建事件
+func New(t Type) *Event {
+	return &Event{Type: t}
+}
+
+// WithExitCode 设置退出码
+func (that *Event) WithExitCode(code int) *Event {
+	that.ExitCode = &code
+	return that
+}
+
+// Set 设置其他数据
+func (that *Event) Set(key string, value interface{}) *Event {
+	if that.Data == nil {
+		that.Data = map[string]interface{}{}
+	}
+	that.Data[key] = value
+	return that
+}
+
+// Handler 事件处理方法
+type Handler func(e *Event)
+
+// subscriberBuffer 每个订阅者缓存的事件数，处理过慢导致缓存已满时丢弃新事件
+const subscriberBuffer = 256
+
+// recentSize 保存最近事件的数量
+const recentSize = 200
+
+type subscriber struct {
+	types   *garray.StrArray
+	events  chan *Event
+	handler Handler
+	done    chan struct{}
+}
+
+/*
+Bus 事件总线；
+每个订阅者在单独的goroutine中按顺序处理事件，处理过慢不会阻塞事件的发布者。
+*/
+type Bus struct {
+	lock        sync.RWMutex
+	lastId      uint64
+	lastSub     int
+	subscribers map[int]*subscriber
+	recent      []*Event
+}
+
+// NewBus Bus工厂函数
+func NewBus() *Bus {
+	return &Bus{subscribers: map[int]*subscriber{}}
+}
+
+// Subscribe 订阅事件，未传入types时订阅所有事件，返回订阅序号
+func (that *Bus) Subscribe(handler Handler, types ...Type) int {
+	sub := &subscriber{
+		types:   garray.NewStrArray(),
+		events:  make(chan *Event, subscriberBuffer),
+		handler: handler,
+		done:    make(chan struct{}),
+	}
+	for _, t := range types {
+		sub.types.Append(string(t))
+	}
+	go func() {
+		defer close(sub.done)
+		for e := range sub.events {
+			sub.handler(e)
+		}
+	}()
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	that.lastSub++
+	that.subscribers[that.lastSub] = sub
+	return that.lastSub
+}
+
+// Unsubscribe 取消订阅
+func (that *Bus) Unsubscribe(id int) {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	if sub, ok := that.subscribers[id]; ok {
+		delete(that.subscribers, id)
+		close(sub.events)
+	}
+}
+
+// Publish 发布事件
+func (that *Bus) Publish(e *Event) {
+	if e.Id == 0 {
+		e.Id = atomic.AddUint64(&that.lastId, 1)
+	}
+	if e.Time == "" {
+		e.Time = gtime.Now().Format("Y-m-d H:i:s.u")
+	}
+	that.lock.Lock()
+	that.recent = append(that.recent, e)
+	if len(that.recent) > recentSize {
+		that.recent = that.recent[len(that.recent)-recentSize:]
+	}
+	that.lock.Unlock()
+
+	that.lock.RLock()
+	defer that.lock.RUnlock()
+	for id, sub := range that.subscribers {
+		if sub.types.Len() > 0 && !sub.types.Contains(string(e.Type)) {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			logger.Warningf("事件订阅者[%d]处理过慢，丢弃事件: %s", id, e.Type)
+		}
+	}
+}
+
+// Close 取消所有订阅，并等待订阅者处理完已发布的事件，最多等待timeout；进程退出前调用
+func (that *Bus) Close(timeout time.Duration) {
+	that.lock.Lock()
+	subs := that.subscribers
+	that.subscribers = map[int]*subscriber{}
+	that.lock.Unlock()
+	deadline := time.After(timeout)
+	for _, sub := range subs {
+		close(sub.events)
+	}
+	for _, sub := range subs {
+		select {
+		case <-sub.done:
+		case <-deadline:
+			return
+		}
+	}
+}
+
+// Recent 最近的n条事件，n小于等于0时返回所有保存的事件
+func (that *Bus) Recent(n int) []*Event {
+	that.lock.RLock()
+	defer that.lock.RUnlock()
+	if n <= 0 || n > len(that.recent) {
+		n = len(that.recent)
+	}
+	return append([]*Event{}, that.recent[len(that.recent)-n:]...)
+}
@@ -0,0 +1,206 @@
+package kevent
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	"github.com/gogf/gf/util/gconv"
+)
+
+/*
+  内置的订阅者，配置示例：
+    events:
+      sinks:
+        - type: webhook                  # 以POST请求发送事件的JSON
+          url: http://127.0.0.1:9000/hook
+          headers: {Authorization: "Bearer xxx"}
+          types: [replica.exited, replica.crashloop, app.failed]  # 为空时订阅所有事件
+        - type: exec                     # 执行命令，事件的JSON写入标准输入，同时设置KEEPER_EVENT_*环境变量
+          command: /usr/local/bin/notify.sh
+          args: [--channel, ops]
+        - type: file                     # 将事件的JSON逐行追加到文件
+          path: /var/log/keeper/events.log
+*/
+
+const (
+	SinkWebhook = "webhook"
+	SinkExec    = "exec"
+	SinkFile    = "file"
+)
+
+// sinkTimeout webhook请求和命令执行的超时时间
+const sinkTimeout = 10 * time.Second
+
+// SinkConfig 内置订阅者的配置
+type SinkConfig struct {
+	Type    string            `json:"type"`
+	Url     string            `json:"url"`
+	Headers map[string]string `json:"headers"`
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Path    string            `json:"path"`
+	Types   []string          `json:"types"`
+}
+
+// Config 事件相关配置
+type Config struct {
+	Sinks []*SinkConfig `json:"sinks"`
+}
+
+// Sink 订阅者
+type Sink interface {
+	Handle(e *Event) error
+}
+
+// NewSink 根据配置创建订阅者
+func NewSink(conf *SinkConfig) (Sink, error) {
+	switch conf.Type {
+	case SinkWebhook:
+		if conf.Url == "" {
+			return nil, gerror.NewCode(gcode.CodeMissingParameter, "webhook sink: url is required")
+		}
+		return NewWebhookSink(conf.Url, conf.Headers), nil
+	case SinkExec:
+		if conf.Command == "" {
+			return nil, gerror.NewCode(gcode.CodeMissingParameter, "exec sink: command is required")
+		}
+		return NewExecSink(conf.Command, conf.Args...), nil
+	case SinkFile:
+		if conf.Path == "" {
+			return nil, gerror.NewCode(gcode.CodeMissingParameter, "file sink: path is required")
+		}
+		return NewFileSink(conf.Path)
+	}
+	return nil, gerror.NewCodef(gcode.CodeInvalidParameter, "unknown sink type: %s", conf.Type)
+}
+
+// WebhookSink 以POST请求发送事件
+type WebhookSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewWebhookSink WebhookSink工厂函数
+func NewWebhookSink(url string, headers map[string]string) *WebhookSink {
+	return &WebhookSink{url: url, headers: headers, client: &http.Client{Timeout: sinkTimeout}}
+}
+
+// Handle 实现Sink接口
+func (that *WebhookSink) Handle(e *Event) error {
+	content, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, that.url, bytes.NewReader(content))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range that.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := that.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+	if resp.StatusCode >= http.StatusBadRequest {
+		return gerror.Newf("webhook sink: %s returned %s", that.url, resp.Status)
+	}
+	return nil
+}
+
+// ExecSink 执行命令，事件的JSON写入命令的标准输入
+type ExecSink struct {
+	command string
+	args    []string
+}
+
+// NewExecSink ExecSink工厂函数
+func NewExecSink(command string, args ...string) *ExecSink {
+	return &ExecSink{command: command, args: args}
+}
+
+// Handle 实现Sink接口
+func (that *ExecSink) Handle(e *Event) error {
+	content, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), sinkTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, that.command, that.args...)
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Env = append(os.Environ(),
+		"KEEPER_EVENT_TYPE="+string(e.Type),
+		"KEEPER_EVENT_KEEPER="+e.Keeper,
+		"KEEPER_EVENT_EXECUTOR="+e.Executor,
+		"KEEPER_EVENT_REPLICA="+e.Replica,
+		"KEEPER_EVENT_APP="+e.App,
+		"KEEPER_EVENT_MESSAGE="+e.Message,
+	)
+	if e.ExitCode != nil {
+		cmd.Env = append(cmd.Env, "KEEPER_EVENT_EXIT_CODE="+gconv.String(*e.ExitCode))
+	}
+	if out, err := cmd.CombinedOutput(); err != nil {
+		return gerror.Newf("exec sink: %s: %v: %s", that.command, err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+// FileSink 将事件的JSON逐行追加到文件
+type FileSink struct {
+	lock sync.Mutex
+	f    *os.File
+}
+
+// NewFileSink FileSink工厂函数
+func NewFileSink(path string) (*FileSink, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &FileSink{f: f}, nil
+}
+
+// Handle 实现Sink接口
+func (that *FileSink) Handle(e *Event) error {
+	content, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	_, err = that.f.Write(append(content, '\n'))
+	return err
+}
+
+// SinkHandler 将Sink包装为Handler，处理失败时调用onError
+func SinkHandler(s Sink, onError func(e *Event, err error)) Handler {
+	return func(e *Event) {
+		if err := s.Handle(e); err != nil && onError != nil {
+			onError(e, err)
+		}
+	}
+}
+
+// ParseTypes 将字符串转换为事件类型
+func ParseTypes(types []string) []Type {
+	list := make([]Type, 0, len(types))
+	for _, t := range types {
+		list = append(list, Type(t))
+	}
+	return list
+}
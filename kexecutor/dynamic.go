@@ -0,0 +1,76 @@
+package kexecutor
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/gogf/gf/container/garray"
+	"github.com/gogf/gf/util/gconv"
+	kipc "github.com/moqsien/gokeeper/kipc"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+  运行时添加、移除App时，主进程中记录的变更；
+  运行时添加的App通过环境变量传给新的副本子进程，由子进程重新创建；
+  子进程会重新执行StartFunc，运行时移除的App也通过环境变量传给子进程，由子进程在启动App前移除。
+  子进程需要启动的App列表(AppsToStart)为空时表示启动所有App，因此移除App时不从列表中删除，避免列表变空后启动所有App。
+*/
+
+// AppSpec 获取App在本Executor中的来源：运行时添加的App返回添加时的AppSpec，否则为StartFunc中添加到本Executor的App
+func (that *Executor) AppSpec(name string) *kipc.AppSpec {
+	if v, found := that.dynamicApps.Search(name); found {
+		return v.(*kipc.AppSpec)
+	}
+	return &kipc.AppSpec{Name: name, From: that.Name}
+}
+
+// RecordAddedApp 主进程中记录运行时添加的App，移回StartFunc中所属的Executor时无需重新创建
+func (that *Executor) RecordAddedApp(spec *kipc.AppSpec) {
+	if spec.From != that.Name {
+		that.dynamicApps.Set(spec.Name, spec)
+	}
+	that.removedApps.Remove(spec.Name)
+	if len(that.AppsToStart) > 0 && !garray.NewStrArrayFrom(that.AppsToStart).Contains(spec.Name) {
+		that.AppsToStart = append(that.AppsToStart, spec.Name)
+	}
+}
+
+// RecordRemovedApp 主进程中记录运行时移除的App，StartFunc中添加的App记录到removedApps
+func (that *Executor) RecordRemovedApp(name string) {
+	if that.dynamicApps.Remove(name) == nil {
+		that.removedApps.Set(name, struct{}{})
+	}
+	that.forgetJob(name)
+	that.AppsRunning.Remove(name)
+}
+
+// removedAppsEnv 传给子进程的运行时移除的App
+func (that *Executor) removedAppsEnv() string {
+	return strings.Join(gconv.Strings(that.removedApps.Keys()), ",")
+}
+
+// dropRemovedApps 子进程中移除主进程传来的运行时已移除的App
+func (that *Executor) dropRemovedApps(names []string) {
+	for _, name := range names {
+		if _, found := that.AppList.Search(name); !found {
+			continue
+		}
+		if err := that.RemoveApp(name); err != nil {
+			logger.Warning(err)
+		}
+	}
+}
+
+// dynamicAppsEnv 传给子进程的运行时添加的App
+func (that *Executor) dynamicAppsEnv() string {
+	if that.dynamicApps.Size() == 0 {
+		return ""
+	}
+	specs := []*kipc.AppSpec{}
+	for _, v := range that.dynamicApps.Values() {
+		specs = append(specs, v.(*kipc.AppSpec))
+	}
+	data, _ := json.Marshal(specs)
+	return string(data)
+}
@@ -4,19 +4,25 @@ import (
 	"context"
 	"fmt"
 	"os"
-	"reflect"
-	"time"
+	"sync"
 
 	"github.com/gogf/gf/container/garray"
 	"github.com/gogf/gf/container/gmap"
+	"github.com/gogf/gf/errors/gcode"
 	"github.com/gogf/gf/errors/gerror"
 	"github.com/gogf/gf/os/gcfg"
+	"github.com/gogf/gf/os/genv"
 	"github.com/gogf/gf/os/gtime"
+	"github.com/gogf/gf/text/gstr"
 	"github.com/gogf/gf/util/gconv"
 	kapp "github.com/moqsien/gokeeper/kapp"
+	kevent "github.com/moqsien/gokeeper/kevent"
+	kipc "github.com/moqsien/gokeeper/kipc"
+	ktrace "github.com/moqsien/gokeeper/ktrace"
 	ktype "github.com/moqsien/gokeeper/ktype"
 	process "github.com/moqsien/processes"
 	logger "github.com/moqsien/processes/logger"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // Keeper实现本接口的方法在ki_executor.go中
@@ -28,6 +34,11 @@ type IKeeper interface {
 	NewProcess(name string, opts ...process.Option) (*process.ProcessPlus, error)
 	ProcManager() *process.Manager
 	GetExecutorsRunning() *gmap.StrAnyMap
+	GetKeeperName() string
+	SaveState()
+	AuditSupervisor(command string, targets []string, err error)
+	TraceContext() context.Context
+	PublishEvent(e *kevent.Event)
 }
 
 /*
@@ -42,6 +53,21 @@ type Executor struct {
 	Name                 string          // 执行器名称
 	AppList              *gmap.StrAnyMap // 保存的App列表，key: appName, value: appContainer
 	AppsRunning          *gmap.StrAnyMap // 当前正在运行中的App
+	Replicas             *gmap.IntAnyMap // 多进程模式下，主进程中保存的副本子进程，key: 副本序号，value: *Replica
+	ConfigFilePath       string          // 多进程模式下，子进程使用的配置文件路径
+	AppsToStart          []string        // 多进程模式下，子进程需要启动的App列表
+	scaleLock            sync.Mutex      // 调整副本数时加锁
+	scaleCancel          func()          // 停止自动扩缩容
+	superviseCancel      func()          // 停止副本监控
+	superviseTick        int64           // 副本监控最近一次运行的时间，UnixNano
+	exitLock             sync.Mutex      // 读写退出记录时加锁
+	exits                []*ExitRecord   // 多进程模式下，主进程中保存的副本子进程退出记录
+	runners              *gmap.StrAnyMap // 正在运行的定时App的调度器、一次性任务的执行器等，key: appName，value: appRunner
+	completedJobs        *gmap.IntAnyMap // 多进程模式下，主进程中保存的各副本已完成的一次性任务，key: 副本序号，value: *gmap.StrAnyMap(key: appName，value: 完成时间)
+	dynamicApps          *gmap.StrAnyMap // 多进程模式下，主进程中保存的运行时添加到本Executor的App，key: appName，value: *kipc.AppSpec
+	detachedApps         *gmap.StrAnyMap // 从本Executor移除的App，移回时复用，key: appName，value: kapp.IApp
+	removedApps          *gmap.StrAnyMap // 多进程模式下，主进程中保存的运行时从本Executor移除的StartFunc中的App，key: appName
+	probing              *gmap.StrAnyMap // 子进程中正在进行存活检查的App，key: appName
 }
 
 /*
@@ -49,10 +75,17 @@ type Executor struct {
 */
 func NewExecutor(execName string, k IKeeper) *Executor {
 	return &Executor{
-		Keeper:      k,
-		Name:        execName,
-		AppList:     gmap.NewStrAnyMap(true),
-		AppsRunning: gmap.NewStrAnyMap(true),
+		Keeper:        k,
+		Name:          execName,
+		AppList:       gmap.NewStrAnyMap(true),
+		AppsRunning:   gmap.NewStrAnyMap(true),
+		Replicas:      gmap.NewIntAnyMap(true),
+		runners:       gmap.NewStrAnyMap(true),
+		completedJobs: gmap.NewIntAnyMap(true),
+		dynamicApps:   gmap.NewStrAnyMap(true),
+		detachedApps:  gmap.NewStrAnyMap(true),
+		removedApps:   gmap.NewStrAnyMap(true),
+		probing:       gmap.NewStrAnyMap(true),
 	}
 }
 
@@ -85,78 +118,67 @@ StopExecutor 停止执行当前Executor；
 func (that *Executor) StopExecutor() {
 	for _, app := range that.AppList.Map() {
 		a := app.(*kapp.AppContainer)
-		if a.State == process.Running {
+		if a.State == process.Running || a.State == process.Starting {
 			a.State = process.Stopping
-			if e := a.App.Exit(); e != nil {
+			e := that.exitApp(that.Keeper.TraceContext(), a)
+			if e != nil {
 				logger.Errorf("服务 %s .结束出错，error: %v", a.App.AppName(), e)
 			} else {
 				logger.Printf("%s 服务 已结束.", a.App.AppName())
 			}
 			a.State = process.Stopped
 			a.StopTime = gtime.Now()
+			that.publishApp(kevent.AppStopped, a.App.AppName(), e)
 		}
 	}
+	if that.Keeper.Mode() == ktype.SingleProc {
+		that.Keeper.PublishEvent(that.newEvent(kevent.ExecutorStopped))
+	}
 	return
 }
 
-// 通过反射生成私有app对象
+/*
+  MakeApp 为App注入所属的Executor、上下文和配置；
+  App必须匿名嵌入kapp.AppBase(或者非nil的*kapp.AppBase)，未嵌入时返回错误；
+  App从其他Executor移入时，保留原有的上下文。
+*/
 func (that *Executor) MakeApp(a kapp.IApp) (kapp.IApp, error) {
-	var (
-		cType  = reflect.TypeOf(a)
-		cValue = reflect.ValueOf(a)
-	)
-	//判断是否是指针类型
-	if cType.Kind() != reflect.Ptr {
-		return nil, gerror.Newf("生成App: 传入的App对象不是指针类型: %s", cType.String())
+	if a == nil {
+		return nil, gerror.NewCode(gcode.CodeMissingParameter, "生成App: 传入的App为nil")
 	}
-	var cTypeElem = cType.Elem()
-	//判断是否是struct类型
-	if cTypeElem.Kind() != reflect.Struct {
-		return nil, gerror.Newf("生成App: 传入的App对象不是struct类型: %s", cType.String())
+	b, ok := a.(kapp.IAppBase)
+	if !ok {
+		return nil, gerror.NewCodef(gcode.CodeInvalidParameter, "生成App: 传入的App对象未继承 AppBase : %T", a)
 	}
-	//如果结构体没有实现 AppCtx 的方法，或者不是匿名结构体
-	iType, ok := cTypeElem.FieldByName("AppBase")
-	if !ok || !iType.Anonymous {
-		return nil, gerror.Newf("生成App: 传入的App对象未继承 AppBase : %s", cType.String())
+	// 嵌入的*kapp.AppBase为nil时无法注入
+	base := b.Base()
+	if base == nil {
+		return nil, gerror.NewCodef(gcode.CodeInvalidParameter, "生成App: 传入的App对象嵌入的 *AppBase 为nil : %T", a)
 	}
-
-	_, found := cType.MethodByName("Execute")
-	if !found {
-		return nil, gerror.Newf("生成App: 传入的App对象未实现Execute方法")
-	}
-
-	_, found = cType.MethodByName("Exit")
-	if !found {
-		return nil, gerror.Newf("生成App: 传入的App对象未实现Exit方法")
+	base.Executor = that
+	if base.Context == nil {
+		base.Context = context.Background()
 	}
+	base.AppConfig = &kapp.AppConfig{Config: that.Keeper.Config()}
+	return a, nil
+}
 
-	_, found = cType.MethodByName("Name")
+// SearchApp 按名称查找Executor中的App，实现kapp.IExecutor接口
+func (that *Executor) SearchApp(name string) (kapp.IApp, bool) {
+	v, found := that.AppList.Search(name)
 	if !found {
-		return nil, gerror.Newf("生成App: 传入的App对象未实现Name方法")
-	}
-	iValue := cValue.Elem().FieldByName("Executor")
-	if iValue.CanSet() {
-		iValue.Set(reflect.ValueOf(that))
+		return nil, false
 	}
-	iValue = cValue.Elem().FieldByName("Context")
-	if iValue.CanSet() {
-		iValue.Set(reflect.ValueOf(context.Background()))
-	}
-	iValue = cValue.Elem().FieldByName("Config")
-	if iValue.CanSet() {
-		c := &kapp.AppConfig{}
-		c.Config = that.Keeper.Config()
-		iValue.Set(reflect.ValueOf(c))
-	}
-	return a, nil
+	return v.(*kapp.AppContainer).App, true
 }
 
 // AddApp 添加App到Executor
 func (that *Executor) AddApp(a kapp.IApp) error {
-	name := a.AppName()
-	_, found := that.AppList.Search(name)
-	if found {
-		return gerror.Newf("App [%s] 已存在", name)
+	if a == nil || a.AppName() == "" {
+		return gerror.NewCode(gcode.CodeMissingParameter, "App及其名称不能为空")
+	}
+	if _, found := that.AppList.Search(a.AppName()); found {
+		return gerror.NewCodef(gcode.CodeInvalidOperation, "App [%s] 已存在", a.AppName())
 	}
 	app, err := that.MakeApp(a)
 	if err != nil {
@@ -169,37 +191,64 @@ func (that *Executor) AddApp(a kapp.IApp) error {
 	return nil
 }
 
-// RemoveApp 从Executor移除App
-func (that *Executor) RemoveApp(name string) {
-	value := that.AppList.Remove(name)
-	if value == nil {
-		return
+// RemoveApp 从Executor移除App，App正在运行时先停止
+func (that *Executor) RemoveApp(name string) error {
+	_, err := that.DetachApp(name)
+	return err
+}
+
+// DetachApp 从Executor移除App并返回，App正在运行时先停止；移除的App会被保留，可以通过TakeApp取回
+func (that *Executor) DetachApp(name string) (kapp.IApp, error) {
+	v, found := that.AppList.Search(name)
+	if !found {
+		return nil, gerror.NewCodef(gcode.CodeNotFound, "未找到[%s]", name)
 	}
-	app := value.(*kapp.AppContainer)
-	if app.State == process.Running {
-		err := that.StopApp(name)
-		if err != nil {
-			logger.Error(err)
-			return
+	ac := v.(*kapp.AppContainer)
+	if ac.State == process.Running || ac.State == process.Starting {
+		// Exit出错时App也已经停止，仍然移除
+		if err := that.StopApp(name); err != nil {
+			logger.Warning(err)
 		}
-		that.AppsRunning.Remove(name)
 	}
+	that.AppList.Remove(name)
+	that.AppsRunning.Remove(name)
+	that.detachedApps.Set(name, ac.App)
+	return ac.App, nil
+}
+
+// TakeApp 取出App用于移动到其他Executor，可以是Executor中的App，也可以是之前被移除的App
+func (that *Executor) TakeApp(name string) (kapp.IApp, error) {
+	if _, found := that.AppList.Search(name); found {
+		app, err := that.DetachApp(name)
+		that.detachedApps.Remove(name)
+		return app, err
+	}
+	if v := that.detachedApps.Remove(name); v != nil {
+		return v.(kapp.IApp), nil
+	}
+	return nil, gerror.NewCodef(gcode.CodeNotFound, "未找到[%s]", name)
 }
 
 // StopApp 关闭指定的App
 func (that *Executor) StopApp(name string) error {
+	return that.stopApp(that.Keeper.TraceContext(), name)
+}
+
+func (that *Executor) stopApp(ctx context.Context, name string) error {
 	a, found := that.AppList.Search(name)
 	if !found {
 		return fmt.Errorf("未找到[%s]", name)
 	}
 	ac := a.(*kapp.AppContainer)
-	if ac.State == process.Running {
+	// 等待依赖的一次性任务完成的App处于Starting状态
+	if ac.State == process.Running || ac.State == process.Starting {
 		ac.State = process.Stopping
-		err := ac.App.Exit()
+		err := that.exitApp(ctx, ac)
 		ac.State = process.Stopped
 		ac.StopTime = gtime.Now()
 		// 更新AppsRunning列表
 		that.AppsRunning.Remove(ac.App.AppName())
+		that.publishApp(kevent.AppStopped, name, err)
 		return err
 	}
 	return nil
@@ -207,6 +256,12 @@ func (that *Executor) StopApp(name string) error {
 
 // StartApp 启动指定的App
 func (that *Executor) StartApp(name string) error {
+	return that.startApp(that.Keeper.TraceContext(), name)
+}
+
+func (that *Executor) startApp(ctx context.Context, name string) (err error) {
+	ctx, span := ktrace.Start(ctx, "app.start", that.appAttrs(name)...)
+	defer func() { ktrace.End(span, err) }()
 	a, found := that.AppList.Search(name)
 	if !found {
 		return fmt.Errorf("未找到[%s]", name)
@@ -215,21 +270,186 @@ func (that *Executor) StartApp(name string) error {
 	if ac.State == process.Starting || ac.State == process.Running {
 		return fmt.Errorf("App[%s]正在运行中", name)
 	}
+	deps, err := that.appDependencies(ac)
+	if err != nil {
+		return err
+	}
+	if len(deps) > 0 {
+		pending, err := that.pendingDependencies(deps)
+		if err != nil {
+			return err
+		}
+		// 依赖的一次性任务还未完成时，在后台等待完成后再启动
+		if len(pending) > 0 {
+			waiter := &appWaiter{executor: that, ctx: ctx, ac: ac, deps: deps, stop: make(chan struct{})}
+			ac.State = process.Starting
+			that.runners.Set(name, waiter)
+			logger.Printf("App[%s]等待任务%v完成后启动", name, pending)
+			waiter.start()
+			return nil
+		}
+	}
+	return that.launchApp(ctx, ac)
+}
+
+// launchApp 启动App；定时App只创建调度器，一次性任务由执行器负责重试，其他App直接调用Execute
+func (that *Executor) launchApp(ctx context.Context, ac *kapp.AppContainer) (err error) {
+	name := ac.App.AppName()
+	var runner appRunner
+	switch app := ac.App.(type) {
+	case kapp.IScheduledApp:
+		runner, err = newAppScheduler(that, ac, app)
+	case kapp.IJobApp:
+		runner, err = newJobRunner(ctx, that, ac, app)
+	}
+	if err != nil {
+		return err
+	}
+	if ac.StartTime != nil {
+		ac.Restarts++
+	}
 	ac.StartTime = gtime.Now()
 	ac.State = process.Running
+	ac.Completed = false
+	// 更新AppsRunning列表
+	that.AppsRunning.Set(name, struct{}{})
+	that.publishApp(kevent.AppStarted, name, nil)
+	if runner != nil {
+		that.runners.Set(name, runner)
+		runner.start()
+		return nil
+	}
 	go func(a1 *kapp.AppContainer) {
+		// Execute返回nil表示App已在后台运行
 		e := a1.App.Execute()
 		if e != nil && a1.State != process.Stopping {
 			a1.State = process.Stopped
+			that.AppsRunning.Remove(a1.App.AppName())
+			// Execute出错时，单独记录一个span，与app.start属于同一条链路
+			_, s := ktrace.Start(ctx, "app.execute", that.appAttrs(a1.App.AppName())...)
+			ktrace.End(s, e)
 			logger.Warningf("App:[%v] 启动失败: %v", a1.App.AppName(), e)
-			return
+			that.publishApp(kevent.AppFailed, a1.App.AppName(), e)
 		}
-		// 更新AppsRunning列表
-		that.AppsRunning.Set(a1.App.AppName(), struct{}{})
 	}(ac)
 	return nil
 }
 
+// exitApp 调用App的Exit方法，并记录span
+func (that *Executor) exitApp(ctx context.Context, ac *kapp.AppContainer) (err error) {
+	_, span := ktrace.Start(ctx, "app.exit", that.appAttrs(ac.App.AppName())...)
+	defer func() { ktrace.End(span, err) }()
+	// 定时App结束调度器，一次性任务结束执行器，正在执行时由它们调用Exit
+	if v := that.runners.Remove(ac.App.AppName()); v != nil {
+		return v.(appRunner).close()
+	}
+	return ac.App.Exit()
+}
+
+// newEvent 创建当前Executor的事件
+func (that *Executor) newEvent(t kevent.Type) *kevent.Event {
+	return &kevent.Event{Type: t, Executor: that.Name}
+}
+
+// publishApp 发布App相关的事件，err不为空时作为事件描述
+func (that *Executor) publishApp(t kevent.Type, name string, err error) {
+	e := that.newEvent(t)
+	e.App = name
+	if err != nil {
+		e.Message = err.Error()
+	}
+	that.Keeper.PublishEvent(e)
+}
+
+// appAttrs App相关span的属性
+func (that *Executor) appAttrs(name string) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		ktrace.AttrKeeper.String(that.Keeper.GetKeeperName()),
+		ktrace.AttrExecutor.String(that.Name),
+		ktrace.AttrApp.String(name),
+	}
+}
+
+// StartApps 启动多个App，返回启动成功的App列表
+func (that *Executor) StartApps(names ...string) (started []string) {
+	for _, name := range names {
+		if err := that.StartApp(name); err != nil {
+			logger.Warning(err)
+			continue
+		}
+		started = append(started, name)
+	}
+	return
+}
+
+// StopApps 关闭多个App，返回关闭成功的App列表
+func (that *Executor) StopApps(names ...string) (stopped []string) {
+	for _, name := range names {
+		if err := that.StopApp(name); err != nil {
+			logger.Warning(err)
+			continue
+		}
+		stopped = append(stopped, name)
+	}
+	return
+}
+
+// ReloadApps 重启多个App，未传入AppName时重启所有正在运行的App，返回重启成功的App列表
+func (that *Executor) ReloadApps(names ...string) (reloaded []string) {
+	if len(names) == 0 {
+		names = gconv.Strings(that.AppsRunning.Keys())
+	}
+	ctx, span := ktrace.Start(that.Keeper.TraceContext(), "app.reload",
+		ktrace.AttrKeeper.String(that.Keeper.GetKeeperName()),
+		ktrace.AttrExecutor.String(that.Name),
+		ktrace.AttrApp.StringSlice(names),
+	)
+	var lastErr error
+	defer func() { ktrace.End(span, lastErr) }()
+	for _, name := range names {
+		if err := that.stopApp(ctx, name); err != nil {
+			logger.Warning(err)
+			lastErr = err
+			// Exit出错(例如等待连接处理完成超时后强制关闭)时App也已经停止，仍然需要重新启动
+			if _, found := that.AppList.Search(name); !found {
+				continue
+			}
+		}
+		if err := that.startApp(ctx, name); err != nil {
+			logger.Warning(err)
+			lastErr = err
+			continue
+		}
+		reloaded = append(reloaded, name)
+		that.publishApp(kevent.AppReloaded, name, nil)
+	}
+	return
+}
+
+// Status 获取Executor在当前进程中的运行状态
+func (that *Executor) Status() *kipc.Status {
+	status := &kipc.Status{
+		Pid:         os.Getpid(),
+		Executor:    that.Name,
+		AppsRunning: gconv.Strings(that.AppsRunning.Keys()),
+	}
+	for _, v := range that.AppList.Values() {
+		ac := v.(*kapp.AppContainer)
+		state := &kipc.AppState{Name: ac.App.AppName(), State: ac.State.ToString(), Restarts: ac.Restarts}
+		if ac.StartTime != nil {
+			state.StartTime = ac.StartTime.String()
+		}
+		if ac.StopTime != nil {
+			state.StopTime = ac.StopTime.String()
+		}
+		that.scheduleState(ac, state)
+		that.jobState(ac, state)
+		that.workerState(ac, state)
+		status.Apps = append(status.Apps, state)
+	}
+	return status
+}
+
 /*
   StartApps
   启动Executor中需要启动的App；
@@ -237,6 +457,10 @@ func (that *Executor) StartApp(name string) error {
   单进程模式下，本方法在主进程中执行(因为只有一个进程)；
 */
 func (that *Executor) StartAllApps() {
+	// 多进程模式下，副本子进程被重启时，主进程传来已完成的一次性任务
+	that.markJobsCompleted(gstr.SplitAndTrim(genv.Get(ktype.EnvCompletedJobs), ","))
+	// 多进程模式下，StartFunc中添加的App如果已在运行时被移除，不再启动
+	that.dropRemovedApps(gstr.SplitAndTrim(genv.Get(ktype.EnvRemovedApps), ","))
 	for name, app := range that.AppList.Map() {
 		a := app.(*kapp.AppContainer)
 		/*
@@ -245,25 +469,18 @@ func (that *Executor) StartAllApps() {
 		  因此，可以支持启动当前Executor中的一部分App。
 		*/
 		if that.Keeper.ListOfAppsToStart().Len() > 0 && !that.Keeper.ListOfAppsToStart().ContainsI(a.App.AppName()) {
-			that.RemoveApp(gconv.String(name))
+			if err := that.RemoveApp(gconv.String(name)); err != nil {
+				logger.Warning(err)
+			}
 			continue
 		}
 
-		// 判断是否app已经在运行
-		if a.State != process.Running {
-			a.StartTime = gtime.Now()
-			a.State = process.Running
+		// 判断是否app已经在运行，已完成的一次性任务不再执行
+		if a.State != process.Running && !a.Completed {
 			// 尝试启动App
-			go func(a1 *kapp.AppContainer) {
-				err := a1.App.Execute()
-				if err != nil && a1.State != process.Stopping {
-					a1.State = process.Stopped
-					logger.Warningf("App:[%v] Start Fails: %v", a1.App.AppName(), err)
-					return
-				}
-				// 在当前子进程的运行Executor中记录已运行的app
-				that.AppsRunning.Set(a1.App.AppName(), struct{}{})
-			}(a)
+			if err := that.StartApp(a.App.AppName()); err != nil {
+				logger.Warning(err)
+			}
 		}
 	}
 }
@@ -305,66 +522,63 @@ func (that *Executor) NewChildProcForStart(configFilePath string) {
 	  如果没有App需要启动，则不会创建新的子进程
 	*/
 	if appNameList := that.GetAppNeedToStart(); len(appNameList) != 0 {
-		// 子进程参数——子进程需要执行的命令：start
-		var args = []string{"start"}
-
-		// 子进程参数——运行环境：product, test, dev；默认product
-		if len(that.Keeper.Config().GetString("ENV_NAME")) > 0 {
-			args = append(args, fmt.Sprintf("--env=%s", that.Keeper.Config().GetString("ENV_NAME")))
-		}
-
-		// 子进程参数——配置文件路径
-		if len(configFilePath) > 0 {
-			args = append(args, fmt.Sprintf("--config=%s", configFilePath))
-		}
-
-		// 子进程参数——是否开启调试
-		if that.Keeper.Config().GetBool("Debug") {
-			args = append(args, "--debug")
-		}
+		that.ConfigFilePath = configFilePath
+		that.AppsToStart = appNameList
 
 		/*
-		  子进程参数——子进程中需要启动的Executor；
-		  传递本参数之后，明确告诉子进程需要执行哪个Executor，无需遍历所有Executor，提高效率。
+		  按照配置的副本数开启子进程，每个副本对应一个子进程；
+		  异步开启新的子进程；一个goroutine(在StartProc中实现)对应一个子进程，
 		*/
-		args = append(args, fmt.Sprintf("--executor=%s", that.Name))
-
-		// 子进程参数——要启动的AppNames
-		args = append(args, appNameList...)
-
-		// 	process.ProcExtraFiles(that.server.graceful.getExtraFiles()), // 与获取inheritedEnv的顺序不能错乱
-		// 	process.ProcEnvironment(that.server.graceful.inheritedEnv.Map()),
-
-		// 创建新的子进程
-		p, e := that.Keeper.NewProcess(that.Name, // 进程名==Executor名称
-			process.ProcPath(os.Args[0]),
-			process.ProcArgs(args),
-			process.ProcEnvVar(ktype.EnvIsChild, "true"),
-			process.ProcEnvVar(ktype.EnvIsMaster, "false"), // 子进程的"主进程标记"设置为false，用于区分子进程和主进程
-			process.ProcStdoutLog("/dev/stdout", ""),
-			process.ProcRedirectStderr(true),
-			process.ProcAutoReStart(process.AutoReStartTrue),
-			process.ProcStopSignal("SIGQUIT", "SIGTERM"),
-			process.ProcStopWaitSecs(int(ktype.MinShutdownTimeout/time.Second)))
-		if e != nil {
-			// 创建子进程失败
-			logger.Warning(e)
+		for i := 0; i < that.InitReplicas(); i++ {
+			if e := that.startReplica(i); e != nil {
+				// 创建子进程失败
+				logger.Warning(e)
+				break
+			}
+		}
+		if that.Replicas.Size() == 0 {
 			return
 		}
-
-		/*
-		  异步开启新的子进程；一个goroutine(在StartProc中实现)对应一个子进程，
-		*/
-		p.StartProc(true)
 		// 主进程中，保存已启动的App列表
 		for _, appName := range appNameList {
 			that.AppsRunning.Set(appName, struct{}{})
 		}
-		// 主进程中，保存对应的子进程的Pid
-		that.Pid = p.Process.Pid
-		// 主进程中，Executor设置其对应的进程
-		that.ProcessPlus = p
 		// 主进程中，加入正在运行的Executor列表
 		that.Keeper.GetExecutorsRunning().Set(that.Name, that)
+		// 主进程中，监控副本子进程的心跳和退出，并按照重启策略重启
+		that.RunSupervisor()
+		// 主进程中，如果配置了扩缩容策略，则根据子进程的CPU使用率自动调整副本数
+		that.RunScalePolicy()
+		that.Keeper.PublishEvent(that.newEvent(kevent.ExecutorStarted).Set("replicas", that.Replicas.Size()))
+	}
+}
+
+// childProcArgs 生成子进程的命令行参数
+func (that *Executor) childProcArgs() []string {
+	// 子进程参数——子进程需要执行的命令：start
+	var args = []string{"start"}
+
+	// 子进程参数——运行环境：product, test, dev；默认product
+	if len(that.Keeper.Config().GetString("ENV_NAME")) > 0 {
+		args = append(args, fmt.Sprintf("--env=%s", that.Keeper.Config().GetString("ENV_NAME")))
+	}
+
+	// 子进程参数——配置文件路径
+	if len(that.ConfigFilePath) > 0 {
+		args = append(args, fmt.Sprintf("--config=%s", that.ConfigFilePath))
+	}
+
+	// 子进程参数——是否开启调试
+	if that.Keeper.Config().GetBool("Debug") {
+		args = append(args, "--debug")
 	}
+
+	/*
+	  子进程参数——子进程中需要启动的Executor；
+	  传递本参数之后，明确告诉子进程需要执行哪个Executor，无需遍历所有Executor，提高效率。
+	*/
+	args = append(args, fmt.Sprintf("--executor=%s", that.Name))
+
+	// 子进程参数——要启动的AppNames
+	return append(args, that.AppsToStart...)
 }
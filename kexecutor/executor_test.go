@@ -0,0 +1,56 @@
+package kexecutor
+
+import (
+	"testing"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	kapp "github.com/moqsien/gokeeper/kapp"
+	ktype "github.com/moqsien/gokeeper/ktype"
+)
+
+type plainApp struct{ name string }
+
+func (that *plainApp) AppName() string { return that.name }
+func (that *plainApp) Execute() error  { return nil }
+func (that *plainApp) Exit() error     { return nil }
+
+type baseApp struct {
+	kapp.AppBase
+	plainApp
+}
+
+type basePtrApp struct {
+	*kapp.AppBase
+	plainApp
+}
+
+func TestMakeApp(t *testing.T) {
+	e := NewExecutor("web", newFakeKeeper(ktype.MultiProcs, true))
+	cases := []struct {
+		name     string
+		app      kapp.IApp
+		wantCode gcode.Code
+	}{
+		{"nil app", nil, gcode.CodeMissingParameter},
+		{"without AppBase", &plainApp{name: "plain"}, gcode.CodeInvalidParameter},
+		{"nil *AppBase", &basePtrApp{plainApp: plainApp{name: "ptr"}}, gcode.CodeInvalidParameter},
+		{"AppBase", &baseApp{plainApp: plainApp{name: "base"}}, gcode.CodeNil},
+		{"*AppBase", &basePtrApp{AppBase: &kapp.AppBase{}, plainApp: plainApp{name: "ptr"}}, gcode.CodeNil},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			app, err := e.MakeApp(c.app)
+			if code := gerror.Code(err); code != c.wantCode {
+				t.Fatalf("code = %v, want %v, err: %v", code, c.wantCode, err)
+			}
+			if err != nil {
+				return
+			}
+			base := app.(kapp.IAppBase).Base()
+			if base.Executor != e || base.Context == nil || base.AppConfig == nil {
+				t.Fatalf("AppBase is not injected: %+v", base)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package kexecutor
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/gogf/gf/os/gtime"
+)
+
+/*
+  子进程的退出记录：主进程记录副本子进程非主动退出时的退出码或终止信号、运行时长以及标准错误的最后几行，
+  每个Executor保存最近的若干条，配置示例：
+    executors:
+      myExecutor:
+        exitHistory: 20    # 保存的退出记录数，默认20
+        exitLogLines: 20   # 每条记录保存的标准错误行数，默认20
+  子进程启动时将标准错误重定向到与主进程之间的管道(见ktype.EnvStderrFd)，主进程读取后写入自己的标准输出，同时保留最后几行。
+*/
+
+// stderrLineMax 保存的每行标准错误的最大长度
+const stderrLineMax = 1024
+
+// ExitRecord 副本子进程的退出记录
+type ExitRecord struct {
+	Replica    string   `json:"replica"`              // 副本名称
+	Index      int      `json:"index"`                // 副本序号
+	Pid        int      `json:"pid"`                  // 子进程pid
+	StartTime  string   `json:"startTime"`            // 子进程启动时间
+	ExitTime   string   `json:"exitTime"`             // 子进程退出时间
+	Runtime    string   `json:"runtime"`              // 子进程运行时长
+	ExitCode   int      `json:"exitCode"`             // 退出码，被信号终止时为-1
+	Signal     string   `json:"signal,omitempty"`     // 终止子进程的信号
+	CoreDumped bool     `json:"coreDumped,omitempty"` // 是否产生了core dump
+	LastLines  []string `json:"lastLines,omitempty"`  // 标准错误的最后几行
+}
+
+// Summary 退出原因的简要描述
+func (that *ExitRecord) Summary() string {
+	var s string
+	if that.Signal != "" {
+		s = fmt.Sprintf("killed by signal %s", that.Signal)
+	} else {
+		s = fmt.Sprintf("exited with code %d", that.ExitCode)
+	}
+	if that.CoreDumped {
+		s += " (core dumped)"
+	}
+	return fmt.Sprintf("%s after %s", s, that.Runtime)
+}
+
+// exitTime 副本子进程的退出时间，未记录时使用当前时间
+func (that *Replica) exitTime() time.Time {
+	if that.StopTime.Before(that.StartTime) {
+		return time.Now()
+	}
+	return that.StopTime
+}
+
+// newExitRecord 根据副本子进程的退出状态生成退出记录
+func (that *Replica) newExitRecord(lines int) *ExitRecord {
+	code, _ := that.GetExitCode()
+	record := &ExitRecord{
+		Replica:   that.Name,
+		Index:     that.Index,
+		StartTime: gtime.New(that.StartTime).String(),
+		ExitCode:  code,
+	}
+	if that.Process != nil {
+		record.Pid = that.Process.Pid
+	}
+	exitTime := that.exitTime()
+	record.ExitTime = gtime.New(exitTime).String()
+	record.Runtime = exitTime.Sub(that.StartTime).Truncate(time.Millisecond).String()
+	if that.ProcessState != nil {
+		if ws, ok := that.ProcessState.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+			record.Signal = ws.Signal().String()
+			record.CoreDumped = ws.CoreDump()
+		}
+	}
+	if that.stderr != nil {
+		record.LastLines = that.stderr.tail.Last(lines)
+	}
+	return record
+}
+
+// recordExit 保存退出记录，超过上限时丢弃最早的记录
+func (that *Executor) recordExit(record *ExitRecord, max int) {
+	that.exitLock.Lock()
+	defer that.exitLock.Unlock()
+	that.exits = append(that.exits, record)
+	if len(that.exits) > max {
+		that.exits = that.exits[len(that.exits)-max:]
+	}
+}
+
+// ExitHistory 副本子进程的退出记录，按时间先后排列
+func (that *Executor) ExitHistory() []*ExitRecord {
+	that.exitLock.Lock()
+	defer that.exitLock.Unlock()
+	return append([]*ExitRecord{}, that.exits...)
+}
+
+// lineTail 保存最后若干行文本
+type lineTail struct {
+	lock  sync.Mutex
+	lines []string
+	max   int
+}
+
+func (that *lineTail) Add(line string) {
+	if len(line) > stderrLineMax {
+		line = line[:stderrLineMax] + "..."
+	}
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	that.lines = append(that.lines, line)
+	if len(that.lines) > that.max {
+		that.lines = that.lines[len(that.lines)-that.max:]
+	}
+}
+
+// Last 最后n行
+func (that *lineTail) Last(n int) []string {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	if n > len(that.lines) {
+		n = len(that.lines)
+	}
+	return append([]string{}, that.lines[len(that.lines)-n:]...)
+}
+
+// Reset 清空，子进程重启时执行
+func (that *lineTail) Reset() {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	that.lines = nil
+}
+
+// stderrPipe 副本子进程的标准错误管道，子进程重启后继续使用，副本关闭时才关闭
+type stderrPipe struct {
+	w    *os.File  // 传给子进程的写入端
+	tail *lineTail // 标准错误的最后几行
+}
+
+// newStderrPipe 创建管道，并将子进程写入的内容转发到主进程的标准输出
+func newStderrPipe(lines int) (*stderrPipe, error) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	p := &stderrPipe{w: w, tail: &lineTail{max: lines}}
+	go p.copy(r)
+	return p, nil
+}
+
+// copy 读取子进程的标准错误，直到所有写入端都被关闭
+func (that *stderrPipe) copy(r *os.File) {
+	defer r.Close()
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadString('\n')
+		if len(line) > 0 {
+			_, _ = io.WriteString(os.Stdout, line)
+			that.tail.Add(strings.TrimRight(line, "\r\n"))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func (that *stderrPipe) close() {
+	_ = that.w.Close()
+}
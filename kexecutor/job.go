@@ -0,0 +1,350 @@
+package kexecutor
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/container/garray"
+	"github.com/gogf/gf/container/gmap"
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	"github.com/gogf/gf/os/gtime"
+	"github.com/gogf/gf/util/gconv"
+	kapp "github.com/moqsien/gokeeper/kapp"
+	kevent "github.com/moqsien/gokeeper/kevent"
+	kipc "github.com/moqsien/gokeeper/kipc"
+	ktrace "github.com/moqsien/gokeeper/ktrace"
+	process "github.com/moqsien/processes"
+	logger "github.com/moqsien/processes/logger"
+)
+
+// dependencyCheckInterval 检查依赖的一次性任务是否完成的间隔
+const dependencyCheckInterval = 200 * time.Millisecond
+
+// appRunner 定时App的调度器、一次性任务的执行器等，App启动时调用start，停止时调用close
+type appRunner interface {
+	start()
+	close() error
+}
+
+/*
+jobRunner 一次性任务的执行器，App启动时创建；
+调用Execute直到执行成功、重试次数用完或者App被停止，并在AppContainer中记录执行情况。
+*/
+type jobRunner struct {
+	executor  *Executor
+	ctx       context.Context
+	ac        *kapp.AppContainer
+	app       kapp.IJobApp
+	conf      *kapp.JobConfig
+	lock      sync.Mutex
+	executing bool // 正在调用Execute
+	stopped   bool // App已被停止
+	stop      chan struct{}
+	done      chan struct{}
+}
+
+// newJobRunner 读取并检查一次性任务的重试策略
+func newJobRunner(ctx context.Context, e *Executor, ac *kapp.AppContainer, app kapp.IJobApp) (*jobRunner, error) {
+	conf := app.Job()
+	if conf == nil {
+		return nil, gerror.NewCodef(gcode.CodeMissingParameter, "App[%s]未设置重试策略", app.AppName())
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, gerror.WrapCodef(gcode.CodeInvalidParameter, err, "App[%s]的重试策略错误", app.AppName())
+	}
+	return &jobRunner{
+		executor: e,
+		ctx:      ctx,
+		ac:       ac,
+		app:      app,
+		conf:     conf,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}, nil
+}
+
+func (that *jobRunner) start() {
+	go that.run()
+}
+
+// run 执行任务，失败后按照重试策略等待并重试
+func (that *jobRunner) run() {
+	defer close(that.done)
+	name := that.app.AppName()
+	backoff := that.conf.Backoff
+	for attempt := 1; ; attempt++ {
+		ok, err := that.runOnce()
+		if !ok {
+			// 停止App时被Exit结束的执行不作为失败
+			if err != nil {
+				logger.Printf("App[%s]已停止，本次执行被结束: %v", name, err)
+			}
+			return
+		}
+		if err == nil {
+			that.executor.completeJob(that)
+			return
+		}
+		if attempt > that.conf.Retries {
+			that.executor.failJob(that, gerror.WrapCodef(gcode.CodeOperationFailed, err, "App[%s]执行%d次均失败", name, attempt))
+			return
+		}
+		logger.Warningf("App[%s]第%d次执行失败，%v后重试: %v", name, attempt, backoff, err)
+		that.executor.publishApp(kevent.AppRunFailed, name, err)
+		timer := time.NewTimer(backoff)
+		select {
+		case <-that.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if backoff *= 2; backoff > that.conf.MaxBackoff && that.conf.MaxBackoff > 0 {
+			backoff = that.conf.MaxBackoff
+		}
+	}
+}
+
+// runOnce 调用一次Execute，App已被停止时ok为false
+func (that *jobRunner) runOnce() (ok bool, err error) {
+	that.lock.Lock()
+	if that.stopped {
+		that.lock.Unlock()
+		return false, nil
+	}
+	that.executing = true
+	that.ac.LastRun = gtime.Now()
+	that.ac.Runs++
+	that.lock.Unlock()
+
+	_, span := ktrace.Start(that.ctx, "app.run", that.executor.appAttrs(that.app.AppName())...)
+	err = that.app.Execute()
+	ktrace.End(span, err)
+
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	that.executing = false
+	that.ac.LastError = ""
+	if err != nil {
+		that.ac.LastError = err.Error()
+	}
+	return !that.stopped, err
+}
+
+// close 结束执行器，正在执行时调用Exit，并等待执行结束
+func (that *jobRunner) close() error {
+	that.lock.Lock()
+	if that.stopped {
+		that.lock.Unlock()
+		return nil
+	}
+	that.stopped = true
+	executing := that.executing
+	that.lock.Unlock()
+
+	close(that.stop)
+	var err error
+	if executing {
+		err = that.app.Exit()
+	}
+	<-that.done
+	return err
+}
+
+// completeJob 一次性任务执行成功，App的状态变为Completed
+func (that *Executor) completeJob(r *jobRunner) {
+	name := r.app.AppName()
+	// App正在被停止时，stopApp已经从runners中移除了执行器
+	if that.runners.Remove(name) == nil {
+		return
+	}
+	r.ac.Completed = true
+	r.ac.State = process.Exited
+	r.ac.StopTime = gtime.Now()
+	that.AppsRunning.Remove(name)
+	logger.Printf("App[%s]执行完成，共执行%d次", name, r.ac.Runs)
+	that.publishApp(kevent.AppCompleted, name, nil)
+}
+
+// failJob 一次性任务的重试次数用完，App的状态变为Fatal
+func (that *Executor) failJob(r *jobRunner, err error) {
+	name := r.app.AppName()
+	if that.runners.Remove(name) == nil {
+		return
+	}
+	r.ac.State = process.Fatal
+	r.ac.StopTime = gtime.Now()
+	that.AppsRunning.Remove(name)
+	logger.Warning(err)
+	that.publishApp(kevent.AppFailed, name, err)
+}
+
+/*
+appWaiter 依赖的一次性任务还未完成时，在后台等待完成后再启动App；
+等待期间App处于Starting状态，停止App时结束等待。
+*/
+type appWaiter struct {
+	executor *Executor
+	ctx      context.Context
+	ac       *kapp.AppContainer
+	deps     []string
+	once     sync.Once
+	stop     chan struct{}
+}
+
+func (that *appWaiter) start() {
+	go that.executor.waitAndLaunch(that)
+}
+
+func (that *appWaiter) close() error {
+	that.once.Do(func() { close(that.stop) })
+	return nil
+}
+
+// waitAndLaunch 等待依赖的一次性任务完成后启动App，依赖的任务失败时App启动失败
+func (that *Executor) waitAndLaunch(w *appWaiter) {
+	name := w.ac.App.AppName()
+	err := that.waitDependencies(w.deps, w.stop)
+	// 等待期间App被停止时，stopApp已经从runners中移除了waiter
+	if that.runners.Remove(name) == nil {
+		return
+	}
+	if err == nil {
+		err = that.launchApp(w.ctx, w.ac)
+	}
+	if err != nil {
+		w.ac.State = process.Stopped
+		w.ac.StopTime = gtime.Now()
+		logger.Warningf("App:[%v] 启动失败: %v", name, err)
+		that.publishApp(kevent.AppFailed, name, err)
+	}
+}
+
+// waitDependencies 等待deps全部完成，stop关闭时返回错误
+func (that *Executor) waitDependencies(deps []string, stop chan struct{}) error {
+	ticker := time.NewTicker(dependencyCheckInterval)
+	defer ticker.Stop()
+	for {
+		pending, err := that.pendingDependencies(deps)
+		if err != nil || len(pending) == 0 {
+			return err
+		}
+		select {
+		case <-stop:
+			return gerror.NewCode(gcode.CodeInvalidOperation, "App已停止")
+		case <-ticker.C:
+		}
+	}
+}
+
+// pendingDependencies 返回deps中还未完成的一次性任务，任务失败时返回错误
+func (that *Executor) pendingDependencies(deps []string) (pending []string, err error) {
+	for _, dep := range deps {
+		v, found := that.AppList.Search(dep)
+		if !found {
+			return nil, gerror.NewCodef(gcode.CodeNotFound, "依赖的任务[%s]不存在", dep)
+		}
+		ac := v.(*kapp.AppContainer)
+		switch {
+		case ac.Completed:
+		case ac.State == process.Fatal:
+			return nil, gerror.NewCodef(gcode.CodeOperationFailed, "依赖的任务[%s]执行失败: %s", dep, ac.LastError)
+		default:
+			pending = append(pending, dep)
+		}
+	}
+	return
+}
+
+// appDependencies App启动前需要等待完成的一次性任务，来自IDependentApp和配置中的dependsOn
+func (that *Executor) appDependencies(ac *kapp.AppContainer) ([]string, error) {
+	name := ac.App.AppName()
+	deps := garray.NewStrArray()
+	if da, ok := ac.App.(kapp.IDependentApp); ok {
+		deps.Append(da.DependsOn()...)
+	}
+	deps.Append(that.Keeper.Config().GetStrings(kapp.ConfigNode(name) + ".dependsOn")...)
+	deps.Unique()
+	for _, dep := range deps.Slice() {
+		if dep == name {
+			return nil, gerror.NewCodef(gcode.CodeInvalidParameter, "App[%s]不能依赖自身", name)
+		}
+		v, found := that.AppList.Search(dep)
+		if !found {
+			return nil, gerror.NewCodef(gcode.CodeNotFound, "App[%s]依赖的任务[%s]不存在", name, dep)
+		}
+		if _, ok := v.(*kapp.AppContainer).App.(kapp.IJobApp); !ok {
+			return nil, gerror.NewCodef(gcode.CodeInvalidParameter, "App[%s]依赖的[%s]不是一次性任务", name, dep)
+		}
+	}
+	return deps.Slice(), nil
+}
+
+// markJobsCompleted 将主进程传来的已完成的一次性任务标记为Completed，不再重复执行
+func (that *Executor) markJobsCompleted(names []string) {
+	for _, name := range names {
+		v, found := that.AppList.Search(name)
+		if !found {
+			continue
+		}
+		ac := v.(*kapp.AppContainer)
+		if _, ok := ac.App.(kapp.IJobApp); ok {
+			ac.Completed = true
+			ac.State = process.Exited
+		}
+	}
+}
+
+/*
+recordJob 主进程中根据序号为index的副本子进程发来的事件记录该副本已完成的一次性任务，
+重启该副本子进程时通过环境变量传给子进程；任务被再次启动时移除记录。
+记录按副本区分，扩容新增的副本子进程仍会执行一次任务。
+*/
+func (that *Executor) recordJob(index int, e *kevent.Event) {
+	switch e.Type {
+	case kevent.AppCompleted:
+		jobs := that.completedJobs.GetOrSetFunc(index, func() interface{} {
+			return gmap.NewStrAnyMap(true)
+		}).(*gmap.StrAnyMap)
+		jobs.Set(e.App, e.Time)
+	case kevent.AppStarted:
+		if jobs, ok := that.completedJobs.Get(index).(*gmap.StrAnyMap); ok {
+			jobs.Remove(e.App)
+		}
+	}
+}
+
+// forgetJob 移除所有副本中App的完成记录
+func (that *Executor) forgetJob(name string) {
+	that.completedJobs.Iterator(func(_ int, v interface{}) bool {
+		v.(*gmap.StrAnyMap).Remove(name)
+		return true
+	})
+}
+
+// completedJobsEnv 传给序号为index的副本子进程的已完成的一次性任务
+func (that *Executor) completedJobsEnv(index int) string {
+	jobs, ok := that.completedJobs.Get(index).(*gmap.StrAnyMap)
+	if !ok {
+		return ""
+	}
+	return strings.Join(gconv.Strings(jobs.Keys()), ",")
+}
+
+// jobState 在App的运行状态中填充一次性任务的执行情况
+func (that *Executor) jobState(ac *kapp.AppContainer, state *kipc.AppState) {
+	if _, ok := ac.App.(kapp.IJobApp); !ok {
+		return
+	}
+	state.Job = true
+	if ac.Completed {
+		state.State = kapp.StateCompleted
+	}
+	if ac.LastRun != nil {
+		state.LastRun = ac.LastRun.String()
+	}
+	state.LastError = ac.LastError
+	state.Runs = ac.Runs
+}
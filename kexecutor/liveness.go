@@ -0,0 +1,55 @@
+package kexecutor
+
+import (
+	"context"
+	"time"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	kapp "github.com/moqsien/gokeeper/kapp"
+	process "github.com/moqsien/processes"
+)
+
+/*
+CheckLiveness 子进程发送心跳前检查正在运行的App是否仍在正常工作；
+实现了kapp.ILivenessApp的App并发执行检查，任一App检查失败或者超过timeout未返回时返回错误；
+上一次的检查仍未返回的App直接认为检查失败，不再重复检查，避免卡住的检查不断堆积。
+*/
+func (that *Executor) CheckLiveness(timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	type result struct {
+		name string
+		err  error
+	}
+	results := make(chan result, that.AppList.Size())
+	probes := 0
+	for _, v := range that.AppList.Values() {
+		ac := v.(*kapp.AppContainer)
+		la, ok := ac.App.(kapp.ILivenessApp)
+		if !ok || ac.State != process.Running {
+			continue
+		}
+		name := ac.App.AppName()
+		if !that.probing.SetIfNotExist(name, true) {
+			return gerror.NewCodef(gcode.CodeOperationFailed, "App[%s]的上一次存活检查仍未返回", name)
+		}
+		probes++
+		go func() {
+			err := la.Alive(ctx)
+			that.probing.Remove(name)
+			results <- result{name, err}
+		}()
+	}
+	for ; probes > 0; probes-- {
+		select {
+		case r := <-results:
+			if r.err != nil {
+				return gerror.WrapCodef(gcode.CodeOperationFailed, r.err, "App[%s]未通过存活检查", r.name)
+			}
+		case <-ctx.Done():
+			return gerror.NewCodef(gcode.CodeOperationFailed, "App的存活检查超过%v未返回", timeout)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,366 @@
+package kexecutor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gogf/gf/errors/gerror"
+	kevent "github.com/moqsien/gokeeper/kevent"
+	kgrace "github.com/moqsien/gokeeper/kgrace"
+	kipc "github.com/moqsien/gokeeper/kipc"
+	ktrace "github.com/moqsien/gokeeper/ktrace"
+	ktype "github.com/moqsien/gokeeper/ktype"
+	process "github.com/moqsien/processes"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+Replica 多进程模式下，Executor的一个副本子进程；
+一个Executor可以同时运行多个副本，每个副本都会运行该Executor中需要启动的所有App。
+副本序号从0开始编号，序号为0的副本同时也是Executor内嵌的ProcessPlus。
+*/
+type Replica struct {
+	*process.ProcessPlus
+	Index         int           // 副本序号
+	Channel       *kipc.Channel // 主进程与该副本子进程的通信通道
+	Restarts      int           // 副本子进程被重启的次数
+	childFile     *os.File      // 传给子进程的套接字，子进程重启后继续使用，副本关闭时才关闭
+	stderr        *stderrPipe   // 子进程的标准错误管道，为空时子进程的标准错误直接写入主进程的标准输出
+	readyPid      *int64        // 最近一次报告App已全部启动的子进程pid，子进程重启后继续使用
+	lastHeartbeat int64         // 最近一次收到心跳的时间，UnixNano
+	status        atomic.Value  // 副本子进程最近一次心跳或者启动完成时报告的运行状态，*kipc.Status
+	unresponsive  int32         // 副本子进程是否已失去响应，心跳处理和监控中并发读写，通过atomic访问
+	exitReported  bool          // 是否已经发布过子进程退出的事件
+	exitTimes     []time.Time   // 最近一段时间内子进程的退出时间，用于判断是否反复退出
+	restartAt     time.Time     // 子进程启动后很快退出时，推迟到该时间再重启，只在scaleLock中访问
+}
+
+// InitReplicas 获取Executor启动时的副本数，默认为1
+func (that *Executor) InitReplicas() int {
+	n := that.Keeper.Config().GetInt(fmt.Sprintf("%s.%s.replicas", ktype.ConfigNodeNameExecutors, that.Name), 1)
+	if n < 1 {
+		n = 1
+	}
+	return n
+}
+
+// newReplica 创建副本；副本子进程不放入Manager中，Manager中只保存Executor
+func (that *Executor) newReplica(ctx context.Context, index int) (*Replica, error) {
+	channel, childFile, err := kipc.NewPair()
+	if err != nil {
+		return nil, err
+	}
+	// 心跳消息交给当前序号对应的副本处理，副本子进程重启后Replica对象会被替换
+	channel.Handle(kipc.MsgHeartbeat, func(msg *kipc.Message) *kipc.Message {
+		if v, ok := that.Replicas.Search(index); ok {
+			status := &kipc.Status{}
+			if err := msg.GetData(status); err == nil {
+				v.(*Replica).status.Store(status)
+			}
+			v.(*Replica).touch()
+		}
+		return nil
+	})
+	/*
+	  子进程启动App后报告启动完成，此时副本可能还未保存到Replicas中，因此记录报告者的pid，
+	  由Replica.Ready与当前子进程的pid比较
+	*/
+	readyPid := new(int64)
+	channel.Handle(kipc.MsgReady, func(msg *kipc.Message) *kipc.Message {
+		status := &kipc.Status{}
+		if err := msg.GetData(status); err != nil {
+			return nil
+		}
+		atomic.StoreInt64(readyPid, int64(status.Pid))
+		if v, ok := that.Replicas.Search(index); ok {
+			v.(*Replica).status.Store(status)
+		}
+		return nil
+	})
+	// 子进程中产生的事件，由主进程统一发布
+	channel.Handle(kipc.MsgEvent, func(msg *kipc.Message) *kipc.Message {
+		e := &kevent.Event{}
+		if err := msg.GetData(e); err != nil {
+			logger.Warningf("Executor[%s]的副本[%d]发来的事件解析失败: %v", that.Name, index, err)
+			return nil
+		}
+		// 由主进程重新编号
+		e.Id = 0
+		if e.Replica == "" {
+			e.Replica = fmt.Sprintf("%s#%d", that.Name, index)
+		}
+		that.recordJob(index, e)
+		that.Keeper.PublishEvent(e)
+		return nil
+	})
+	go channel.Serve()
+	stderr, err := newStderrPipe(that.GetSupervisePolicy().ExitLogLines)
+	if err != nil {
+		// 无法创建管道时，退出记录中不包含标准错误
+		logger.Warningf("Executor[%s]的副本[%d]创建标准错误管道失败: %v", that.Name, index, err)
+	}
+	r := &Replica{
+		Index:     index,
+		Channel:   channel,
+		childFile: childFile,
+		stderr:    stderr,
+		readyPid:  readyPid,
+	}
+	r.ProcessPlus = that.newReplicaProc(ctx, r)
+	return r, nil
+}
+
+/*
+newReplicaProc 创建副本子进程，子进程退出后是否重启由superviseReplicas决定；
+ctx中的trace context通过环境变量传给子进程。
+*/
+func (that *Executor) newReplicaProc(ctx context.Context, r *Replica) *process.ProcessPlus {
+	p := process.NewProcess(os.Args[0], fmt.Sprintf("%s#%d", that.Name, r.Index))
+	p.ProcManager = that.Keeper.ProcManager()
+	p.ProcSettings = process.GetDefaultProcSettings()
+	options := []process.Option{
+		process.ProcArgs(that.childProcArgs()),
+		process.ProcEnvVar(ktype.EnvIsChild, "true"),
+		process.ProcEnvVar(ktype.EnvIsMaster, "false"), // 子进程的"主进程标记"设置为false，用于区分子进程和主进程
+		process.ProcEnvVar(ktype.EnvKeeperName, that.Keeper.GetKeeperName()),
+		process.ProcEnvVar(ktype.EnvMasterPid, strconv.Itoa(os.Getpid())),
+		procParentDeathSignal(syscall.SIGTERM),
+		process.ProcEnvVar(ktype.EnvIPCFd, "3"),               // ExtraFiles中的第一个文件在子进程中的描述符为3
+		process.ProcEnvVar(ktype.AdminActionReloadEnvKey, ""), // 监听套接字通过ENV_LISTEN_FDS传递，gf不能按照继承来的值使用文件描述符
+		process.ProcStdoutLog("/dev/stdout", ""),
+		process.ProcRedirectStderr(true),
+		process.ProcAutoReStart(process.AutoReStartFalse),
+		process.ProcStopSignal("SIGQUIT", "SIGTERM"),
+		process.ProcStopWaitSecs(int(ktype.MinShutdownTimeout / time.Second)),
+	}
+	extraFiles := []*os.File{r.childFile}
+	if r.stderr != nil {
+		extraFiles = append(extraFiles, r.stderr.w)
+		options = append(options, process.ProcEnvVar(ktype.EnvStderrFd, "4"))
+	}
+	// 主进程继承的监听套接字依次放在其后，子进程按名称获取
+	if files, env := kgrace.ChildFiles(len(extraFiles) + 3); len(files) > 0 {
+		extraFiles = append(extraFiles, files...)
+		options = append(options, process.ProcEnvVar(ktype.EnvListenFds, env))
+	}
+	// 本副本已完成的一次性任务不在重启的副本子进程中再次执行
+	if jobs := that.completedJobsEnv(r.Index); jobs != "" {
+		options = append(options, process.ProcEnvVar(ktype.EnvCompletedJobs, jobs))
+	}
+	// 运行时移除的App在新的副本子进程中不再启动
+	if apps := that.removedAppsEnv(); apps != "" {
+		options = append(options, process.ProcEnvVar(ktype.EnvRemovedApps, apps))
+	}
+	// 运行时添加的App在新的副本子进程中重新创建
+	if apps := that.dynamicAppsEnv(); apps != "" {
+		options = append(options, process.ProcEnvVar(ktype.EnvDynamicApps, apps))
+	}
+	options = append(options, process.ProcExtraFiles(extraFiles))
+	for k, v := range ktrace.InjectEnv(ctx) {
+		options = append(options, process.ProcEnvVar(k, v))
+	}
+	for _, option := range options {
+		option(p)
+	}
+	return p
+}
+
+// startReplica 开启序号为index的副本子进程
+func (that *Executor) startReplica(index int) (err error) {
+	ctx, span := ktrace.Start(that.Keeper.TraceContext(), "executor.replica.start",
+		ktrace.AttrExecutor.String(that.Name), ktrace.AttrReplica.Int(index))
+	defer func() { ktrace.End(span, err) }()
+	r, err := that.newReplica(ctx, index)
+	if err != nil {
+		return err
+	}
+	if err = that.runReplica(r); err != nil {
+		r.close()
+		return err
+	}
+	that.Keeper.SaveState()
+	logger.Printf("Executor[%s]的副本[%d]已启动, pid: %d", that.Name, index, r.Process.Pid)
+	that.Keeper.PublishEvent(r.newEvent(kevent.ReplicaStarted, that.Name))
+	return nil
+}
+
+// runReplica 启动副本子进程，并将副本保存到Replicas中
+func (that *Executor) runReplica(r *Replica) error {
+	r.touch() // 子进程启动后才开始计算心跳超时
+	if r.stderr != nil {
+		r.stderr.tail.Reset()
+	}
+	r.StartProc(true)
+	if r.Process == nil {
+		return gerror.Newf("Executor[%s]的副本[%d]启动失败", that.Name, r.Index)
+	}
+	that.Replicas.Set(r.Index, r)
+	if r.Index == 0 {
+		// 主进程中，序号为0的副本作为Executor对应的进程
+		that.Pid = r.Process.Pid
+		that.ProcessPlus = r.ProcessPlus
+	}
+	return nil
+}
+
+// newEvent 创建副本相关的事件
+func (that *Replica) newEvent(t kevent.Type, execName string) *kevent.Event {
+	e := &kevent.Event{Type: t, Executor: execName, Replica: that.Name}
+	if that.Process != nil {
+		e.Pid = that.Process.Pid
+	}
+	return e
+}
+
+// close 关闭副本的通信通道
+func (that *Replica) close() {
+	that.Channel.Close()
+	_ = that.childFile.Close()
+	if that.stderr != nil {
+		that.stderr.close()
+	}
+}
+
+// retireReplica 平滑关闭序号为index的副本子进程
+func (that *Executor) retireReplica(index int) {
+	v := that.Replicas.Remove(index)
+	if v == nil {
+		return
+	}
+	r := v.(*Replica)
+	r.StopProc(true)
+	r.close()
+	// 之后扩容复用该序号的副本子进程需要重新执行一次性任务
+	that.completedJobs.Remove(index)
+	that.Keeper.SaveState()
+	logger.Printf("Executor[%s]的副本[%d]已关闭", that.Name, index)
+}
+
+// ReplicaList 获取按序号排列的副本列表
+func (that *Executor) ReplicaList() []*Replica {
+	indexes := that.Replicas.Keys()
+	sort.Ints(indexes)
+	list := make([]*Replica, 0, len(indexes))
+	for _, i := range indexes {
+		if v, ok := that.Replicas.Search(i); ok {
+			list = append(list, v.(*Replica))
+		}
+	}
+	return list
+}
+
+// Ready 副本子进程是否已报告App全部启动
+func (that *Replica) Ready() bool {
+	return that.Process != nil && atomic.LoadInt64(that.readyPid) == int64(that.Process.Pid) && !that.exited()
+}
+
+// ReadyReplicas 获取App已全部启动的副本数
+func (that *Executor) ReadyReplicas() (n int) {
+	for _, r := range that.ReplicaList() {
+		if r.Ready() {
+			n++
+		}
+	}
+	return
+}
+
+// freeIndex 获取最小的未使用的副本序号
+func (that *Executor) freeIndex() int {
+	i := 0
+	for that.Replicas.Contains(i) {
+		i++
+	}
+	return i
+}
+
+// StopReplicas 关闭所有的副本子进程，同时停止自动扩缩容和副本监控
+func (that *Executor) StopReplicas() {
+	that.scaleLock.Lock()
+	defer that.scaleLock.Unlock()
+	if that.scaleCancel != nil {
+		that.scaleCancel()
+		that.scaleCancel = nil
+	}
+	if that.superviseCancel != nil {
+		that.superviseCancel()
+		that.superviseCancel = nil
+	}
+	running := that.Replicas.Size() > 0
+	for _, r := range that.ReplicaList() {
+		that.retireReplica(r.Index)
+	}
+	that.ProcessPlus = nil
+	that.Pid = 0
+	if running {
+		that.Keeper.PublishEvent(that.newEvent(kevent.ExecutorStopped))
+	}
+}
+
+/*
+Scale 调整Executor的副本数；
+副本数增加时开启新的子进程，副本数减少时从序号最大的副本开始平滑关闭；
+本方法只在多进程模式的主进程中执行。
+*/
+func (that *Executor) Scale(n int) error {
+	if that.Keeper.Mode() != ktype.MultiProcs || !that.Keeper.IsMaster() {
+		return gerror.New("只有多进程模式下的主进程才能调整副本数")
+	}
+	if n < 1 {
+		return gerror.Newf("副本数[%d]不能小于1", n)
+	}
+	that.scaleLock.Lock()
+	defer that.scaleLock.Unlock()
+	if that.Replicas.Size() == 0 {
+		return gerror.Newf("Executor[%s]未运行", that.Name)
+	}
+	from := that.Replicas.Size()
+	if from == n {
+		return nil
+	}
+	defer func() {
+		e := that.newEvent(kevent.ExecutorScaled).Set("from", from).Set("to", that.Replicas.Size())
+		that.Keeper.PublishEvent(e)
+	}()
+	for that.Replicas.Size() < n {
+		if err := that.startReplica(that.freeIndex()); err != nil {
+			return err
+		}
+	}
+	for list := that.ReplicaList(); len(list) > n; list = list[:len(list)-1] {
+		that.retireReplica(list[len(list)-1].Index)
+	}
+	return nil
+}
+
+/*
+RequestReplicas 向所有正在运行的副本子进程发送消息，并等待各个副本的回复；
+某个副本处理失败时，不影响其他副本，返回的错误中包含所有失败副本的错误。
+*/
+func (that *Executor) RequestReplicas(msg *kipc.Message) (replies []*kipc.Message, err error) {
+	var errs []string
+	for _, r := range that.ReplicaList() {
+		if !r.IsRunning() {
+			continue
+		}
+		m := *msg // 每个通道的消息序号各不相同
+		reply, e := r.Channel.Request(&m, kipc.DefaultTimeout)
+		if e != nil {
+			e = gerror.Newf("Executor[%s]的副本[%d]: %v", that.Name, r.Index, e)
+			logger.Warning(e)
+			errs = append(errs, e.Error())
+			continue
+		}
+		replies = append(replies, reply)
+	}
+	if len(errs) > 0 {
+		err = gerror.New(strings.Join(errs, "; "))
+	}
+	return
+}
@@ -0,0 +1,23 @@
+//go:build linux
+// +build linux
+
+package kexecutor
+
+import (
+	"syscall"
+
+	process "github.com/moqsien/processes"
+)
+
+/*
+procParentDeathSignal 创建子进程的线程退出时(例如主进程被SIGKILL)，由内核向子进程发送sig；
+子进程收到退出信号后会平滑关闭其中的App。
+*/
+func procParentDeathSignal(sig syscall.Signal) process.Option {
+	return func(p *process.ProcessPlus) {
+		if p.SysProcAttr == nil {
+			p.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+		}
+		p.SysProcAttr.Pdeathsig = sig
+	}
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package kexecutor
+
+import (
+	"syscall"
+
+	process "github.com/moqsien/processes"
+)
+
+// procParentDeathSignal 非Linux系统不支持，子进程通过检查父进程pid来发现主进程退出
+func procParentDeathSignal(_ syscall.Signal) process.Option {
+	return func(p *process.ProcessPlus) {}
+}
@@ -0,0 +1,29 @@
+package kexecutor
+
+import (
+	"context"
+	"testing"
+
+	ktype "github.com/moqsien/gokeeper/ktype"
+)
+
+func TestReplicaProcEnv(t *testing.T) {
+	e := NewExecutor("web", newFakeKeeper(ktype.MultiProcs, true))
+	p := e.newReplicaProc(context.Background(), &Replica{Index: 1})
+	env := p.Environment
+	cases := []struct {
+		key  string
+		want string
+	}{
+		{ktype.EnvIsChild, "true"},
+		{ktype.EnvIsMaster, "false"},
+		{ktype.EnvKeeperName, "test"},
+		{ktype.EnvIPCFd, "3"},
+		{ktype.AdminActionReloadEnvKey, ""}, // 不能继承gf平滑重启传递的监听套接字
+	}
+	for _, c := range cases {
+		if v, ok := env.Search(c.key); !ok || v != c.want {
+			t.Errorf("env %s = %q, %v, want %q", c.key, v, ok, c.want)
+		}
+	}
+}
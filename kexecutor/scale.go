@@ -0,0 +1,145 @@
+package kexecutor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/gogf/gf/util/gconv"
+	ktype "github.com/moqsien/gokeeper/ktype"
+	kutils "github.com/moqsien/gokeeper/kutils"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+ScalePolicy 自动扩缩容策略，配置示例：
+
+	executors:
+	  myExecutor:
+	    replicas: 2
+	    scale:
+	      min: 1
+	      max: 4
+	      cpuHigh: 70
+	      cpuLow: 20
+	      interval: 30s
+
+副本的平均CPU使用率高于cpuHigh时增加一个副本，低于cpuLow时减少一个副本。
+*/
+type ScalePolicy struct {
+	Min      int           // 最少副本数
+	Max      int           // 最多副本数，为0表示未开启自动扩缩容
+	CPUHigh  float64       // 平均CPU使用率(%)高于该值时扩容
+	CPULow   float64       // 平均CPU使用率(%)低于该值时缩容
+	Interval time.Duration // CPU使用率采样间隔
+}
+
+// GetScalePolicy 从配置文件中读取Executor的扩缩容策略
+func (that *Executor) GetScalePolicy() *ScalePolicy {
+	node := fmt.Sprintf("%s.%s.scale", ktype.ConfigNodeNameExecutors, that.Name)
+	cfg := that.Keeper.Config()
+	policy := &ScalePolicy{
+		Min:      cfg.GetInt(node+".min", 1),
+		Max:      cfg.GetInt(node+".max", 0),
+		CPUHigh:  cfg.GetFloat64(node+".cpuHigh", 70),
+		CPULow:   cfg.GetFloat64(node+".cpuLow", 20),
+		Interval: cfg.GetDuration(node+".interval", 30*time.Second),
+	}
+	if policy.Min < 1 {
+		policy.Min = 1
+	}
+	if policy.Interval <= 0 {
+		policy.Interval = 30 * time.Second
+	}
+	return policy
+}
+
+// RunScalePolicy 如果配置了扩缩容策略，则在主进程中开启自动扩缩容
+func (that *Executor) RunScalePolicy() {
+	policy := that.GetScalePolicy()
+	if policy.Max < policy.Min {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	that.scaleLock.Lock()
+	if that.scaleCancel != nil {
+		that.scaleCancel()
+	}
+	that.scaleCancel = cancel
+	that.scaleLock.Unlock()
+	go that.autoScale(ctx, policy)
+}
+
+// autoScale 定时采样所有副本的CPU使用率，并根据策略调整副本数
+func (that *Executor) autoScale(ctx context.Context, policy *ScalePolicy) {
+	type sample struct {
+		cpu  time.Duration
+		time time.Time
+	}
+	samples := map[int]sample{} // key: pid
+	ticker := time.NewTicker(policy.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		var (
+			total   float64
+			counted int
+			current = map[int]sample{}
+		)
+		for _, r := range that.ReplicaList() {
+			if r.Process == nil || !r.IsRunning() {
+				continue
+			}
+			pid := r.Process.Pid
+			cpu, err := kutils.ProcCPUTime(pid)
+			if err != nil {
+				logger.Warningf("读取进程[%d]的CPU使用时间失败: %v", pid, err)
+				continue
+			}
+			now := time.Now()
+			current[pid] = sample{cpu: cpu, time: now}
+			if last, ok := samples[pid]; ok && now.After(last.time) {
+				total += cpuUsage(cpu-last.cpu, now.Sub(last.time))
+				counted++
+			}
+		}
+		samples = current
+		if counted == 0 {
+			continue
+		}
+		usage, n := total/float64(counted), that.Replicas.Size()
+		target := policy.target(usage, n)
+		if target == n || ctx.Err() != nil {
+			continue
+		}
+		logger.Printf("Executor[%s]平均CPU使用率为%.2f%%，副本数调整为%d", that.Name, usage, target)
+		err := that.Scale(target)
+		that.Keeper.AuditSupervisor("scale", []string{that.Name, gconv.String(target)}, err)
+		if err != nil {
+			logger.Warningf("Executor[%s]自动扩缩容失败: %v", that.Name, err)
+		}
+	}
+}
+
+// cpuUsage 计算一段墙钟时间内进程的CPU使用率(%)，多核并行时可以超过100
+func cpuUsage(cpu, wall time.Duration) float64 {
+	if wall <= 0 {
+		return 0
+	}
+	return float64(cpu) / float64(wall) * 100
+}
+
+// target 根据副本的平均CPU使用率和当前副本数，计算调整后的副本数；每次最多增减一个副本
+func (that *ScalePolicy) target(usage float64, n int) int {
+	if usage > that.CPUHigh && n < that.Max {
+		return n + 1
+	}
+	if usage < that.CPULow && n > that.Min {
+		return n - 1
+	}
+	return n
+}
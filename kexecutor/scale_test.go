@@ -0,0 +1,209 @@
+package kexecutor
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/gogf/gf/container/garray"
+	"github.com/gogf/gf/container/gmap"
+	"github.com/gogf/gf/os/gcfg"
+	kevent "github.com/moqsien/gokeeper/kevent"
+	ktype "github.com/moqsien/gokeeper/ktype"
+	kutils "github.com/moqsien/gokeeper/kutils"
+	process "github.com/moqsien/processes"
+)
+
+// testExitAfterEnv 设置后，副本子进程在该时间后以退出码1退出
+const testExitAfterEnv = "KEXECUTOR_TEST_EXIT_AFTER"
+
+// 副本子进程使用测试程序自身，收到停止信号后退出
+func TestMain(m *testing.M) {
+	if os.Getenv(ktype.EnvIsChild) == "true" {
+		sig := make(chan os.Signal, 1)
+		signal.Notify(sig, syscall.SIGQUIT, syscall.SIGTERM)
+		if d, err := time.ParseDuration(os.Getenv(testExitAfterEnv)); err == nil {
+			select {
+			case <-sig:
+			case <-time.After(d):
+				os.Exit(1)
+			}
+		} else {
+			<-sig
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+const testConfigFile = "kexecutor_test.json"
+
+type fakeKeeper struct {
+	mode    ktype.ProcMode
+	master  bool
+	manager *process.Manager
+	lock    sync.Mutex
+	events  []*kevent.Event
+}
+
+func newFakeKeeper(mode ktype.ProcMode, master bool) *fakeKeeper {
+	gcfg.SetContent("{}", testConfigFile)
+	return &fakeKeeper{mode: mode, master: master, manager: process.NewManager()}
+}
+
+func (that *fakeKeeper) Config() *gcfg.Config                { return gcfg.New(testConfigFile) }
+func (that *fakeKeeper) IsMaster() bool                      { return that.master }
+func (that *fakeKeeper) ListOfAppsToStart() *garray.StrArray { return garray.NewStrArray(true) }
+func (that *fakeKeeper) Mode() ktype.ProcMode                { return that.mode }
+func (that *fakeKeeper) NewProcess(name string, opts ...process.Option) (*process.ProcessPlus, error) {
+	return that.manager.NewProcess(name, opts...)
+}
+func (that *fakeKeeper) ProcManager() *process.Manager                               { return that.manager }
+func (that *fakeKeeper) GetExecutorsRunning() *gmap.StrAnyMap                        { return gmap.NewStrAnyMap(true) }
+func (that *fakeKeeper) GetKeeperName() string                                       { return "test" }
+func (that *fakeKeeper) SaveState()                                                  {}
+func (that *fakeKeeper) AuditSupervisor(command string, targets []string, err error) {}
+func (that *fakeKeeper) TraceContext() context.Context                               { return context.Background() }
+func (that *fakeKeeper) PublishEvent(e *kevent.Event) {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	that.events = append(that.events, e)
+}
+
+func (that *fakeKeeper) eventsOf(t kevent.Type) (list []*kevent.Event) {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	for _, e := range that.events {
+		if e.Type == t {
+			list = append(list, e)
+		}
+	}
+	return
+}
+
+func TestScaleRejected(t *testing.T) {
+	cases := []struct {
+		name   string
+		mode   ktype.ProcMode
+		master bool
+		n      int
+	}{
+		{"single process", ktype.SingleProc, true, 2},
+		{"child process", ktype.MultiProcs, false, 2},
+		{"less than one", ktype.MultiProcs, true, 0},
+		{"not running", ktype.MultiProcs, true, 2},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			e := NewExecutor("web", newFakeKeeper(c.mode, c.master))
+			if err := e.Scale(c.n); err == nil {
+				t.Fatalf("Scale(%d) should fail", c.n)
+			}
+			if e.Replicas.Size() != 0 {
+				t.Fatalf("%d replicas started", e.Replicas.Size())
+			}
+		})
+	}
+}
+
+func TestScale(t *testing.T) {
+	k := newFakeKeeper(ktype.MultiProcs, true)
+	e := NewExecutor("web", k)
+	if err := e.startReplica(0); err != nil {
+		t.Fatal(err)
+	}
+	defer e.StopReplicas()
+	indexes := func() (list []int) {
+		for _, r := range e.ReplicaList() {
+			if !r.IsRunning() {
+				t.Fatalf("replica %d is not running", r.Index)
+			}
+			list = append(list, r.Index)
+		}
+		return
+	}
+
+	if err := e.Scale(3); err != nil {
+		t.Fatal(err)
+	}
+	if got := indexes(); len(got) != 3 || got[0] != 0 || got[1] != 1 || got[2] != 2 {
+		t.Fatalf("replicas after scaling up: %v", got)
+	}
+	retired := e.ReplicaList()[1:]
+
+	if err := e.Scale(3); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Scale(1); err != nil {
+		t.Fatal(err)
+	}
+	if got := indexes(); len(got) != 1 || got[0] != 0 {
+		t.Fatalf("replicas after scaling down: %v", got)
+	}
+	for _, r := range retired {
+		if r.IsRunning() || kutils.PidExists(r.Process.Pid) {
+			t.Fatalf("retired replica %d (pid %d) is still running", r.Index, r.Process.Pid)
+		}
+	}
+
+	// 副本数不变时不发布事件
+	events := k.eventsOf(kevent.ExecutorScaled)
+	if len(events) != 2 {
+		t.Fatalf("%d scaled events, want 2", len(events))
+	}
+	for i, want := range [][2]int{{1, 3}, {3, 1}} {
+		if from, to := events[i].Data["from"], events[i].Data["to"]; from != want[0] || to != want[1] {
+			t.Fatalf("event %d: from %v to %v, want %v", i, from, to, want)
+		}
+	}
+}
+
+func TestScalePolicyTarget(t *testing.T) {
+	policy := &ScalePolicy{Min: 1, Max: 3, CPUHigh: 70, CPULow: 20}
+	cases := []struct {
+		name  string
+		usage float64
+		n     int
+		want  int
+	}{
+		{"high usage scales up", 80, 1, 2},
+		{"high usage stops at max", 80, 3, 3},
+		{"low usage scales down", 10, 3, 2},
+		{"low usage stops at min", 10, 1, 1},
+		{"usage in range", 50, 2, 2},
+		{"usage at high threshold", 70, 2, 2},
+		{"usage at low threshold", 20, 2, 2},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := policy.target(c.usage, c.n); got != c.want {
+				t.Fatalf("target(%v, %d) = %d, want %d", c.usage, c.n, got, c.want)
+			}
+		})
+	}
+}
+
+func TestCPUUsage(t *testing.T) {
+	cases := []struct {
+		name string
+		cpu  time.Duration
+		wall time.Duration
+		want float64
+	}{
+		{"idle", 0, time.Second, 0},
+		{"half a core", 500 * time.Millisecond, time.Second, 50},
+		{"two cores", 4 * time.Second, 2 * time.Second, 200},
+		{"no wall time", time.Second, 0, 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if got := cpuUsage(c.cpu, c.wall); got != c.want {
+				t.Fatalf("cpuUsage(%s, %s) = %v, want %v", c.cpu, c.wall, got, c.want)
+			}
+		})
+	}
+}
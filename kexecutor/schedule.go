@@ -0,0 +1,295 @@
+package kexecutor
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	"github.com/gogf/gf/os/gtime"
+	"github.com/gogf/gf/util/grand"
+	kapp "github.com/moqsien/gokeeper/kapp"
+	kcron "github.com/moqsien/gokeeper/kcron"
+	kevent "github.com/moqsien/gokeeper/kevent"
+	kipc "github.com/moqsien/gokeeper/kipc"
+	ktrace "github.com/moqsien/gokeeper/ktrace"
+	logger "github.com/moqsien/processes/logger"
+)
+
+// maxQueuedRuns overlap为queue时最多等待的执行次数，超过后跳过
+const maxQueuedRuns = 16
+
+/*
+appScheduler 定时App的调度器，App启动时创建，停止时结束；
+按照执行计划调用App的Execute，并在AppContainer中记录执行情况。
+*/
+type appScheduler struct {
+	executor *Executor
+	ac       *kapp.AppContainer
+	app      kapp.IScheduledApp
+	conf     *kapp.ScheduleConfig
+	schedule kcron.Schedule
+	lock     sync.Mutex
+	running  int  // 正在执行的次数
+	queued   int  // 等待执行的次数
+	stopped  bool // 调度器已结束
+	stop     chan struct{}
+	wg       sync.WaitGroup
+}
+
+// newAppScheduler 读取并检查App的执行计划
+func newAppScheduler(e *Executor, ac *kapp.AppContainer, app kapp.IScheduledApp) (*appScheduler, error) {
+	conf := app.Schedule()
+	if conf == nil {
+		return nil, gerror.NewCodef(gcode.CodeMissingParameter, "App[%s]未设置执行计划", app.AppName())
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, gerror.WrapCodef(gcode.CodeInvalidParameter, err, "App[%s]的执行计划错误", app.AppName())
+	}
+	schedule, err := kcron.Parse(conf.Spec)
+	if err != nil {
+		return nil, gerror.WrapCodef(gcode.CodeInvalidParameter, err, "App[%s]的执行计划错误", app.AppName())
+	}
+	return &appScheduler{
+		executor: e,
+		ac:       ac,
+		app:      app,
+		conf:     conf,
+		schedule: schedule,
+		stop:     make(chan struct{}),
+	}, nil
+}
+
+// start 开始按计划执行
+func (that *appScheduler) start() {
+	that.wg.Add(1)
+	go that.loop()
+}
+
+// loop 等待下一次执行时间，到达后执行
+func (that *appScheduler) loop() {
+	defer that.wg.Done()
+	planned := time.Now()
+	for {
+		// 从上一次计划执行的时间开始计算，错过的执行(例如系统休眠)不补执行
+		now := time.Now()
+		next := that.schedule.Next(planned)
+		if !next.IsZero() && next.Before(now) {
+			next = that.schedule.Next(now)
+		}
+		if next.IsZero() {
+			logger.Warningf("App[%s]的执行计划[%s]没有下一次执行时间", that.app.AppName(), that.conf.Spec)
+			that.setNextRun(time.Time{})
+			return
+		}
+		planned = next
+		if that.conf.Jitter > 0 {
+			next = next.Add(time.Duration(grand.Intn(int(that.conf.Jitter))))
+		}
+		that.setNextRun(next)
+		timer := time.NewTimer(next.Sub(now))
+		select {
+		case <-that.stop:
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if err := that.fire(); err != nil {
+			logger.Warning(err)
+		}
+	}
+}
+
+func (that *appScheduler) setNextRun(next time.Time) {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	if next.IsZero() {
+		that.ac.NextRun = nil
+		return
+	}
+	that.ac.NextRun = gtime.New(next)
+}
+
+// fire 执行一次，上一次执行还未结束时按照overlap处理
+func (that *appScheduler) fire() error {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	name := that.app.AppName()
+	if that.stopped {
+		return gerror.NewCodef(gcode.CodeInvalidOperation, "App[%s]未运行", name)
+	}
+	if that.running > 0 {
+		switch that.conf.Overlap {
+		case kapp.OverlapAllow:
+		case kapp.OverlapQueue:
+			if that.queued >= maxQueuedRuns {
+				return gerror.NewCodef(gcode.CodeInvalidOperation, "App[%s]等待执行的次数已达到%d，跳过本次执行", name, maxQueuedRuns)
+			}
+			that.queued++
+			return nil
+		default:
+			return gerror.NewCodef(gcode.CodeInvalidOperation, "App[%s]上一次执行还未结束，跳过本次执行", name)
+		}
+	}
+	that.running++
+	that.wg.Add(1)
+	go that.run()
+	return nil
+}
+
+// run 执行一次，结束后继续执行等待中的次数
+func (that *appScheduler) run() {
+	defer that.wg.Done()
+	for {
+		that.runOnce()
+		that.lock.Lock()
+		if that.queued > 0 && !that.stopped {
+			that.queued--
+			that.lock.Unlock()
+			continue
+		}
+		that.running--
+		that.lock.Unlock()
+		return
+	}
+}
+
+// runOnce 调用Execute，超时后结束本次执行并等待Execute返回；
+// App实现了IRunContextApp时只取消本次执行的ctx，否则调用Exit
+func (that *appScheduler) runOnce() {
+	name := that.app.AppName()
+	that.lock.Lock()
+	that.ac.LastRun = gtime.Now()
+	that.ac.Runs++
+	that.lock.Unlock()
+
+	_, span := ktrace.Start(that.executor.Keeper.TraceContext(), "app.run", that.executor.appAttrs(name)...)
+	start := time.Now()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rca, withCtx := that.app.(kapp.IRunContextApp)
+	done := make(chan error, 1)
+	go func() {
+		if withCtx {
+			done <- rca.ExecuteContext(ctx)
+			return
+		}
+		done <- that.app.Execute()
+	}()
+	var err error
+	if that.conf.Timeout > 0 {
+		timer := time.NewTimer(that.conf.Timeout)
+		select {
+		case err = <-done:
+			timer.Stop()
+		case <-timer.C:
+			if withCtx {
+				cancel()
+			} else if e := that.app.Exit(); e != nil {
+				logger.Warningf("App[%s]执行超时，调用Exit出错: %v", name, e)
+			}
+			<-done
+			err = gerror.NewCodef(gcode.CodeOperationFailed, "App[%s]执行超过%v，已结束本次执行", name, that.conf.Timeout)
+		}
+	} else {
+		err = <-done
+	}
+	ktrace.End(span, err)
+
+	that.lock.Lock()
+	that.ac.LastError = ""
+	if err != nil {
+		that.ac.LastError = err.Error()
+	}
+	stopped := that.stopped
+	that.lock.Unlock()
+	switch {
+	case err != nil && stopped:
+		// 停止App时被Exit结束的执行不作为失败
+		logger.Printf("App[%s]已停止，本次执行被结束: %v", name, err)
+	case err != nil:
+		logger.Warningf("App[%s]执行失败，耗时%v: %v", name, time.Since(start), err)
+		that.executor.publishApp(kevent.AppRunFailed, name, err)
+	default:
+		logger.Printf("App[%s]执行完成，耗时%v", name, time.Since(start))
+	}
+}
+
+// close 结束调度器，正在执行时调用Exit，并等待执行结束
+func (that *appScheduler) close() error {
+	that.lock.Lock()
+	if that.stopped {
+		that.lock.Unlock()
+		return nil
+	}
+	that.stopped = true
+	that.queued = 0
+	running := that.running
+	that.lock.Unlock()
+
+	close(that.stop)
+	var err error
+	if running > 0 {
+		err = that.app.Exit()
+	}
+	that.wg.Wait()
+	that.setNextRun(time.Time{})
+	return err
+}
+
+// TriggerApp 立即执行一次定时App，App需要处于运行状态
+func (that *Executor) TriggerApp(name string) error {
+	a, found := that.AppList.Search(name)
+	if !found {
+		return gerror.NewCodef(gcode.CodeNotFound, "未找到[%s]", name)
+	}
+	if _, ok := a.(*kapp.AppContainer).App.(kapp.IScheduledApp); !ok {
+		return gerror.NewCodef(gcode.CodeInvalidOperation, "App[%s]不是定时App", name)
+	}
+	v, found := that.runners.Search(name)
+	if !found {
+		return gerror.NewCodef(gcode.CodeInvalidOperation, "App[%s]未运行", name)
+	}
+	scheduler, ok := v.(*appScheduler)
+	if !ok {
+		// 等待依赖的一次性任务完成
+		return gerror.NewCodef(gcode.CodeInvalidOperation, "App[%s]正在启动", name)
+	}
+	return scheduler.fire()
+}
+
+// TriggerApps 立即执行多个定时App，返回触发成功的App列表
+func (that *Executor) TriggerApps(names ...string) (triggered []string) {
+	for _, name := range names {
+		if err := that.TriggerApp(name); err != nil {
+			logger.Warning(err)
+			continue
+		}
+		triggered = append(triggered, name)
+	}
+	return
+}
+
+// scheduleState 在App的运行状态中填充定时App的执行情况
+func (that *Executor) scheduleState(ac *kapp.AppContainer, state *kipc.AppState) {
+	sa, ok := ac.App.(kapp.IScheduledApp)
+	if !ok {
+		return
+	}
+	if scheduler, ok := that.runners.Get(ac.App.AppName()).(*appScheduler); ok {
+		scheduler.lock.Lock()
+		defer scheduler.lock.Unlock()
+		state.Schedule = scheduler.conf.Spec
+	} else if conf := sa.Schedule(); conf != nil {
+		state.Schedule = conf.Spec
+	}
+	if ac.LastRun != nil {
+		state.LastRun = ac.LastRun.String()
+	}
+	if ac.NextRun != nil {
+		state.NextRun = ac.NextRun.String()
+	}
+	state.LastError = ac.LastError
+	state.Runs = ac.Runs
+}
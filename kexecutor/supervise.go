@@ -0,0 +1,266 @@
+package kexecutor
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	kevent "github.com/moqsien/gokeeper/kevent"
+	kipc "github.com/moqsien/gokeeper/kipc"
+	ktrace "github.com/moqsien/gokeeper/ktrace"
+	ktype "github.com/moqsien/gokeeper/ktype"
+	process "github.com/moqsien/processes"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+SupervisePolicy 副本子进程的监控策略，配置示例：
+
+	executors:
+	  myExecutor:
+	    autoRestart: true
+	    heartbeat:
+	      interval: 5s
+	      timeout: 30s
+	    crashLoop:
+	      exits: 5
+	      window: 1m
+	    exitHistory: 20
+	    exitLogLines: 20
+
+autoRestart与process.AutoReStart的取值相同：true总是重启，unexpected非预期退出时重启，false不重启；
+子进程每隔interval向主进程发送一次心跳，主进程超过timeout未收到心跳时，认为子进程已失去响应，
+获取子进程的goroutine调用栈写入Executor的日志，然后强制结束子进程，并按照autoRestart重启；
+子进程发送心跳前检查实现了kapp.ILivenessApp的App，App卡住时子进程不发送心跳；
+子进程在window时间内退出exits次时，发布replica.crashloop事件；
+主进程保存每个Executor最近exitHistory次子进程退出的记录，每条记录包含标准错误的最后exitLogLines行。
+*/
+type SupervisePolicy struct {
+	AutoRestart       process.AutoReStart // 子进程退出后的重启策略
+	HeartbeatInterval time.Duration       // 子进程发送心跳的间隔
+	HeartbeatTimeout  time.Duration       // 超过该时间未收到心跳，则认为子进程失去响应
+	CrashLoopExits    int                 // 在CrashLoopWindow时间内退出的次数达到该值时，认为子进程在反复退出
+	CrashLoopWindow   time.Duration       // 判断子进程反复退出的时间窗口
+	ExitHistory       int                 // 保存的退出记录数
+	ExitLogLines      int                 // 每条退出记录保存的标准错误行数
+}
+
+// GetSupervisePolicy 从配置文件中读取Executor的监控策略
+func (that *Executor) GetSupervisePolicy() *SupervisePolicy {
+	node := fmt.Sprintf("%s.%s", ktype.ConfigNodeNameExecutors, that.Name)
+	cfg := that.Keeper.Config()
+	policy := &SupervisePolicy{
+		AutoRestart:       process.AutoReStart(cfg.GetString(node+".autoRestart", string(process.AutoReStartTrue))),
+		HeartbeatInterval: cfg.GetDuration(node+".heartbeat.interval", 5*time.Second),
+		HeartbeatTimeout:  cfg.GetDuration(node+".heartbeat.timeout", 30*time.Second),
+		CrashLoopExits:    cfg.GetInt(node+".crashLoop.exits", 5),
+		CrashLoopWindow:   cfg.GetDuration(node+".crashLoop.window", time.Minute),
+		ExitHistory:       cfg.GetInt(node+".exitHistory", 20),
+		ExitLogLines:      cfg.GetInt(node+".exitLogLines", 20),
+	}
+	if policy.HeartbeatInterval <= 0 {
+		policy.HeartbeatInterval = 5 * time.Second
+	}
+	if policy.HeartbeatTimeout < policy.HeartbeatInterval {
+		policy.HeartbeatTimeout = 6 * policy.HeartbeatInterval
+	}
+	if policy.CrashLoopExits < 2 {
+		policy.CrashLoopExits = 5
+	}
+	if policy.CrashLoopWindow <= 0 {
+		policy.CrashLoopWindow = time.Minute
+	}
+	if policy.ExitHistory <= 0 {
+		policy.ExitHistory = 20
+	}
+	if policy.ExitLogLines < 0 {
+		policy.ExitLogLines = 0
+	}
+	return policy
+}
+
+// touch 记录收到心跳的时间
+func (that *Replica) touch() {
+	atomic.StoreInt64(&that.lastHeartbeat, time.Now().UnixNano())
+	atomic.StoreInt32(&that.unresponsive, 0)
+}
+
+// LastStatus 副本子进程最近一次心跳或者启动完成时报告的运行状态，未报告过时为nil
+func (that *Replica) LastStatus() *kipc.Status {
+	status, _ := that.status.Load().(*kipc.Status)
+	return status
+}
+
+// IsUnresponsive 副本子进程是否已失去响应
+func (that *Replica) IsUnresponsive() bool {
+	return atomic.LoadInt32(&that.unresponsive) == 1
+}
+
+// LastHeartbeat 最近一次收到心跳的时间
+func (that *Replica) LastHeartbeat() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&that.lastHeartbeat))
+}
+
+// exited 副本子进程已经退出，且不是被用户主动关闭的
+func (that *Replica) exited() bool {
+	that.Lock.RLock()
+	defer that.Lock.RUnlock()
+	return !that.Starting && !that.StopByUser && that.ProcessState != nil
+}
+
+// RunSupervisor 在主进程中开启副本监控
+func (that *Executor) RunSupervisor() {
+	ctx, cancel := context.WithCancel(context.Background())
+	that.scaleLock.Lock()
+	if that.superviseCancel != nil {
+		that.superviseCancel()
+	}
+	that.superviseCancel = cancel
+	that.scaleLock.Unlock()
+	atomic.StoreInt64(&that.superviseTick, time.Now().UnixNano())
+	go that.superviseReplicas(ctx, that.GetSupervisePolicy())
+}
+
+// SupervisorAlive 副本监控是否在timeout内运行过，用于判断主进程是否卡住
+func (that *Executor) SupervisorAlive(timeout time.Duration) bool {
+	tick := atomic.LoadInt64(&that.superviseTick)
+	return tick > 0 && time.Since(time.Unix(0, tick)) < timeout
+}
+
+// superviseReplicas 每秒检查一次所有副本子进程的心跳和退出情况
+func (that *Executor) superviseReplicas(ctx context.Context, policy *SupervisePolicy) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		atomic.StoreInt64(&that.superviseTick, time.Now().UnixNano())
+		for _, r := range that.ReplicaList() {
+			if r.exited() {
+				that.restartReplica(ctx, r, policy)
+			} else if r.IsRunning() && time.Since(r.LastHeartbeat()) > policy.HeartbeatTimeout &&
+				atomic.CompareAndSwapInt32(&r.unresponsive, 0, 1) {
+				e := r.newEvent(kevent.ReplicaUnresponsive, that.Name)
+				e.Message = fmt.Sprintf("no heartbeat for %v", time.Since(r.LastHeartbeat()).Truncate(time.Second))
+				that.Keeper.PublishEvent(e)
+				go that.killUnresponsive(r)
+			}
+		}
+	}
+}
+
+/*
+killUnresponsive 处理失去响应的副本子进程；
+先通过IPC通道获取goroutine调用栈，失败时发送SIGABRT，由go运行时将调用栈输出到子进程的标准错误；
+然后强制结束子进程，由superviseReplicas按照重启策略重启。
+*/
+func (that *Executor) killUnresponsive(r *Replica) {
+	logger.Warningf("Executor[%s]的副本[%d]超过%v未发送心跳，已失去响应",
+		that.Name, r.Index, time.Since(r.LastHeartbeat()).Truncate(time.Second))
+	reply, err := r.Channel.Request(kipc.NewMessage(kipc.MsgStack), 5*time.Second)
+	var stack string
+	if err == nil && reply.GetData(&stack) == nil && r.StdoutLog != nil {
+		_, _ = fmt.Fprintf(r.StdoutLog, "=== Executor[%s]的副本[%d]失去响应时的goroutine调用栈 ===\n%s\n", that.Name, r.Index, stack)
+	} else {
+		_ = r.Signal(syscall.SIGABRT, false)
+		time.Sleep(time.Second)
+	}
+	err = r.Signal(syscall.SIGKILL, false)
+	that.Keeper.AuditSupervisor("kill", []string{r.Name}, err)
+}
+
+// restartReplica 按照重启策略重启已经退出的副本子进程
+func (that *Executor) restartReplica(ctx context.Context, r *Replica, policy *SupervisePolicy) {
+	that.scaleLock.Lock()
+	defer that.scaleLock.Unlock()
+	// 加锁后再次确认副本没有被关闭或者替换
+	if that.replicaReplaced(ctx, r) {
+		return
+	}
+	code, _ := r.GetExitCode()
+	if !r.exitReported {
+		// 重启失败时会再次进入本方法，退出事件只发布一次
+		r.exitReported = true
+		record := r.newExitRecord(policy.ExitLogLines)
+		that.recordExit(record, policy.ExitHistory)
+		logger.Warningf("Executor[%s]的副本[%d] %s", that.Name, r.Index, record.Summary())
+		e := r.newEvent(kevent.ReplicaExited, that.Name).WithExitCode(code).Set("exit", record)
+		e.Message = record.Summary()
+		that.Keeper.PublishEvent(e)
+		that.checkCrashLoop(r, policy)
+	}
+	restart := policy.AutoRestart == process.AutoReStartTrue ||
+		(policy.AutoRestart == process.AutoReStartUnexpected && !r.InExitCodes(code))
+	if !restart {
+		logger.Printf("Executor[%s]的副本[%d]已退出，退出码: %d，不需要重启", that.Name, r.Index, code)
+		that.Replicas.Remove(r.Index)
+		r.close()
+		that.Keeper.SaveState()
+		return
+	}
+	/*
+	  进程启动后很快退出时，推迟一会再重启，避免频繁重启耗尽资源；
+	  推迟期间直接返回，由之后每秒一次的检查重启，不阻塞其他副本的监控和调整副本数等操作
+	*/
+	if r.restartAt.IsZero() && r.exitTime().Sub(r.StartTime) < 2*time.Second {
+		r.restartAt = time.Now().Add(3 * time.Second)
+	}
+	if time.Now().Before(r.restartAt) {
+		return
+	}
+	spanCtx, span := ktrace.Start(that.Keeper.TraceContext(), "executor.replica.restart",
+		ktrace.AttrExecutor.String(that.Name), ktrace.AttrReplica.Int(r.Index))
+	restarted := &Replica{
+		Index:     r.Index,
+		Channel:   r.Channel,
+		Restarts:  r.Restarts + 1,
+		childFile: r.childFile,
+		stderr:    r.stderr,
+		readyPid:  r.readyPid,
+		exitTimes: r.exitTimes,
+	}
+	restarted.ProcessPlus = that.newReplicaProc(spanCtx, restarted)
+	err := that.runReplica(restarted)
+	ktrace.End(span, err)
+	that.Keeper.AuditSupervisor("restart", []string{r.Name}, err)
+	if err != nil {
+		logger.Warning(err)
+		return
+	}
+	that.Keeper.SaveState()
+	logger.Printf("Executor[%s]的副本[%d]已退出，退出码: %d，已重启, pid: %d",
+		that.Name, r.Index, code, restarted.Process.Pid)
+	that.Keeper.PublishEvent(restarted.newEvent(kevent.ReplicaRestarted, that.Name).Set("restarts", restarted.Restarts))
+}
+
+// replicaReplaced 副本已经被关闭或者替换，或者监控已停止，需要在scaleLock中调用
+func (that *Executor) replicaReplaced(ctx context.Context, r *Replica) bool {
+	v, ok := that.Replicas.Search(r.Index)
+	return !ok || v != r || ctx.Err() != nil
+}
+
+// checkCrashLoop 记录副本子进程的退出时间，在时间窗口内退出次数达到上限时发布replica.crashloop事件
+func (that *Executor) checkCrashLoop(r *Replica, policy *SupervisePolicy) {
+	now := time.Now()
+	times := []time.Time{now}
+	for _, t := range r.exitTimes {
+		if now.Sub(t) < policy.CrashLoopWindow {
+			times = append(times, t)
+		}
+	}
+	r.exitTimes = times
+	if len(times) < policy.CrashLoopExits {
+		return
+	}
+	logger.Warningf("Executor[%s]的副本[%d]在%v内退出了%d次", that.Name, r.Index, policy.CrashLoopWindow, len(times))
+	e := r.newEvent(kevent.ReplicaCrashLoop, that.Name).Set("exits", len(times)).Set("window", policy.CrashLoopWindow.String())
+	e.Message = fmt.Sprintf("exited %d times within %v", len(times), policy.CrashLoopWindow)
+	that.Keeper.PublishEvent(e)
+	// 重新开始计数，避免每次退出都发布事件
+	r.exitTimes = nil
+}
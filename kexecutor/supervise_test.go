@@ -0,0 +1,43 @@
+package kexecutor
+
+import (
+	"testing"
+	"time"
+
+	kevent "github.com/moqsien/gokeeper/kevent"
+	ktype "github.com/moqsien/gokeeper/ktype"
+)
+
+// 很快退出的副本推迟重启，推迟期间监控继续运行
+func TestRestartBackoffDoesNotBlockSupervisor(t *testing.T) {
+	t.Setenv(testExitAfterEnv, "1200ms") // 超过启动检查的1秒，但在2秒内退出
+	k := newFakeKeeper(ktype.MultiProcs, true)
+	e := NewExecutor("web", k)
+	if err := e.startReplica(0); err != nil {
+		t.Fatal(err)
+	}
+	defer e.StopReplicas()
+	e.RunSupervisor()
+
+	var exitedAt time.Time
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+		if !e.SupervisorAlive(1500 * time.Millisecond) {
+			t.Fatal("supervisor is blocked")
+		}
+		if exitedAt.IsZero() && len(k.eventsOf(kevent.ReplicaExited)) > 0 {
+			exitedAt = time.Now()
+		}
+		if len(k.eventsOf(kevent.ReplicaRestarted)) > 0 {
+			if exitedAt.IsZero() {
+				t.Fatal("restarted before the exit event")
+			}
+			if waited := time.Since(exitedAt); waited < 2500*time.Millisecond {
+				t.Fatalf("restarted %v after exiting, want about 3s", waited)
+			}
+			return
+		}
+	}
+	t.Fatal("replica is not restarted")
+}
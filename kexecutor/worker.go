@@ -0,0 +1,47 @@
+package kexecutor
+
+import (
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	kapp "github.com/moqsien/gokeeper/kapp"
+	kipc "github.com/moqsien/gokeeper/kipc"
+	logger "github.com/moqsien/processes/logger"
+)
+
+// ResizeApp 调整WorkerApp的并发数
+func (that *Executor) ResizeApp(name string, n int) error {
+	a, found := that.AppList.Search(name)
+	if !found {
+		return gerror.NewCodef(gcode.CodeNotFound, "未找到[%s]", name)
+	}
+	wa, ok := a.(*kapp.AppContainer).App.(kapp.IWorkerApp)
+	if !ok {
+		return gerror.NewCodef(gcode.CodeInvalidOperation, "App[%s]不支持调整并发数", name)
+	}
+	return wa.Resize(n)
+}
+
+// ResizeApps 调整多个WorkerApp的并发数，返回调整成功的App列表
+func (that *Executor) ResizeApps(n int, names ...string) (resized []string) {
+	for _, name := range names {
+		if err := that.ResizeApp(name, n); err != nil {
+			logger.Warning(err)
+			continue
+		}
+		resized = append(resized, name)
+	}
+	return
+}
+
+// workerState 在App的运行状态中填充WorkerApp的并发数和任务数
+func (that *Executor) workerState(ac *kapp.AppContainer, state *kipc.AppState) {
+	wa, ok := ac.App.(kapp.IWorkerApp)
+	if !ok {
+		return
+	}
+	stats := wa.Stats()
+	state.Workers = stats.Concurrency
+	state.InFlight = stats.InFlight
+	state.Processed = stats.Processed
+	state.Failed = stats.Failed
+}
@@ -0,0 +1,193 @@
+package kgrace
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	"github.com/gogf/gf/os/genv"
+	"github.com/gogf/gf/text/gstr"
+	"github.com/gogf/gf/util/gconv"
+	ktype "github.com/moqsien/gokeeper/ktype"
+	kutils "github.com/moqsien/gokeeper/kutils"
+)
+
+/*
+  继承的监听套接字：
+    - 主进程读取systemd socket activation传来的套接字(LISTEN_FDS/LISTEN_PID/LISTEN_FDNAMES)，
+      名称为socket unit中的FileDescriptorName，未设置时为socket unit的名称；
+    - 多进程模式下，主进程通过ExtraFiles将这些套接字传给每个副本子进程，名称和描述符写入环境变量ktype.EnvListenFds；
+    - App按名称获取继承的套接字，没有继承到时自己监听：
+        ln, err := kgrace.Listen("web", "tcp", ":80")
+  这样keeper不需要root权限就能使用特权端口，keeper重启时套接字由systemd保持，不会拒绝新的连接。
+*/
+
+const (
+	EnvSystemdListenFds     = "LISTEN_FDS"
+	EnvSystemdListenPid     = "LISTEN_PID"
+	EnvSystemdListenFdNames = "LISTEN_FDNAMES"
+	systemdListenFdsStart   = 3         // systemd传来的第一个套接字的描述符
+	systemdUnknownName      = "unknown" // 未设置LISTEN_FDNAMES时的名称
+)
+
+// File 继承的套接字
+type File struct {
+	Name string
+	File *os.File
+}
+
+var (
+	inheritOnce sync.Once
+	inheritErr  error
+	files       []*File
+)
+
+/*
+Inherit 读取继承的套接字，只在第一次调用时执行：
+子进程中读取主进程通过ktype.EnvListenFds传来的套接字，否则读取systemd传来的套接字。
+*/
+func Inherit() error {
+	inheritOnce.Do(func() {
+		if value := genv.Get(ktype.EnvListenFds); value != "" {
+			inheritErr = inheritFromMaster(value)
+			return
+		}
+		inheritErr = inheritFromSystemd()
+	})
+	return inheritErr
+}
+
+// inheritFromSystemd 读取systemd传来的套接字，读取后清除相关环境变量，避免传给子进程后被误用
+func inheritFromSystemd() error {
+	defer func() {
+		_ = genv.Remove(EnvSystemdListenFds, EnvSystemdListenPid, EnvSystemdListenFdNames)
+	}()
+	count := gconv.Int(genv.Get(EnvSystemdListenFds))
+	if count <= 0 {
+		return nil
+	}
+	if pid := gconv.Int(genv.Get(EnvSystemdListenPid)); pid != os.Getpid() {
+		return gerror.NewCodef(gcode.CodeInvalidParameter, "%s=%d与当前进程不符，忽略systemd传来的套接字", EnvSystemdListenPid, pid)
+	}
+	var names []string
+	if value := genv.Get(EnvSystemdListenFdNames); value != "" {
+		names = strings.Split(value, ":")
+	}
+	for i := 0; i < count; i++ {
+		name := systemdUnknownName
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		fd := systemdListenFdsStart + i
+		kutils.CloseOnExec(fd)
+		files = append(files, &File{Name: name, File: os.NewFile(uintptr(fd), name)})
+	}
+	return nil
+}
+
+// inheritFromMaster 读取主进程传来的套接字，value格式为name:fd,name:fd
+func inheritFromMaster(value string) error {
+	for _, item := range gstr.SplitAndTrim(value, ",") {
+		pos := strings.LastIndex(item, ":")
+		if pos <= 0 {
+			return gerror.NewCodef(gcode.CodeInvalidParameter, "%s格式错误: %s", ktype.EnvListenFds, value)
+		}
+		name, fd := item[:pos], gconv.Int(item[pos+1:])
+		if fd < systemdListenFdsStart {
+			return gerror.NewCodef(gcode.CodeInvalidParameter, "%s格式错误: %s", ktype.EnvListenFds, value)
+		}
+		kutils.CloseOnExec(fd)
+		files = append(files, &File{Name: name, File: os.NewFile(uintptr(fd), name)})
+	}
+	return nil
+}
+
+// Files 所有继承的套接字
+func Files() []*File {
+	return append([]*File{}, files...)
+}
+
+/*
+ChildFiles 需要传给子进程的套接字，以及子进程中ktype.EnvListenFds的值；
+start为第一个套接字在子进程中的描述符，即ExtraFiles中已有的文件数+3。
+*/
+func ChildFiles(start int) ([]*os.File, string) {
+	list := make([]*os.File, 0, len(files))
+	items := make([]string, 0, len(files))
+	for i, f := range files {
+		list = append(list, f.File)
+		items = append(items, fmt.Sprintf("%s:%d", f.Name, start+i))
+	}
+	return list, strings.Join(items, ",")
+}
+
+// find 名称为name的套接字
+func find(name string) []*File {
+	_ = Inherit()
+	var found []*File
+	for _, f := range files {
+		if f.Name == name {
+			found = append(found, f)
+		}
+	}
+	return found
+}
+
+/*
+Listeners 名称为name的所有流式套接字(TCP、unix stream)；
+每次调用都会复制一份文件描述符，关闭返回的net.Listener不影响继承的套接字。
+*/
+func Listeners(name string) ([]net.Listener, error) {
+	var listeners []net.Listener
+	for _, f := range find(name) {
+		if ln, err := net.FileListener(f.File); err == nil {
+			listeners = append(listeners, ln)
+		}
+	}
+	if len(listeners) == 0 {
+		return nil, gerror.NewCodef(gcode.CodeNotFound, "未继承名称为[%s]的监听套接字", name)
+	}
+	return listeners, nil
+}
+
+// Listener 名称为name的第一个流式套接字
+func Listener(name string) (net.Listener, error) {
+	listeners, err := Listeners(name)
+	if err != nil {
+		return nil, err
+	}
+	for _, ln := range listeners[1:] {
+		_ = ln.Close()
+	}
+	return listeners[0], nil
+}
+
+// PacketConn 名称为name的第一个数据报套接字(UDP、unix datagram)
+func PacketConn(name string) (net.PacketConn, error) {
+	for _, f := range find(name) {
+		if conn, err := net.FilePacketConn(f.File); err == nil {
+			return conn, nil
+		}
+	}
+	return nil, gerror.NewCodef(gcode.CodeNotFound, "未继承名称为[%s]的数据报套接字", name)
+}
+
+// Listen 优先使用继承的名称为name的套接字，没有继承到时监听address
+func Listen(name, network, address string) (net.Listener, error) {
+	if ln, err := Listener(name); err == nil {
+		return ln, nil
+	}
+	return net.Listen(network, address)
+}
+
+// ListenPacket 优先使用继承的名称为name的数据报套接字，没有继承到时监听address
+func ListenPacket(name, network, address string) (net.PacketConn, error) {
+	if conn, err := PacketConn(name); err == nil {
+		return conn, nil
+	}
+	return net.ListenPacket(network, address)
+}
@@ -0,0 +1,179 @@
+package kipc
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gogf/gf/container/gmap"
+	"github.com/gogf/gf/errors/gerror"
+	"github.com/gogf/gf/os/genv"
+	ktype "github.com/moqsien/gokeeper/ktype"
+	logger "github.com/moqsien/processes/logger"
+)
+
+/*
+  主进程与子进程之间的双向通信通道；
+  主进程通过socketpair创建一对相连的unix套接字，其中一端作为子进程的ExtraFiles传给子进程，
+  子进程通过环境变量ktype.EnvIPCFd得知该套接字的文件描述符。
+  消息以JSON格式逐行传输。
+*/
+
+// DefaultTimeout 等待回复的默认超时时间
+const DefaultTimeout = 10 * time.Second
+
+// Handler 消息处理方法，返回值为回复消息，返回nil表示不需要回复
+type Handler func(msg *Message) *Message
+
+// Channel 双向通信通道
+type Channel struct {
+	conn      net.Conn
+	encoder   *json.Encoder
+	writeLock sync.Mutex
+	seq       uint64
+	pending   *gmap.AnyAnyMap // 等待回复的请求，key: 消息序号，value: chan *Message
+	handlers  *gmap.StrAnyMap // 消息处理方法，key: 消息类型，value: Handler
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewPair 创建一对相连的套接字，返回主进程使用的Channel，以及需要传给子进程的文件
+func NewPair() (*Channel, *os.File, error) {
+	fds, err := syscall.Socketpair(syscall.AF_UNIX, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return nil, nil, err
+	}
+	// 传给子进程的一端在exec时会被dup到ExtraFiles对应的描述符上，因此两端都可以设置CloseOnExec
+	syscall.CloseOnExec(fds[0])
+	syscall.CloseOnExec(fds[1])
+	parentFile := os.NewFile(uintptr(fds[0]), "keeper-ipc-parent")
+	childFile := os.NewFile(uintptr(fds[1]), "keeper-ipc-child")
+	c, err := NewChannel(parentFile)
+	if err != nil {
+		_ = childFile.Close()
+		return nil, nil, err
+	}
+	return c, childFile, nil
+}
+
+// NewChannel 通过套接字文件创建Channel，f会被复制，调用方可以关闭f
+func NewChannel(f *os.File) (*Channel, error) {
+	conn, err := net.FileConn(f)
+	_ = f.Close()
+	if err != nil {
+		return nil, err
+	}
+	return &Channel{
+		conn:     conn,
+		encoder:  json.NewEncoder(conn),
+		pending:  gmap.NewAnyAnyMap(true),
+		handlers: gmap.NewStrAnyMap(true),
+		closed:   make(chan struct{}),
+	}, nil
+}
+
+// FromEnv 子进程中，通过环境变量获取主进程传过来的Channel
+func FromEnv() (*Channel, error) {
+	fd := genv.GetVar(ktype.EnvIPCFd, 0).Int()
+	if fd <= 0 {
+		return nil, gerror.Newf("环境变量[%s]未设置", ktype.EnvIPCFd)
+	}
+	return NewChannel(os.NewFile(uintptr(fd), "keeper-ipc-child"))
+}
+
+// Handle 注册消息处理方法
+func (that *Channel) Handle(t MsgType, h Handler) {
+	that.handlers.Set(string(t), h)
+}
+
+// Serve 循环读取消息，直到通道关闭；收到的回复消息交给等待中的请求，其他消息交给对应的Handler处理
+func (that *Channel) Serve() {
+	defer that.Close()
+	scanner := bufio.NewScanner(that.conn)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		msg := &Message{}
+		if err := json.Unmarshal(scanner.Bytes(), msg); err != nil {
+			logger.Warningf("IPC消息解析失败: %v", err)
+			continue
+		}
+		if msg.Reply {
+			if ch := that.pending.Remove(msg.Id); ch != nil {
+				ch.(chan *Message) <- msg
+			}
+			continue
+		}
+		go that.dispatch(msg)
+	}
+}
+
+func (that *Channel) dispatch(msg *Message) {
+	var reply *Message
+	if h, ok := that.handlers.Search(string(msg.Type)); ok {
+		reply = h.(Handler)(msg)
+	} else {
+		reply = &Message{Error: "unsupported message type: " + string(msg.Type)}
+	}
+	if reply == nil {
+		return
+	}
+	reply.Id, reply.Type, reply.Reply = msg.Id, msg.Type, true
+	if err := that.write(reply); err != nil {
+		logger.Warningf("IPC消息回复失败: %v", err)
+	}
+}
+
+func (that *Channel) write(msg *Message) error {
+	that.writeLock.Lock()
+	defer that.writeLock.Unlock()
+	return that.encoder.Encode(msg)
+}
+
+// Notify 发送不需要回复的消息
+func (that *Channel) Notify(msg *Message) error {
+	msg.Id = atomic.AddUint64(&that.seq, 1)
+	return that.write(msg)
+}
+
+// Request 发送消息并等待回复，回复消息中的Error不为空时返回错误
+func (that *Channel) Request(msg *Message, timeout time.Duration) (*Message, error) {
+	msg.Id = atomic.AddUint64(&that.seq, 1)
+	ch := make(chan *Message, 1)
+	that.pending.Set(msg.Id, ch)
+	if err := that.write(msg); err != nil {
+		that.pending.Remove(msg.Id)
+		return nil, err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case reply := <-ch:
+		if reply.Error != "" {
+			return reply, gerror.New(reply.Error)
+		}
+		return reply, nil
+	case <-timer.C:
+		that.pending.Remove(msg.Id)
+		return nil, gerror.Newf("等待IPC消息[%s]的回复超时", msg.Type)
+	case <-that.closed:
+		return nil, gerror.New("IPC通道已关闭")
+	}
+}
+
+// Done 通道关闭时，返回的chan会被关闭
+func (that *Channel) Done() <-chan struct{} {
+	return that.closed
+}
+
+// Close 关闭通道
+func (that *Channel) Close() {
+	that.closeOnce.Do(func() {
+		_ = that.conn.Close()
+		close(that.closed)
+	})
+}
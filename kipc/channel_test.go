@@ -0,0 +1,127 @@
+package kipc
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestPair 创建一对相连的Channel，分别模拟主进程和子进程
+func newTestPair(t *testing.T) (parent, child *Channel) {
+	t.Helper()
+	parent, f, err := NewPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if child, err = NewChannel(f); err != nil {
+		parent.Close()
+		t.Fatal(err)
+	}
+	go parent.Serve()
+	go child.Serve()
+	t.Cleanup(func() {
+		parent.Close()
+		child.Close()
+	})
+	return parent, child
+}
+
+func TestChannelRequest(t *testing.T) {
+	parent, child := newTestPair(t)
+	child.Handle(MsgStartApps, func(msg *Message) *Message {
+		return &Message{Apps: msg.Apps}
+	})
+	child.Handle(MsgStatus, func(msg *Message) *Message {
+		return (&Message{}).SetData(&Status{Pid: 42, Executor: "ex"})
+	})
+	child.Handle(MsgStopApps, func(msg *Message) *Message {
+		return &Message{Error: "stop failed"}
+	})
+	// 返回nil表示不需要回复，请求方等待超时
+	child.Handle(MsgLogLevel, func(msg *Message) *Message {
+		return nil
+	})
+
+	cases := []struct {
+		name    string
+		msg     *Message
+		timeout time.Duration
+		apps    []string
+		pid     int
+		err     string
+	}{
+		{name: "reply apps", msg: NewMessage(MsgStartApps, "a", "b"), timeout: time.Second, apps: []string{"a", "b"}},
+		{name: "reply data", msg: NewMessage(MsgStatus), timeout: time.Second, pid: 42},
+		{name: "reply error", msg: NewMessage(MsgStopApps, "a"), timeout: time.Second, err: "stop failed"},
+		{name: "unsupported type", msg: NewMessage(MsgReload), timeout: time.Second, err: "unsupported message type"},
+		{name: "no reply", msg: &Message{Type: MsgLogLevel, Level: "INFO"}, timeout: 100 * time.Millisecond, err: "超时"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			reply, err := parent.Request(c.msg, c.timeout)
+			if c.err != "" {
+				if err == nil || !strings.Contains(err.Error(), c.err) {
+					t.Fatalf("err = %v, want %q", err, c.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reply.Reply || reply.Id != c.msg.Id || reply.Type != c.msg.Type {
+				t.Fatalf("reply = %+v does not match request %+v", reply, c.msg)
+			}
+			if strings.Join(reply.Apps, ",") != strings.Join(c.apps, ",") {
+				t.Fatalf("apps = %v, want %v", reply.Apps, c.apps)
+			}
+			status := &Status{}
+			if err = reply.GetData(status); err != nil {
+				t.Fatal(err)
+			}
+			if status.Pid != c.pid {
+				t.Fatalf("pid = %d, want %d", status.Pid, c.pid)
+			}
+		})
+	}
+}
+
+func TestChannelNotify(t *testing.T) {
+	parent, child := newTestPair(t)
+	got := make(chan *Message, 1)
+	parent.Handle(MsgHeartbeat, func(msg *Message) *Message {
+		got <- msg
+		return nil
+	})
+	if err := child.Notify(NewMessage(MsgHeartbeat).SetData(&Status{Pid: 7})); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-got:
+		status := &Status{}
+		if err := msg.GetData(status); err != nil || status.Pid != 7 {
+			t.Fatalf("status = %+v, err = %v", status, err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("notify was not delivered")
+	}
+}
+
+func TestChannelClosed(t *testing.T) {
+	parent, child := newTestPair(t)
+	child.Handle(MsgStatus, func(msg *Message) *Message {
+		child.Close()
+		return nil
+	})
+	start := time.Now()
+	if _, err := parent.Request(NewMessage(MsgStatus), 5*time.Second); err == nil {
+		t.Fatal("request should fail after the peer closed")
+	}
+	if time.Since(start) > time.Second {
+		t.Fatal("request should return when the channel is closed instead of waiting for the timeout")
+	}
+	select {
+	case <-parent.Done():
+	case <-time.After(time.Second):
+		t.Fatal("Done was not closed")
+	}
+}
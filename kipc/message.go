@@ -0,0 +1,108 @@
+package kipc
+
+import (
+	"encoding/json"
+)
+
+// MsgType 主进程与子进程之间传递的消息类型
+type MsgType string
+
+const (
+	MsgStartApps MsgType = "start_apps" // 主进程 -> 子进程：启动App
+	MsgStopApps  MsgType = "stop_apps"  // 主进程 -> 子进程：停止App
+	MsgReload    MsgType = "reload"     // 主进程 -> 子进程：重启App
+	MsgStatus    MsgType = "status"     // 主进程 -> 子进程：获取子进程的运行状态
+	MsgLogLevel  MsgType = "log_level"  // 主进程 -> 子进程：修改日志级别
+	MsgHeartbeat MsgType = "heartbeat"  // 子进程 -> 主进程：心跳，Data为子进程的Status
+	MsgStack     MsgType = "stack"      // 主进程 -> 子进程：获取子进程所有goroutine的调用栈
+	MsgMetrics   MsgType = "metrics"    // 主进程 -> 子进程：获取子进程中注册的指标
+	MsgProfile   MsgType = "profile"    // 主进程 -> 子进程：生成子进程的pprof profile，Data为ProfileRequest
+	MsgEvent     MsgType = "event"      // 子进程 -> 主进程：子进程中产生的生命周期事件，Data为kevent.Event
+	MsgReady     MsgType = "ready"      // 子进程 -> 主进程：子进程中的App已全部启动，Data为子进程的Status
+	MsgTrigger   MsgType = "trigger"    // 主进程 -> 子进程：立即执行一次定时App
+	MsgResize    MsgType = "resize"     // 主进程 -> 子进程：调整WorkerApp的并发数，Data为ResizeRequest
+	MsgAddApp    MsgType = "add_app"    // 主进程 -> 子进程：添加App，Data为AppSpec，Apps不为空时添加后启动
+	MsgRemoveApp MsgType = "remove_app" // 主进程 -> 子进程：停止并移除App
+)
+
+/*
+Message 主进程与子进程之间传递的消息；
+请求消息与回复消息使用同一个结构，回复消息的Id与请求消息相同，且Reply为true。
+*/
+type Message struct {
+	Id    uint64          `json:"id"`              // 消息序号
+	Type  MsgType         `json:"type"`            // 消息类型
+	Reply bool            `json:"reply,omitempty"` // 是否为回复消息
+	Apps  []string        `json:"apps,omitempty"`  // 需要操作的App列表，或者操作成功的App列表
+	Level string          `json:"level,omitempty"` // 日志级别
+	Data  json.RawMessage `json:"data,omitempty"`  // 其他数据
+	Error string          `json:"error,omitempty"` // 处理消息时出现的错误
+}
+
+// NewMessage 创建消息
+func NewMessage(t MsgType, apps ...string) *Message {
+	return &Message{Type: t, Apps: apps}
+}
+
+// SetData 将v序列化后保存到消息的Data中
+func (that *Message) SetData(v interface{}) *Message {
+	that.Data, _ = json.Marshal(v)
+	return that
+}
+
+// GetData 将消息的Data反序列化到v中
+func (that *Message) GetData(v interface{}) error {
+	if len(that.Data) == 0 {
+		return nil
+	}
+	return json.Unmarshal(that.Data, v)
+}
+
+// AppState 子进程中App的运行状态
+type AppState struct {
+	Name      string `json:"name"`
+	State     string `json:"state"`
+	StartTime string `json:"startTime,omitempty"`
+	StopTime  string `json:"stopTime,omitempty"`
+	Restarts  int    `json:"restarts"`            // App被重启的次数
+	Schedule  string `json:"schedule,omitempty"`  // 定时App的执行计划
+	Job       bool   `json:"job,omitempty"`       // 是否为一次性任务
+	LastRun   string `json:"lastRun,omitempty"`   // 定时App或一次性任务最近一次执行的开始时间
+	NextRun   string `json:"nextRun,omitempty"`   // 定时App下一次按计划执行的时间
+	LastError string `json:"lastError,omitempty"` // 定时App或一次性任务最近一次执行的错误
+	Runs      int    `json:"runs,omitempty"`      // 定时App或一次性任务的执行次数
+	Workers   int    `json:"workers,omitempty"`   // WorkerApp当前的worker数
+	InFlight  int64  `json:"inFlight,omitempty"`  // WorkerApp正在处理的任务数
+	Processed int64  `json:"processed,omitempty"` // WorkerApp处理成功的任务数
+	Failed    int64  `json:"failed,omitempty"`    // WorkerApp处理失败的任务数
+}
+
+// Status 子进程的运行状态，MsgStatus消息回复的Data
+type Status struct {
+	Pid         int         `json:"pid"`
+	Executor    string      `json:"executor"`
+	AppsRunning []string    `json:"appsRunning"`
+	Apps        []*AppState `json:"apps"`
+}
+
+// ResizeRequest MsgResize消息的Data
+type ResizeRequest struct {
+	Concurrency int `json:"concurrency"` // 调整后的并发数
+}
+
+/*
+AppSpec 运行时添加到Executor的App，MsgAddApp消息的Data；
+Type不为空时通过App工厂创建，否则从From(App在StartFunc中所属的Executor)中移入。
+*/
+type AppSpec struct {
+	Name   string                 `json:"name"`             // App名称
+	Type   string                 `json:"type,omitempty"`   // App工厂的类型名称
+	Config map[string]interface{} `json:"config,omitempty"` // App的配置，写入apps.[Name]节点
+	From   string                 `json:"from,omitempty"`   // App在StartFunc中所属的Executor
+}
+
+// ProfileRequest MsgProfile消息的Data
+type ProfileRequest struct {
+	Kind    string `json:"kind"`    // profile类型：cpu、heap、goroutine、block、mutex
+	Seconds int    `json:"seconds"` // 采样时长
+}
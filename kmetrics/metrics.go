@@ -0,0 +1,296 @@
+package kmetrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gogf/gf/container/gmap"
+)
+
+/*
+  kmetrics 以Prometheus文本格式输出的指标；
+  keeper在管理接口的/metrics上输出所有指标，多进程模式下会汇总所有子进程中注册的指标；
+  App可以通过Register注册自己的Collector，或者直接使用NewCounter、NewGauge创建指标。
+*/
+
+// 指标类型
+const (
+	TypeCounter = "counter"
+	TypeGauge   = "gauge"
+	TypeUntyped = "untyped"
+)
+
+// Sample 指标的一个样本
+type Sample struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// Metric 一个指标及其所有样本
+type Metric struct {
+	Name    string    `json:"name"`
+	Help    string    `json:"help,omitempty"`
+	Type    string    `json:"type"`
+	Samples []*Sample `json:"samples"`
+}
+
+// AddSample 添加样本，labels为标签名与标签值依次排列
+func (that *Metric) AddSample(value float64, labels ...string) *Metric {
+	s := &Sample{Value: value}
+	if len(labels) > 0 {
+		s.Labels = make(map[string]string, len(labels)/2)
+		for i := 0; i+1 < len(labels); i += 2 {
+			s.Labels[labels[i]] = labels[i+1]
+		}
+	}
+	that.Samples = append(that.Samples, s)
+	return that
+}
+
+// WithLabels 为所有样本加上标签，用于主进程汇总子进程的指标
+func (that *Metric) WithLabels(labels map[string]string) *Metric {
+	for _, s := range that.Samples {
+		if s.Labels == nil {
+			s.Labels = map[string]string{}
+		}
+		for k, v := range labels {
+			s.Labels[k] = v
+		}
+	}
+	return that
+}
+
+// Collector 指标收集器
+type Collector interface {
+	Collect() []*Metric
+}
+
+// CollectorFunc 将方法转换为Collector
+type CollectorFunc func() []*Metric
+
+func (that CollectorFunc) Collect() []*Metric {
+	return that()
+}
+
+// Registry 保存Collector
+type Registry struct {
+	collectors *gmap.StrAnyMap
+}
+
+// NewRegistry Registry工厂函数
+func NewRegistry() *Registry {
+	return &Registry{collectors: gmap.NewStrAnyMap(true)}
+}
+
+// DefaultRegistry 默认Registry，App注册的指标保存在这里
+var DefaultRegistry = NewRegistry()
+
+// Register 注册Collector，name相同时替换原有的Collector
+func (that *Registry) Register(name string, c Collector) {
+	that.collectors.Set(name, c)
+}
+
+// Unregister 移除Collector
+func (that *Registry) Unregister(name string) {
+	that.collectors.Remove(name)
+}
+
+// Gather 收集所有Collector的指标
+func (that *Registry) Gather() []*Metric {
+	var (
+		metrics []*Metric
+		names   = that.collectors.Keys()
+	)
+	sort.Strings(names)
+	for _, name := range names {
+		if c, ok := that.collectors.Get(name).(Collector); ok {
+			metrics = append(metrics, c.Collect()...)
+		}
+	}
+	return metrics
+}
+
+// Register 向DefaultRegistry注册Collector
+func Register(name string, c Collector) {
+	DefaultRegistry.Register(name, c)
+}
+
+// Unregister 从DefaultRegistry移除Collector
+func Unregister(name string) {
+	DefaultRegistry.Unregister(name)
+}
+
+// WriteText 以Prometheus文本格式输出指标，同名指标会被合并
+func WriteText(w io.Writer, metrics []*Metric) error {
+	var (
+		order  []string
+		merged = map[string]*Metric{}
+	)
+	for _, m := range metrics {
+		if v, ok := merged[m.Name]; ok {
+			v.Samples = append(v.Samples, m.Samples...)
+			continue
+		}
+		merged[m.Name] = &Metric{Name: m.Name, Help: m.Help, Type: m.Type, Samples: append([]*Sample{}, m.Samples...)}
+		order = append(order, m.Name)
+	}
+	bw := bufio.NewWriter(w)
+	for _, name := range order {
+		m := merged[name]
+		if m.Help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", name, escape(m.Help, false))
+		}
+		if m.Type == "" {
+			m.Type = TypeUntyped
+		}
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, m.Type)
+		for _, s := range m.Samples {
+			bw.WriteString(name)
+			writeLabels(bw, s.Labels)
+			bw.WriteByte(' ')
+			bw.WriteString(formatValue(s.Value))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+func writeLabels(w *bufio.Writer, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	w.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			w.WriteByte(',')
+		}
+		fmt.Fprintf(w, `%s="%s"`, k, escape(labels[k], true))
+	}
+	w.WriteByte('}')
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func escape(s string, quote bool) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	s = strings.ReplaceAll(s, "\n", `\n`)
+	if quote {
+		s = strings.ReplaceAll(s, `"`, `\"`)
+	}
+	return s
+}
+
+// vec 带标签的指标，Counter和Gauge共用
+type vec struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+	lock   sync.RWMutex
+	values map[string]float64 // key: 标签值以\xff连接
+}
+
+func newVec(typ, name, help string, labels ...string) *vec {
+	return &vec{name: name, help: help, typ: typ, labels: labels, values: map[string]float64{}}
+}
+
+func (that *vec) add(v float64, values ...string) {
+	key := strings.Join(values, "\xff")
+	that.lock.Lock()
+	that.values[key] += v
+	that.lock.Unlock()
+}
+
+func (that *vec) set(v float64, values ...string) {
+	key := strings.Join(values, "\xff")
+	that.lock.Lock()
+	that.values[key] = v
+	that.lock.Unlock()
+}
+
+// Collect 实现Collector接口
+func (that *vec) Collect() []*Metric {
+	m := &Metric{Name: that.name, Help: that.help, Type: that.typ}
+	that.lock.RLock()
+	keys := make([]string, 0, len(that.values))
+	for k := range that.values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		var labels []string
+		values := strings.Split(key, "\xff")
+		for i, name := range that.labels {
+			if i < len(values) {
+				labels = append(labels, name, values[i])
+			}
+		}
+		m.AddSample(that.values[key], labels...)
+	}
+	that.lock.RUnlock()
+	return []*Metric{m}
+}
+
+// Counter 只增不减的计数器
+type Counter struct {
+	*vec
+}
+
+// NewCounter 创建计数器，labels为标签名
+func NewCounter(name, help string, labels ...string) *Counter {
+	return &Counter{newVec(TypeCounter, name, help, labels...)}
+}
+
+// Inc 计数加1，values为与标签名一一对应的标签值
+func (that *Counter) Inc(values ...string) {
+	that.add(1, values...)
+}
+
+// Add 计数加v，v不能为负数
+func (that *Counter) Add(v float64, values ...string) {
+	if v < 0 {
+		return
+	}
+	that.add(v, values...)
+}
+
+// Gauge 可增可减的指标
+type Gauge struct {
+	*vec
+}
+
+// NewGauge 创建Gauge，labels为标签名
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return &Gauge{newVec(TypeGauge, name, help, labels...)}
+}
+
+// Set 设置值，values为与标签名一一对应的标签值
+func (that *Gauge) Set(v float64, values ...string) {
+	that.set(v, values...)
+}
+
+// Add 增加v，v可以为负数
+func (that *Gauge) Add(v float64, values ...string) {
+	that.add(v, values...)
+}
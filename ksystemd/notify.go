@@ -0,0 +1,101 @@
+package ksystemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+	"github.com/gogf/gf/os/genv"
+	"github.com/gogf/gf/util/gconv"
+)
+
+/*
+  systemd的sd_notify协议：通过环境变量NOTIFY_SOCKET指定的unix数据报套接字向systemd发送状态，
+  每个数据报由若干行KEY=VALUE组成，常用的有：
+    READY=1        服务已启动完成，Type=notify的服务在发送之前一直处于activating状态
+    STATUS=...     服务当前状态的描述，显示在systemctl status中
+    WATCHDOG=1     看门狗心跳，需要在WATCHDOG_USEC内至少发送一次
+    MAINPID=...    服务的主进程发生变化，例如重新执行了二进制文件
+    STOPPING=1     服务正在结束
+  未以systemd服务运行(没有NOTIFY_SOCKET)时，所有方法都不做任何事情。
+*/
+
+const (
+	EnvNotifySocket = "NOTIFY_SOCKET"
+	EnvWatchdogUsec = "WATCHDOG_USEC"
+	EnvWatchdogPid  = "WATCHDOG_PID"
+)
+
+// Enabled 当前进程是否由systemd以Type=notify的方式启动
+func Enabled() bool {
+	return genv.Get(EnvNotifySocket) != ""
+}
+
+/*
+Notify 向systemd发送状态，states中的每一项为一行KEY=VALUE；
+未设置NOTIFY_SOCKET时返回false和nil。
+*/
+func Notify(states ...string) (bool, error) {
+	socket := genv.Get(EnvNotifySocket)
+	if socket == "" {
+		return false, nil
+	}
+	addr := &net.UnixAddr{Name: socket, Net: "unixgram"}
+	// 以@开头的是抽象命名空间中的套接字
+	if strings.HasPrefix(socket, "@") {
+		addr.Name = "\x00" + socket[1:]
+	}
+	conn, err := net.DialUnix(addr.Net, nil, addr)
+	if err != nil {
+		return false, gerror.WrapCodef(gcode.CodeOperationFailed, err, "连接%s失败", socket)
+	}
+	defer conn.Close()
+	if _, err = conn.Write([]byte(strings.Join(states, "\n"))); err != nil {
+		return false, gerror.WrapCodef(gcode.CodeOperationFailed, err, "向%s发送状态失败", socket)
+	}
+	return true, nil
+}
+
+// Ready 服务已启动完成，同时更新状态描述
+func Ready(status string) (bool, error) {
+	return Notify("READY=1", "STATUS="+status)
+}
+
+// Status 更新状态描述
+func Status(status string) (bool, error) {
+	return Notify("STATUS=" + status)
+}
+
+// Watchdog 发送看门狗心跳
+func Watchdog() (bool, error) {
+	return Notify("WATCHDOG=1")
+}
+
+// Stopping 服务正在结束
+func Stopping(status string) (bool, error) {
+	return Notify("STOPPING=1", "STATUS="+status)
+}
+
+// MainPid 服务的主进程变为pid，在重新执行二进制文件、由新进程接替主进程时发送
+func MainPid(pid int) (bool, error) {
+	return Notify(fmt.Sprintf("MAINPID=%d", pid))
+}
+
+/*
+WatchdogInterval 发送看门狗心跳的间隔，为WATCHDOG_USEC的一半；
+未开启看门狗，或者WATCHDOG_PID不是当前进程时返回0。
+*/
+func WatchdogInterval() time.Duration {
+	usec := gconv.Int64(genv.Get(EnvWatchdogUsec))
+	if usec <= 0 {
+		return 0
+	}
+	if pid := genv.Get(EnvWatchdogPid); pid != "" && gconv.Int(pid) != os.Getpid() {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond / 2
+}
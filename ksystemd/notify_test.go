@@ -0,0 +1,107 @@
+package ksystemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// listenNotify 监听临时的unix数据报套接字，并设置NOTIFY_SOCKET为socket
+func listenNotify(t *testing.T, socket string) *net.UnixConn {
+	name := socket
+	if socket[0] == '@' {
+		name = "\x00" + socket[1:]
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: name, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = conn.Close() })
+	t.Setenv(EnvNotifySocket, socket)
+	return conn
+}
+
+// readNotify 读取一个数据报
+func readNotify(t *testing.T, conn *net.UnixConn) string {
+	buf := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func TestNotify(t *testing.T) {
+	conn := listenNotify(t, filepath.Join(t.TempDir(), "notify.sock"))
+	cases := []struct {
+		name   string
+		notify func() (bool, error)
+		want   string
+	}{
+		{"ready", func() (bool, error) { return Ready("1 executors, 2/2 replicas ready") },
+			"READY=1\nSTATUS=1 executors, 2/2 replicas ready"},
+		{"status", func() (bool, error) { return Status("1 executors, 1/2 replicas ready") },
+			"STATUS=1 executors, 1/2 replicas ready"},
+		{"watchdog", Watchdog, "WATCHDOG=1"},
+		{"stopping", func() (bool, error) { return Stopping("stopping") }, "STOPPING=1\nSTATUS=stopping"},
+		{"main pid", func() (bool, error) { return MainPid(4321) }, "MAINPID=4321"},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			sent, err := c.notify()
+			if err != nil || !sent {
+				t.Fatalf("sent = %v, err = %v", sent, err)
+			}
+			if got := readNotify(t, conn); got != c.want {
+				t.Fatalf("payload = %q, want %q", got, c.want)
+			}
+		})
+	}
+}
+
+func TestNotifyAbstractSocket(t *testing.T) {
+	conn := listenNotify(t, fmt.Sprintf("@gokeeper-notify-test-%d", os.Getpid()))
+	if sent, err := Watchdog(); err != nil || !sent {
+		t.Fatalf("sent = %v, err = %v", sent, err)
+	}
+	if got := readNotify(t, conn); got != "WATCHDOG=1" {
+		t.Fatalf("payload = %q, want %q", got, "WATCHDOG=1")
+	}
+}
+
+func TestNotifyDisabled(t *testing.T) {
+	t.Setenv(EnvNotifySocket, "")
+	if Enabled() {
+		t.Fatal("Enabled() = true without NOTIFY_SOCKET")
+	}
+	if sent, err := Ready("ready"); err != nil || sent {
+		t.Fatalf("sent = %v, err = %v", sent, err)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	cases := []struct {
+		name string
+		usec string
+		pid  string
+		want time.Duration
+	}{
+		{"disabled", "", "", 0},
+		{"half of usec", "10000000", "", 5 * time.Second},
+		{"current pid", "10000000", fmt.Sprint(os.Getpid()), 5 * time.Second},
+		{"other pid", "10000000", fmt.Sprint(os.Getpid() + 1), 0},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Setenv(EnvWatchdogUsec, c.usec)
+			t.Setenv(EnvWatchdogPid, c.pid)
+			if got := WatchdogInterval(); got != c.want {
+				t.Fatalf("WatchdogInterval() = %v, want %v", got, c.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package ksystemd
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+/*
+  根据配置生成systemd的unit文件，配置示例：
+    systemd:
+      description: "my keeper"   # 默认为keeper名称
+      user: www                  # 运行服务的用户，默认不设置
+      group: www
+      workingDirectory: /data/app
+      restart: on-failure        # 默认on-failure
+      restartSec: 3s
+      watchdog: 30s              # WatchdogSec，不设置时不开启看门狗
+      limitNOFILE: 65535
+      after: [network-online.target]
+      environment:
+        GOMAXPROCS: 4
+  生成的服务为Type=notify，主进程在所有Executor都启动完成后发送READY=1。
+*/
+
+// UnitConfig 生成unit文件需要的配置
+type UnitConfig struct {
+	Name             string            // 服务名称，即keeper名称
+	ExecStart        []string          // 启动命令及参数
+	PidFile          string            // 主进程的pid文件
+	StopTimeout      time.Duration     // TimeoutStopSec
+	Description      string            // 服务描述
+	User             string            // 运行服务的用户
+	Group            string            // 运行服务的用户组
+	WorkingDirectory string            // 工作目录
+	Restart          string            // 重启策略
+	RestartSec       time.Duration     // 重启间隔
+	Watchdog         time.Duration     // 看门狗超时时间
+	LimitNOFILE      int               // 最大文件描述符数
+	After            []string          // 在这些unit之后启动
+	Environment      map[string]string // 环境变量
+}
+
+var (
+	// ExecStart中的$会被当作环境变量展开
+	argReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`, `%`, `%%`)
+	envReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `%`, `%%`)
+)
+
+// quote 按照systemd的规则为ExecStart中的参数或者Environment中的变量加引号
+func quote(s string, r *strings.Replacer) string {
+	if s != "" && !strings.ContainsAny(s, " \t\"'\\;$%") {
+		return s
+	}
+	return `"` + r.Replace(s) + `"`
+}
+
+// seconds 以秒为单位的时长，systemd也支持带单位的写法，这里统一使用秒
+func seconds(d time.Duration) string {
+	return fmt.Sprintf("%gs", d.Seconds())
+}
+
+// Render 生成unit文件的内容
+func (that *UnitConfig) Render() []byte {
+	buf := &bytes.Buffer{}
+	line := func(key string, value interface{}) {
+		fmt.Fprintf(buf, "%s=%v\n", key, value)
+	}
+	description := that.Description
+	if description == "" {
+		description = that.Name
+	}
+	after := that.After
+	if len(after) == 0 {
+		after = []string{"network.target"}
+	}
+
+	buf.WriteString("[Unit]\n")
+	line("Description", description)
+	line("After", strings.Join(after, " "))
+	line("Wants", strings.Join(after, " "))
+
+	buf.WriteString("\n[Service]\n")
+	line("Type", "notify")
+	// 只接受主进程发送的状态，子进程继承了NOTIFY_SOCKET也不会影响服务状态
+	line("NotifyAccess", "main")
+	args := make([]string, 0, len(that.ExecStart))
+	for _, arg := range that.ExecStart {
+		args = append(args, quote(arg, argReplacer))
+	}
+	line("ExecStart", strings.Join(args, " "))
+	if that.PidFile != "" {
+		line("PIDFile", that.PidFile)
+	}
+	if that.WorkingDirectory != "" {
+		line("WorkingDirectory", that.WorkingDirectory)
+	}
+	if that.User != "" {
+		line("User", that.User)
+	}
+	if that.Group != "" {
+		line("Group", that.Group)
+	}
+	keys := make([]string, 0, len(that.Environment))
+	for k := range that.Environment {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		line("Environment", quote(fmt.Sprintf("%s=%s", k, that.Environment[k]), envReplacer))
+	}
+	restart := that.Restart
+	if restart == "" {
+		restart = "on-failure"
+	}
+	line("Restart", restart)
+	if that.RestartSec > 0 {
+		line("RestartSec", seconds(that.RestartSec))
+	}
+	if that.Watchdog > 0 {
+		line("WatchdogSec", seconds(that.Watchdog))
+	}
+	// 主进程收到SIGTERM后负责结束所有子进程，超时后systemd再强制结束剩余的进程
+	line("KillMode", "mixed")
+	if that.StopTimeout > 0 {
+		line("TimeoutStopSec", seconds(that.StopTimeout))
+	}
+	if that.LimitNOFILE > 0 {
+		line("LimitNOFILE", that.LimitNOFILE)
+	}
+
+	buf.WriteString("\n[Install]\n")
+	line("WantedBy", "multi-user.target")
+	return buf.Bytes()
+}
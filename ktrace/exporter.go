@@ -0,0 +1,149 @@
+package ktrace
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gogf/gf/errors/gerror"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// DefaultOtlpEndpoint 本地OTLP collector的默认地址
+const DefaultOtlpEndpoint = "http://127.0.0.1:4318/v1/traces"
+
+/*
+StdoutExporter 以JSON格式将span逐行写入标准输出或文件，便于调试；
+path为空或为"stdout"时写入标准输出。
+*/
+type StdoutExporter struct {
+	lock sync.Mutex
+	w    io.Writer
+	f    *os.File
+}
+
+// NewStdoutExporter StdoutExporter工厂函数
+func NewStdoutExporter(path string) (*StdoutExporter, error) {
+	if path == "" || path == "stdout" {
+		return &StdoutExporter{w: os.Stdout}, nil
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &StdoutExporter{w: f, f: f}, nil
+}
+
+// stdoutSpan StdoutExporter输出的span
+type stdoutSpan struct {
+	Name         string                 `json:"name"`
+	TraceId      string                 `json:"traceId"`
+	SpanId       string                 `json:"spanId"`
+	ParentSpanId string                 `json:"parentSpanId,omitempty"`
+	Kind         string                 `json:"kind"`
+	StartTime    time.Time              `json:"startTime"`
+	EndTime      time.Time              `json:"endTime"`
+	Duration     string                 `json:"duration"`
+	Attributes   map[string]interface{} `json:"attributes,omitempty"`
+	Events       []*stdoutEvent         `json:"events,omitempty"`
+	Status       string                 `json:"status"`
+	StatusDesc   string                 `json:"statusDescription,omitempty"`
+	Resource     map[string]interface{} `json:"resource,omitempty"`
+}
+
+type stdoutEvent struct {
+	Name       string                 `json:"name"`
+	Time       time.Time              `json:"time"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// ExportSpans 实现sdktrace.SpanExporter接口
+func (that *StdoutExporter) ExportSpans(_ context.Context, spans []sdktrace.ReadOnlySpan) error {
+	that.lock.Lock()
+	defer that.lock.Unlock()
+	for _, s := range spans {
+		out := &stdoutSpan{
+			Name:       s.Name(),
+			TraceId:    s.SpanContext().TraceID().String(),
+			SpanId:     s.SpanContext().SpanID().String(),
+			Kind:       s.SpanKind().String(),
+			StartTime:  s.StartTime(),
+			EndTime:    s.EndTime(),
+			Duration:   s.EndTime().Sub(s.StartTime()).String(),
+			Attributes: attrMap(s.Attributes()),
+			Status:     s.Status().Code.String(),
+			StatusDesc: s.Status().Description,
+		}
+		if s.Parent().HasSpanID() {
+			out.ParentSpanId = s.Parent().SpanID().String()
+		}
+		if s.Resource() != nil {
+			out.Resource = attrMap(s.Resource().Attributes())
+		}
+		for _, e := range s.Events() {
+			out.Events = append(out.Events, &stdoutEvent{Name: e.Name, Time: e.Time, Attributes: attrMap(e.Attributes)})
+		}
+		content, err := json.Marshal(out)
+		if err != nil {
+			return err
+		}
+		if _, err = that.w.Write(append(content, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown 实现sdktrace.SpanExporter接口
+func (that *StdoutExporter) Shutdown(_ context.Context) error {
+	if that.f != nil {
+		return that.f.Close()
+	}
+	return nil
+}
+
+func attrMap(attrs []attribute.KeyValue) map[string]interface{} {
+	if len(attrs) == 0 {
+		return nil
+	}
+	m := make(map[string]interface{}, len(attrs))
+	for _, kv := range attrs {
+		m[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	return m
+}
+
+/*
+NewOtlpExporter 创建官方的OTLP/HTTP导出器，使用protobuf编码和gzip压缩，导出失败时按照导出器默认的策略重试；
+endpoint为完整的URL，为空时使用DefaultOtlpEndpoint，https以外的地址不使用TLS；
+collector需要开启otlp receiver的http协议(默认端口4318)。
+*/
+func NewOtlpExporter(endpoint string, headers map[string]string) (sdktrace.SpanExporter, error) {
+	if endpoint == "" {
+		endpoint = DefaultOtlpEndpoint
+	}
+	u, err := url.Parse(endpoint)
+	if err != nil || u.Host == "" {
+		return nil, gerror.Newf("invalid otlp endpoint: %s", endpoint)
+	}
+	opts := []otlptracehttp.Option{
+		otlptracehttp.WithEndpoint(u.Host),
+		otlptracehttp.WithCompression(otlptracehttp.GzipCompression),
+	}
+	if u.Path != "" {
+		opts = append(opts, otlptracehttp.WithURLPath(u.Path))
+	}
+	if u.Scheme != "https" {
+		opts = append(opts, otlptracehttp.WithInsecure())
+	}
+	if len(headers) > 0 {
+		opts = append(opts, otlptracehttp.WithHeaders(headers))
+	}
+	return otlptracehttp.New(context.Background(), opts...)
+}
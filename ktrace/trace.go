@@ -0,0 +1,187 @@
+package ktrace
+
+import (
+	"context"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gogf/gf/errors/gerror"
+	ktype "github.com/moqsien/gokeeper/ktype"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdkresource "go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+/*
+  ktrace 基于OpenTelemetry的链路追踪；
+  未开启时使用otel默认的空TracerProvider，不会产生任何开销。
+  配置示例：
+    trace:
+      enable: true
+      exporter: otlp                                 # otlp 或 stdout
+      endpoint: "http://127.0.0.1:4318/v1/traces"    # otlp导出地址，使用OTLP/HTTP的protobuf编码
+      headers:                                       # otlp请求附带的header
+        Authorization: "Bearer xxxxxx"
+      path: ""                                       # stdout导出的文件路径，默认为标准输出
+      sampleRatio: 1                                 # 采样率，默认全部采样
+  也可以在StartFunc中调用SetTracerProvider使用自定义的TracerProvider，此时忽略上述配置。
+  多进程模式下，主进程通过环境变量TRACEPARENT/TRACESTATE将trace context传给子进程，子进程中的span与主进程属于同一条链路。
+*/
+
+// 导出方式
+const (
+	ExporterOtlp   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// TracerName keeper使用的Tracer名称
+const TracerName = "github.com/moqsien/gokeeper"
+
+// 常用的span属性
+const (
+	AttrKeeper   = attribute.Key("keeper.name")
+	AttrRole     = attribute.Key("keeper.role")
+	AttrExecutor = attribute.Key("keeper.executor")
+	AttrReplica  = attribute.Key("keeper.replica")
+	AttrApp      = attribute.Key("keeper.app")
+	AttrCommand  = attribute.Key("keeper.command")
+	AttrSource   = attribute.Key("keeper.source")
+	AttrActor    = attribute.Key("keeper.actor")
+)
+
+// Config 链路追踪配置，对应配置文件中的trace节点
+type Config struct {
+	Enable      bool              `json:"enable"`
+	Exporter    string            `json:"exporter"`
+	Endpoint    string            `json:"endpoint"`
+	Headers     map[string]string `json:"headers"`
+	Path        string            `json:"path"`
+	SampleRatio float64           `json:"sampleRatio"`
+}
+
+// userProvider 是否已通过SetTracerProvider设置了TracerProvider
+var userProvider int32
+
+// SetTracerProvider 设置全局的TracerProvider，设置后keeper不再根据配置创建TracerProvider
+func SetTracerProvider(tp trace.TracerProvider) {
+	atomic.StoreInt32(&userProvider, 1)
+	otel.SetTracerProvider(tp)
+}
+
+// HasTracerProvider 是否已通过SetTracerProvider设置了TracerProvider
+func HasTracerProvider() bool {
+	return atomic.LoadInt32(&userProvider) == 1
+}
+
+/*
+Setup 根据配置创建TracerProvider并设置为全局的TracerProvider，attrs为资源属性；
+返回的方法用于在进程退出前导出剩余的span。
+*/
+func Setup(conf *Config, attrs ...attribute.KeyValue) (func(ctx context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+	if conf == nil || !conf.Enable || HasTracerProvider() {
+		return func(context.Context) error { return nil }, nil
+	}
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+	switch strings.ToLower(conf.Exporter) {
+	case ExporterStdout:
+		exporter, err = NewStdoutExporter(conf.Path)
+	case ExporterOtlp, "":
+		exporter, err = NewOtlpExporter(conf.Endpoint, conf.Headers)
+	default:
+		err = gerror.Newf("unknown trace exporter: %s", conf.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+	ratio := conf.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+	tp := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter, sdktrace.WithBatchTimeout(time.Second)),
+		sdktrace.WithResource(sdkresource.NewSchemaless(attrs...)),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(tp)
+	return tp.Shutdown, nil
+}
+
+// Tracer keeper使用的Tracer
+func Tracer() trace.Tracer {
+	return otel.Tracer(TracerName)
+}
+
+// Start 开始一个span
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return Tracer().Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End 结束span，err不为nil时记录错误
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// envCarrier 以环境变量传递trace context
+type envCarrier map[string]string
+
+var envKeys = map[string]string{
+	"traceparent": ktype.EnvTraceParent,
+	"tracestate":  ktype.EnvTraceState,
+}
+
+func (that envCarrier) Get(key string) string {
+	return that[key]
+}
+
+func (that envCarrier) Set(key, value string) {
+	that[key] = value
+}
+
+func (that envCarrier) Keys() []string {
+	keys := make([]string, 0, len(that))
+	for k := range that {
+		keys = append(keys, k)
+	}
+	return keys
+}
+
+// InjectEnv 将ctx中的trace context转换为需要传给子进程的环境变量，key为环境变量名
+func InjectEnv(ctx context.Context) map[string]string {
+	carrier := envCarrier{}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+	env := map[string]string{}
+	for k, v := range carrier {
+		if name, ok := envKeys[k]; ok && v != "" {
+			env[name] = v
+		}
+	}
+	return env
+}
+
+// ExtractEnv 从环境变量中读取主进程传来的trace context
+func ExtractEnv(ctx context.Context) context.Context {
+	carrier := envCarrier{}
+	for k, name := range envKeys {
+		if v := os.Getenv(name); v != "" {
+			carrier[k] = v
+		}
+	}
+	return propagation.TraceContext{}.Extract(ctx, carrier)
+}
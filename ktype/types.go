@@ -32,8 +32,24 @@ const (
 	EnvIsMaster             = "ENV_MULTI_MASTER"                    // 多进程模式下，主进程的标记用环境变量名
 	EnvCanCtrl              = "ENV_CAN_CTRL"                        // 是否开启交互式shell功能
 	EnvIsChild              = "GRACEFUL_IS_CHILD"                   // 当前是否是在子进程
+	EnvKeeperName           = "ENV_KEEPER_NAME"                     // 多进程模式下，子进程所属的keeper名称，用于识别残留的子进程
+	EnvMasterPid            = "ENV_MASTER_PID"                      // 多进程模式下，主进程的pid，子进程用于检查主进程是否已退出
+	EnvIPCFd                = "ENV_IPC_FD"                          // 多进程模式下，子进程与主进程通信的套接字文件描述符
+	EnvStderrFd             = "ENV_STDERR_FD"                       // 多进程模式下，子进程需要将标准错误重定向到的文件描述符
+	EnvListenFds            = "ENV_LISTEN_FDS"                      // 多进程模式下，主进程传给子进程的监听套接字，格式为name:fd,name:fd
+	EnvCompletedJobs        = "ENV_COMPLETED_JOBS"                  // 多进程模式下，主进程传给子进程的已完成的一次性任务，格式为name,name
+	EnvDynamicApps          = "ENV_DYNAMIC_APPS"                    // 多进程模式下，主进程传给子进程的运行时添加的App，格式为kipc.AppSpec列表的json
+	EnvRemovedApps          = "ENV_REMOVED_APPS"                    // 多进程模式下，主进程传给子进程的运行时移除的App，格式为name,name
+	EnvTraceParent          = "TRACEPARENT"                         // 多进程模式下，传给子进程的W3C trace context
+	EnvTraceState           = "TRACESTATE"                          // 多进程模式下，传给子进程的W3C trace state
 	ParentAddrKey           = "GRACEFUL_INHERIT_LISTEN_PARENT_ADDR" // 父进程的监听列表
-	AdminActionReloadEnvKey = "GF_SERVER_RELOAD"                    // gf框架的ghttp服务平滑重启key
+	AdminActionReloadEnvKey = "GF_SERVER_RELOAD"                    // gf框架的ghttp服务平滑重启时传递监听套接字的环境变量，keeper的子进程和GfApp中清空
 	MinShutdownTimeout      = 15 * time.Second                      // 进程收到结束或重启信号后，存活的最大时间
 	ConfigNodeNameLogger    = "logger"
+	ConfigNodeNameTrace     = "trace"     // 链路追踪配置
+	ConfigNodeNamePprof     = "pprof"     // pprof配置，默认关闭
+	ConfigNodeNameEvents    = "events"    // 生命周期事件的订阅者配置
+	ConfigNodeNameExecutors = "executors" // 各个Executor的专属配置，key为Executor名称
+	ConfigNodeNameSystemd   = "systemd"   // 生成systemd的unit文件时使用的配置
+	ConfigNodeNameApps      = "apps"      // 各个App的专属配置，key为App名称
 )
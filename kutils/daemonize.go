@@ -7,6 +7,7 @@ import (
 
 	"github.com/gogf/gf/os/gcfg"
 	"github.com/gogf/gf/os/gfile"
+	ksystemd "github.com/moqsien/gokeeper/ksystemd"
 	logger "github.com/moqsien/processes/logger"
 )
 
@@ -45,6 +46,10 @@ func Demonize(config *gcfg.Config) error {
 	if err != nil {
 		return err
 	}
+	// 以systemd服务运行时，由新进程接替主进程
+	if _, e = ksystemd.MainPid(cmd.Process.Pid); e != nil {
+		logger.Warning(e)
+	}
 	os.Exit(0)
 	return nil
 }
@@ -2,6 +2,7 @@ package kutils
 
 import (
 	"encoding/json"
+	"runtime"
 
 	"github.com/gogf/gf/util/gconv"
 )
@@ -26,3 +27,26 @@ func SliceToString[T string | interface{}](s []T) string {
 		return string(r)
 	}
 }
+
+// TrimEmpty 去掉切片中的空字符串
+func TrimEmpty(s []string) []string {
+	r := make([]string, 0, len(s))
+	for _, v := range s {
+		if v != "" {
+			r = append(r, v)
+		}
+	}
+	return r
+}
+
+// AllStacks 获取当前进程中所有goroutine的调用栈
+func AllStacks() string {
+	buf := make([]byte, 64*1024)
+	for {
+		n := runtime.Stack(buf, true)
+		if n < len(buf) {
+			return string(buf[:n])
+		}
+		buf = make([]byte, 2*len(buf))
+	}
+}
@@ -0,0 +1,76 @@
+//go:build linux
+// +build linux
+
+package kutils
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gogf/gf/util/gconv"
+)
+
+// Linux下/proc/[pid]/stat中的时间单位为clock tick，绝大多数平台上USER_HZ都是100
+const clockTicksPerSecond = 100
+
+// ProcCPUTime 从/proc/[pid]/stat中读取进程累计占用的CPU时间(用户态+内核态)
+func ProcCPUTime(pid int) (time.Duration, error) {
+	content, err := os.ReadFile(fmt.Sprintf("/proc/%d/stat", pid))
+	if err != nil {
+		return 0, err
+	}
+	d, ok := parseStatCPUTime(string(content))
+	if !ok {
+		return 0, fmt.Errorf("/proc/%d/stat 格式错误", pid)
+	}
+	return d, nil
+}
+
+// parseStatCPUTime 从/proc/[pid]/stat的内容中解析utime和stime之和
+func parseStatCPUTime(stat string) (time.Duration, bool) {
+	// 进程名可能包含空格和")"，因此从最后一个")"之后开始解析，fields[0]对应第3个字段state
+	i := strings.LastIndex(stat, ")")
+	if i < 0 {
+		return 0, false
+	}
+	fields := strings.Fields(stat[i+1:])
+	if len(fields) < 13 {
+		return 0, false
+	}
+	ticks := gconv.Int64(fields[11]) + gconv.Int64(fields[12]) // utime + stime
+	return time.Duration(ticks) * time.Second / clockTicksPerSecond, true
+}
+
+// ProcEnviron 从/proc/[pid]/environ中读取进程的环境变量
+func ProcEnviron(pid int) ([]string, error) {
+	content, err := os.ReadFile(fmt.Sprintf("/proc/%d/environ", pid))
+	if err != nil {
+		return nil, err
+	}
+	return strings.Split(strings.TrimRight(string(content), "\x00"), "\x00"), nil
+}
+
+// ProcRSS 从/proc/[pid]/statm中读取进程的常驻内存大小，单位为字节
+func ProcRSS(pid int) (int64, error) {
+	content, err := os.ReadFile(fmt.Sprintf("/proc/%d/statm", pid))
+	if err != nil {
+		return 0, err
+	}
+	fields := strings.Fields(string(content))
+	if len(fields) < 2 {
+		return 0, fmt.Errorf("/proc/%d/statm 格式错误", pid)
+	}
+	return gconv.Int64(fields[1]) * int64(os.Getpagesize()), nil
+}
+
+// PidExists 判断pid对应的进程是否存在；没有权限向其发送信号时也认为进程存在
+func PidExists(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || err == syscall.EPERM
+}
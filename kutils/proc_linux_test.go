@@ -0,0 +1,60 @@
+//go:build linux
+// +build linux
+
+package kutils
+
+import (
+	"os"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseStatCPUTime(t *testing.T) {
+	// utime和stime分别是第14、15个字段
+	const rest = " S 1 1 1 0 -1 4194560 100 0 0 0 150 50 0 0 20 0 1 0 10 1000 100"
+	cases := []struct {
+		name string
+		stat string
+		want time.Duration
+		ok   bool
+	}{
+		{"plain name", "42 (sleep)" + rest, 2 * time.Second, true},
+		{"name with spaces", "42 (my app)" + rest, 2 * time.Second, true},
+		{"name with parenthesis", "42 (a) b)" + rest, 2 * time.Second, true},
+		{"no parenthesis", "42 sleep" + rest, 0, false},
+		{"too few fields", "42 (sleep) S 1 1 1", 0, false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got, ok := parseStatCPUTime(c.stat)
+			if ok != c.ok || got != c.want {
+				t.Fatalf("parseStatCPUTime = %s, %v, want %s, %v", got, ok, c.want, c.ok)
+			}
+		})
+	}
+}
+
+// 与getrusage的结果对比，验证clockTicksPerSecond与当前系统的USER_HZ一致
+func TestProcCPUTimeMatchesRusage(t *testing.T) {
+	var rusage syscall.Rusage
+	used := func() time.Duration {
+		if err := syscall.Getrusage(syscall.RUSAGE_SELF, &rusage); err != nil {
+			t.Fatal(err)
+		}
+		return time.Duration(rusage.Utime.Nano() + rusage.Stime.Nano())
+	}
+	for x := 0; used() < 300*time.Millisecond; x++ {
+		for i := 0; i < 1e6; i++ {
+			x ^= i
+		}
+	}
+	got, err := ProcCPUTime(os.Getpid())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := used()
+	if diff := got - want; diff < -want/5-50*time.Millisecond || diff > want/5+50*time.Millisecond {
+		t.Fatalf("ProcCPUTime = %s, getrusage = %s", got, want)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build !linux
+// +build !linux
+
+package kutils
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"time"
+)
+
+var errProcNotSupported = errors.New("当前系统不支持读取/proc")
+
+// ProcCPUTime 非Linux系统不支持
+func ProcCPUTime(pid int) (time.Duration, error) {
+	return 0, errProcNotSupported
+}
+
+// ProcEnviron 非Linux系统不支持
+func ProcEnviron(pid int) ([]string, error) {
+	return nil, errProcNotSupported
+}
+
+// ProcRSS 非Linux系统不支持
+func ProcRSS(pid int) (int64, error) {
+	return 0, errProcNotSupported
+}
+
+// PidExists 判断pid对应的进程是否存在
+func PidExists(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
@@ -0,0 +1,56 @@
+package kutils
+
+import (
+	"bytes"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"github.com/gogf/gf/errors/gcode"
+	"github.com/gogf/gf/errors/gerror"
+)
+
+// ProfileKinds 支持的profile类型
+var ProfileKinds = []string{"cpu", "heap", "goroutine", "block", "mutex"}
+
+// DefaultProfileSeconds 未指定时cpu profile的采样时长
+const DefaultProfileSeconds = 10
+
+/*
+Profile 生成当前进程的pprof profile，返回pprof的protobuf格式数据，可以直接使用go tool pprof分析；
+cpu需要采样seconds秒；block和mutex默认未开启采样，seconds大于0时临时开启采样seconds秒后再生成profile。
+*/
+func Profile(kind string, seconds int) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	switch kind {
+	case "cpu":
+		if seconds <= 0 {
+			seconds = DefaultProfileSeconds
+		}
+		if err := pprof.StartCPUProfile(buf); err != nil {
+			return nil, gerror.NewCode(gcode.CodeInvalidOperation, err.Error())
+		}
+		time.Sleep(time.Duration(seconds) * time.Second)
+		pprof.StopCPUProfile()
+		return buf.Bytes(), nil
+	case "block":
+		if seconds > 0 {
+			runtime.SetBlockProfileRate(1)
+			time.Sleep(time.Duration(seconds) * time.Second)
+			defer runtime.SetBlockProfileRate(0)
+		}
+	case "mutex":
+		if seconds > 0 {
+			prev := runtime.SetMutexProfileFraction(1)
+			time.Sleep(time.Duration(seconds) * time.Second)
+			defer runtime.SetMutexProfileFraction(prev)
+		}
+	case "heap", "goroutine":
+	default:
+		return nil, gerror.NewCodef(gcode.CodeInvalidParameter, "unknown profile: %s, supported: %v", kind, ProfileKinds)
+	}
+	if err := pprof.Lookup(kind).WriteTo(buf, 0); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
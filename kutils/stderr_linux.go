@@ -0,0 +1,21 @@
+//go:build linux
+// +build linux
+
+package kutils
+
+import (
+	"syscall"
+)
+
+// RedirectStderr 将当前进程的标准错误重定向到文件描述符fd，go运行时的panic信息也会写入fd
+func RedirectStderr(fd int) error {
+	if err := syscall.Dup3(fd, syscall.Stderr, 0); err != nil {
+		return err
+	}
+	return syscall.Close(fd)
+}
+
+// CloseOnExec 为文件描述符设置FD_CLOEXEC，避免泄露给exec启动的其他进程
+func CloseOnExec(fd int) {
+	syscall.CloseOnExec(fd)
+}
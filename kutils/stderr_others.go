@@ -0,0 +1,16 @@
+//go:build !linux
+// +build !linux
+
+package kutils
+
+import (
+	"errors"
+)
+
+// RedirectStderr 非Linux系统不支持
+func RedirectStderr(fd int) error {
+	return errors.New("当前系统不支持重定向标准错误")
+}
+
+// CloseOnExec 非Linux系统不做处理
+func CloseOnExec(fd int) {}